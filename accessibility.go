@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+// AccessibilityConfig selects a colour-blind-safe palette and, with Patterns,
+// distinguishes states by blink pattern as well as by colour.
+type AccessibilityConfig struct {
+	Palette  string
+	Patterns bool
+}
+
+// palettes keep failures apart from healthy states for the common forms of
+// colour blindness, using blue/orange and brightness rather than red/green.
+var palettes = map[string]map[string]string{
+	"deuteranopia": {
+		"active":       "0044ff00",
+		"inactive":     "08080800",
+		"reloading":    "00aaaa00",
+		"failed":       "ff660000",
+		"activating":   "44226600",
+		"deactivating": "66440000",
+		"masked":       "66006600",
+		"disabled":     "08080800",
+		"skipped":      "00222200",
+		"watchdog":     "ffff0000",
+		"armed":        "00226600",
+		"absent":       "00000400",
+		"missing":      "ff660000",
+	},
+	"protanopia": {
+		"active":       "0055ff00",
+		"inactive":     "08080800",
+		"reloading":    "00aaaa00",
+		"failed":       "ffcc0000",
+		"activating":   "44226600",
+		"deactivating": "66550000",
+		"masked":       "66006600",
+		"disabled":     "08080800",
+		"skipped":      "00222200",
+		"watchdog":     "ffff0000",
+		"armed":        "00286600",
+		"absent":       "00000400",
+		"missing":      "ffcc0000",
+	},
+	"tritanopia": {
+		"active":       "00886600",
+		"inactive":     "08080800",
+		"reloading":    "66666600",
+		"failed":       "ff002200",
+		"activating":   "00444400",
+		"deactivating": "44002200",
+		"masked":       "66006600",
+		"disabled":     "08080800",
+		"skipped":      "00222200",
+		"watchdog":     "ffff0000",
+		"armed":        "00443300",
+		"absent":       "00000400",
+		"missing":      "ff002200",
+	},
+}
+
+// patterns are the blink patterns of each state in accessibility mode.
+var patterns = map[string]led.Pattern{
+	"failed":       {Period: 500 * time.Millisecond, Duty: 0.5},
+	"reloading":    {Period: time.Second, Duty: 0.5},
+	"activating":   {Period: 2 * time.Second, Duty: 0.75},
+	"deactivating": {Period: 2 * time.Second, Duty: 0.25},
+	"masked":       {Period: 4 * time.Second, Duty: 0.1},
+	"watchdog":     {Period: 250 * time.Millisecond, Duty: 0.5},
+	"missing":      {Period: time.Second, Duty: 0.2},
+}
+
+func (a AccessibilityConfig) Validate() error {
+	if _, ok := palettes[a.Palette]; a.Palette != "" && !ok {
+		return fmt.Errorf("accessibility: unknown palette %q", a.Palette)
+	}
+	return nil
+}
+
+// patternFor is the blink pattern of unit in state: the service's own, or the
+// accessibility pattern of the state.
+func patternFor(unit string, state string) led.Pattern {
+	for _, service := range C.Services {
+		if service.serves(unit) {
+			if style, ok := service.Colours[state]; ok && style.Period > 0 {
+				return style.pattern()
+			}
+		}
+	}
+	// A required unit that is missing always blinks, as an error.
+	if !C.Accessibility.Patterns && state != "missing" {
+		return led.Pattern{}
+	}
+	return patterns[state]
+}
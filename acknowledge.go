@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+// AcknowledgeConfig is how an acknowledged failure is shown until the unit
+// recovers or fails again: Colour when set, otherwise the failed colour with
+// every channel divided by Dim.
+type AcknowledgeConfig struct {
+	Colour string
+	Dim    uint64
+}
+
+func (a AcknowledgeConfig) overlay(colour string) string {
+	if a.Colour != "" {
+		return a.Colour
+	}
+	return dimColour(colour, a.Dim)
+}
+
+func acknowledgeWorst(strips []*strip.Strip) error {
+	pixel := worstFailure(strips)
+	if pixel == nil {
+		return errors.New("no unacknowledged failure")
+	}
+	return acknowledgeUnit(strips, pixel.Unit)
+}
+
+// acknowledgeUnit marks the failure of unit as known.
+func acknowledgeUnit(strips []*strip.Strip, unit string) error {
+	for _, pixel := range pixelsOf(strips) {
+		if pixel.Unit != unit {
+			continue
+		}
+		if pixel.Status != "failed" {
+			return fmt.Errorf("%s has not failed", unit)
+		}
+		logr.Info("Failure acknowledged", zap.String("unit", unit))
+		pixel.SetAcknowledged(true)
+		setState(pixel, pixel.Status)
+		return nil
+	}
+	return fmt.Errorf("%s is not on a strip", unit)
+}
+
+func unacknowledgeUnit(strips []*strip.Strip, unit string) error {
+	for _, pixel := range pixelsOf(strips) {
+		if pixel.Unit == unit {
+			pixel.SetAcknowledged(false)
+			setState(pixel, pixel.Status)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not on a strip", unit)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
+)
+
+// ButtonConfig binds actions to a push-button wired between Pin and ground.
+type ButtonConfig struct {
+	Pin         string
+	LongPress   time.Duration `mapstructure:"long_press"`
+	ShortAction string        `mapstructure:"short_action"`
+	LongAction  string        `mapstructure:"long_action"`
+}
+
+// actions are the things an input can be bound to.
+var actions = map[string]func([]*strip.Strip) error{
+	"none":           func([]*strip.Strip) error { return nil },
+	"wake":           func([]*strip.Strip) error { return nil },
+	"cycle-profile":  cycleProfile,
+	"acknowledge":    acknowledgeWorst,
+	"restart-failed": restartFailed,
+}
+
+// unitActions are actions on a named unit, for the control socket.
+var unitActions = map[string]func([]*strip.Strip, string) error{
+	"acknowledge":   acknowledgeUnit,
+	"unacknowledge": unacknowledgeUnit,
+}
+
+func (b ButtonConfig) Validate() error {
+	for _, action := range []string{b.ShortAction, b.LongAction} {
+		if _, ok := actions[action]; !ok {
+			return fmt.Errorf("button: unknown action %q", action)
+		}
+	}
+	return nil
+}
+
+// worstFailure returns the lowest numbered failed pixel that has not been
+// acknowledged yet.
+func worstFailure(strips []*strip.Strip) *led.Led {
+	var worst *led.Led
+	for _, pixel := range pixelsOf(strips) {
+		if pixel.Status != "failed" || pixel.Acknowledged {
+			continue
+		}
+		if worst == nil || pixel.Number < worst.Number {
+			worst = pixel
+		}
+	}
+	return worst
+}
+
+// restartFailed restarts the worst failed unit, showing it as activating
+// while the job runs. It returns once systemd has queued the job.
+func restartFailed([]*strip.Strip) error {
+	var pixel *led.Led
+	for _, p := range tracked {
+		if p.Status == "failed" {
+			pixel = p
+			break
+		}
+	}
+	if pixel == nil {
+		return errors.New("no failed unit")
+	}
+	if sysd == nil {
+		return errors.New("restarting units needs the dbus transport")
+	}
+	done := make(chan string, 1)
+	ctx, cancel := C.Systemd.context()
+	defer cancel()
+	err := monitor.Traced("RestartUnit", pixel.Unit, func() error {
+		_, err := sysd.RestartUnitContext(ctx, pixel.Unit, "replace", done)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	logr.Info("Restarting failed unit", zap.String("unit", pixel.Unit))
+	setState(pixel, "activating")
+	go awaitRestart(pixel.Unit, done)
+	return nil
+}
+
+// restartWait is how long awaitRestart waits for a restart job to finish.
+const restartWait = 5 * time.Minute
+
+// awaitRestart logs how the restart job of unit ends, off the event and
+// control paths: the unit's own events show the outcome on its pixel.
+func awaitRestart(unit string, done <-chan string) {
+	select {
+	case result := <-done:
+		if result != "done" {
+			logr.Error("Restart job failed", zap.String("unit", unit), zap.String("result", result))
+			return
+		}
+		logr.Info("Restart job finished", zap.String("unit", unit), zap.String("result", result))
+	case <-time.After(restartWait):
+		logr.Warn("Restart job still running, no longer waiting for it", zap.String("unit", unit), zap.Duration("waited", restartWait))
+	}
+}
+
+const debounce = 30 * time.Millisecond
+
+func buttonLoop(strips []*strip.Strip, b ButtonConfig) {
+	pin := gpioreg.ByName(b.Pin)
+	if pin == nil {
+		logr.Error("Unknown button pin", zap.String("pin", b.Pin))
+		return
+	}
+	if err := pin.In(gpio.PullUp, gpio.BothEdges); err != nil {
+		logr.Error("Unable to configure button pin", zap.String("pin", b.Pin), zap.Error(err))
+		return
+	}
+	for pin.WaitForEdge(-1) {
+		if pin.Read() != gpio.Low {
+			continue
+		}
+		pressed := time.Now()
+		action := b.ShortAction
+		if !pin.WaitForEdge(b.LongPress) {
+			action = b.LongAction
+			for pin.Read() == gpio.Low {
+				pin.WaitForEdge(-1)
+			}
+		} else if time.Since(pressed) < debounce {
+			continue
+		}
+		logr.Debug("Button pressed", zap.String("action", action))
+		touch()
+		if err := actions[action](strips); err != nil {
+			logr.Info("Button action", zap.String("action", action), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/shift/systemd-status-leds/strip"
+	"github.com/spf13/viper"
+)
+
+// calibration works out a strip's channels, order and length by lighting
+// raw bytes on it and asking what shows. The display is opened with
+// channels in the order they are sent, so each frame byte is the byte on
+// the wire.
+type calibration struct {
+	display strip.Display
+	ask     func(question string) bool
+	// max is the most pixels the strip may have.
+	max int
+}
+
+// light sends a frame lighting bytes from to to of the wire.
+func (c calibration) light(from, to int) error {
+	frame := make([]byte, 3*((4*c.max+2)/3))
+	for i := from; i < to; i++ {
+		frame[i] = 255
+	}
+	_, err := c.display.Write(frame)
+	return err
+}
+
+// channels asks whether the fourth byte lights the first pixel, as white on
+// RGBW strips, or the second pixel's first channel on RGB strips.
+func (c calibration) channels() (int, error) {
+	if err := c.light(3, 4); err != nil {
+		return 0, err
+	}
+	if c.ask("Is the first pixel lit, rather than the second?") {
+		return 4, nil
+	}
+	return 3, nil
+}
+
+// order asks the colour each of the first pixel's channels shows.
+func (c calibration) order(channels int) (string, error) {
+	left := "rgbw"[:channels]
+	names := map[byte]string{'r': "red", 'g': "green", 'b': "blue", 'w': "white"}
+	var order string
+	for pos := 0; pos < channels; pos++ {
+		if err := c.light(pos, pos+1); err != nil {
+			return "", err
+		}
+		colour := left[len(left)-1]
+		for i := 0; i < len(left)-1; i++ {
+			if c.ask("Is the first pixel " + names[left[i]] + "?") {
+				colour = left[i]
+				break
+			}
+		}
+		order += string(colour)
+		left = strings.Replace(left, string(colour), "", 1)
+	}
+	return order, nil
+}
+
+// length finds the last pixel that lights by bisection.
+func (c calibration) length(channels int) (int, error) {
+	lo, hi := 1, c.max
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if err := c.light((mid-1)*channels, mid*channels); err != nil {
+			return 0, err
+		}
+		if c.ask(fmt.Sprintf("Is a pixel lit? (testing pixel %d)", mid)) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, nil
+}
+
+// balanceRounds bounds how often balance turns a colour down.
+const balanceRounds = 20
+
+// balance shows white on the first pixel, its red, green and blue at full
+// but for their factors, turning down by a tenth whichever colour looks too
+// strong until it looks white.
+func (c calibration) balance(order string) (strip.Balance, error) {
+	factors := map[byte]float64{'r': 1, 'g': 1, 'b': 1}
+	names := map[byte]string{'r': "red", 'g': "green", 'b': "blue"}
+	frame := make([]byte, 3*((4*c.max+2)/3))
+	for round := 0; round < balanceRounds; round++ {
+		for i := 0; i < len(order); i++ {
+			frame[i] = byte(255 * factors[order[i]])
+		}
+		if _, err := c.display.Write(frame); err != nil {
+			return strip.Balance{}, err
+		}
+		if c.ask("Does the first pixel look white?") {
+			break
+		}
+		for _, colour := range []byte("rgb") {
+			if c.ask("Is it too " + names[colour] + "?") {
+				factors[colour] *= 0.9
+				break
+			}
+		}
+	}
+	round := func(f float64) float64 { return math.Round(f*100) / 100 }
+	return strip.Balance{Red: round(factors['r']), Green: round(factors['g']), Blue: round(factors['b'])}, nil
+}
+
+// run calibrates the strip, turning it off at the end.
+func (c calibration) run() (channels int, order string, length int, balance strip.Balance, err error) {
+	defer c.display.Halt()
+	if channels, err = c.channels(); err != nil {
+		return
+	}
+	if order, err = c.order(channels); err != nil {
+		return
+	}
+	if length, err = c.length(channels); err != nil {
+		return
+	}
+	balance, err = c.balance(order)
+	return
+}
+
+// calibrate asks about patterns shown on the configured strip and writes
+// the channels, order, length and white balance found into the config. It returns the
+// process exit status.
+func calibrate(args []string) int {
+	flags := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	path := flags.String("config", "", "configuration file")
+	max := flags.Int("max", 300, "most pixels the strip may have")
+	_ = flags.Parse(args)
+	if err := loadConfig(*path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	backend := C.Strip.Backend
+	if backend == "" {
+		backend = strip.BackendSPI
+	}
+	if backend != strip.BackendSPI && backend != strip.BackendUART && backend != strip.BackendPWM {
+		fmt.Fprintf(os.Stderr, "calibrate needs an addressable strip, not the %s backend\n", backend)
+		return 1
+	}
+	if err := lockDevice(*C.Strip.port()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	hertz := C.Strip.Hertz
+	if hertz == 0 {
+		hertz = strip.DefaultHertz
+	}
+	port, display, err := strip.Backends[backend](strip.Port{
+		Device:   *C.Strip.port(),
+		Length:   (4**max + 2) / 3,
+		Channels: 3,
+		Hertz:    hertz,
+		Order:    "rgb",
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer port.Close()
+
+	c := calibration{display: display, ask: prompt(os.Stdin, os.Stdout), max: *max}
+	channels, order, length, balance, err := c.run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	balanced := fmt.Sprintf("{red: %g, green: %g, blue: %g}", balance.Red, balance.Green, balance.Blue)
+	fmt.Printf("channels: %d, order: %s, length: %d, balance: %s\n", channels, order, length, balanced)
+
+	file := viper.ConfigFileUsed()
+	config, err := os.ReadFile(file)
+	if err == nil {
+		updated := setStripKeys(string(config), [][2]string{
+			{"channels", fmt.Sprint(channels)},
+			{"order", order},
+			{"length", fmt.Sprint(length)},
+			{"balance", balanced},
+		})
+		err = os.WriteFile(file, []byte(updated), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("wrote", file)
+	return 0
+}
+
+// prompt asks yes/no questions on out, reading answers from in until one is
+// y or n.
+func prompt(in io.Reader, out io.Writer) func(string) bool {
+	scanner := bufio.NewScanner(in)
+	return func(question string) bool {
+		for {
+			fmt.Fprintf(out, "%s [y/n] ", question)
+			if !scanner.Scan() {
+				return false
+			}
+			switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+			case "y", "yes":
+				return true
+			case "n", "no":
+				return false
+			}
+		}
+	}
+}
+
+var topLevelKey = regexp.MustCompile(`^[^\s#]`)
+
+// setStripKeys sets keys in the strip section of a YAML config, replacing
+// them where set and adding them after the section's first line otherwise,
+// keeping everything else as written.
+func setStripKeys(config string, keys [][2]string) string {
+	lines := strings.Split(config, "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.TrimRight(line, " ") == "strip:" {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		start = len(lines)
+		if lines[start-1] == "" {
+			start--
+		}
+		lines = append(lines[:start], append([]string{"strip:"}, lines[start:]...)...)
+	}
+	end, indent := len(lines), ""
+	for i := start + 1; i < len(lines); i++ {
+		if topLevelKey.MatchString(lines[i]) {
+			end = i
+			break
+		}
+		if trimmed := strings.TrimLeft(lines[i], " "); trimmed != "" && !strings.HasPrefix(trimmed, "#") && indent == "" {
+			indent = lines[i][:len(lines[i])-len(trimmed)]
+		}
+	}
+	if indent == "" {
+		indent = "    "
+	}
+	for _, kv := range keys {
+		set := indent + kv[0] + ": " + kv[1]
+		found := false
+		for i := start + 1; i < end; i++ {
+			if strings.HasPrefix(lines[i], indent+kv[0]+":") {
+				lines[i], found = set, true
+				break
+			}
+		}
+		if !found {
+			lines = append(lines[:start+1], append([]string{set}, lines[start+1:]...)...)
+			end++
+		}
+	}
+	return strings.Join(lines, "\n")
+}
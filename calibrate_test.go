@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shift/systemd-status-leds/strip"
+	"github.com/spf13/viper"
+)
+
+// model answers calibration questions as a strip of length pixels taking
+// channels in order would show the last frame written.
+type model struct {
+	order  string
+	length int
+	// strength is how bright each colour looks at full, balanced when all
+	// shown look within a tenth of each other.
+	strength map[byte]float64
+	frame    []byte
+}
+
+func (m *model) Write(frame []byte) (int, error) {
+	m.frame = append(m.frame[:0], frame...)
+	return len(frame), nil
+}
+
+func (m *model) Halt() error { return nil }
+
+func (m *model) answer(question string) bool {
+	channels := len(m.order)
+	lit := -1
+	for i, b := range m.frame {
+		if b != 0 {
+			lit = i
+		}
+	}
+	pixel := lit / channels
+	names := map[string]byte{"red": 'r', "green": 'g', "blue": 'b', "white": 'w'}
+	looks := map[byte]float64{}
+	weakest := 1.0
+	for i, b := range m.frame[:channels] {
+		if c := m.order[i]; c != 'w' {
+			looks[c] = float64(b) / 255 * m.strength[c]
+			weakest = min(weakest, looks[c])
+		}
+	}
+	switch {
+	case question == "Does the first pixel look white?":
+		for _, v := range looks {
+			if v > 1.1*weakest {
+				return false
+			}
+		}
+		return true
+	case strings.HasPrefix(question, "Is it too "):
+		colour := names[strings.TrimSuffix(strings.TrimPrefix(question, "Is it too "), "?")]
+		return looks[colour] > 1.1*weakest
+	case strings.HasPrefix(question, "Is the first pixel lit"):
+		return pixel == 0
+	case strings.HasPrefix(question, "Is a pixel lit"):
+		return pixel < m.length
+	default:
+		colour := strings.TrimSuffix(strings.TrimPrefix(question, "Is the first pixel "), "?")
+		return pixel == 0 && m.order[lit%channels] == names[colour]
+	}
+}
+
+func TestCalibration(t *testing.T) {
+	even := map[byte]float64{'r': 1, 'g': 1, 'b': 1}
+	for _, m := range []*model{
+		{order: "grb", length: 300, strength: even},
+		{order: "grbw", length: 37, strength: even},
+		{order: "rgb", length: 1, strength: even},
+		{order: "wbgr", length: 144, strength: even},
+	} {
+		c := calibration{display: m, ask: m.answer, max: 300}
+		channels, order, length, balance, err := c.run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if channels != len(m.order) || order != m.order || length != m.length {
+			t.Errorf("calibrated %d %s %d, want %d %s %d", channels, order, length, len(m.order), m.order, m.length)
+		}
+		if balance != (strip.Balance{Red: 1, Green: 1, Blue: 1}) {
+			t.Errorf("balanced an even strip to %+v", balance)
+		}
+	}
+}
+
+func TestCalibrateBalance(t *testing.T) {
+	m := &model{order: "grb", length: 8, strength: map[byte]float64{'r': 1, 'g': 0.9, 'b': 0.6}}
+	c := calibration{display: m, ask: m.answer, max: 8}
+	balance, err := c.balance(m.order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Blue != 1 || balance.Red > 0.66 || balance.Green > 0.73 {
+		t.Errorf("balance = %+v, want red and green turned down to weak blue", balance)
+	}
+	if !m.answer("Does the first pixel look white?") {
+		t.Error("the last frame shown does not look white")
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	var out strings.Builder
+	ask := prompt(strings.NewReader("maybe\nY\nno\n"), &out)
+	if !ask("Lit?") || ask("Lit?") {
+		t.Error("answers not read as yes then no")
+	}
+	if strings.Count(out.String(), "Lit? [y/n]") != 3 {
+		t.Errorf("asked %q, want the unclear answer asked again", out.String())
+	}
+}
+
+func TestSetStripKeys(t *testing.T) {
+	config := setStripKeys(exampleConfig, [][2]string{{"channels", "3"}, {"order", "grb"}, {"length", "60"}})
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Strip.Channels != 3 || c.Strip.Order != "grb" || c.Strip.Length != 60 || c.Strip.Spidev != "0.0" {
+		t.Errorf("strip = %+v", c.Strip)
+	}
+	if !strings.Contains(config, "# 3 for RGB strips, 4 for RGBW.") {
+		t.Error("comments lost")
+	}
+	if got := setStripKeys("version: 3\n", [][2]string{{"length", "8"}}); got != "version: 3\nstrip:\n    length: 8\n" {
+		t.Errorf("added strip section = %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+// ClockConfig shows the time on the pixels of Segment: in binary, hours then
+// minutes then, with room, seconds, most significant bit first; or as a
+// seconds heartbeat, one Colour pixel stepping along the segment each second.
+// Off, dark unless set, is shown for the unlit bits.
+type ClockConfig struct {
+	Segment string
+	Mode    string
+	Colour  string
+	Off     string
+}
+
+// binaryClockBits are the pixels needed for hours and minutes.
+const binaryClockBits = 5 + 6
+
+func (c ClockConfig) Validate() error {
+	if c.Segment == "" {
+		return nil
+	}
+	switch c.Mode {
+	case "binary", "seconds":
+	default:
+		return fmt.Errorf("clock.mode must be binary or seconds, got %q", c.Mode)
+	}
+	if err := checkColour("clock.colour", c.Colour); err != nil {
+		return err
+	}
+	if c.Off != "" {
+		return checkColour("clock.off", c.Off)
+	}
+	return nil
+}
+
+// clockBits is what each of n pixels shows at t.
+func (c ClockConfig) clockBits(t time.Time, n int) []bool {
+	bits := make([]bool, n)
+	if c.Mode == "seconds" {
+		if n > 0 {
+			bits[t.Second()%n] = true
+		}
+		return bits
+	}
+	i := 0
+	put := func(v, width int) {
+		for b := width - 1; b >= 0 && i < n; b-- {
+			bits[i] = v>>b&1 == 1
+			i++
+		}
+	}
+	put(t.Hour(), 5)
+	put(t.Minute(), 6)
+	if n >= binaryClockBits+6 {
+		put(t.Second(), 6)
+	}
+	return bits
+}
+
+func clockLoop(pixels []*led.Led, c ClockConfig) {
+	for now := range time.Tick(time.Second) {
+		c.show(pixels, now)
+	}
+}
+
+// show sets pixels to the time at now.
+func (c ClockConfig) show(pixels []*led.Led, now time.Time) {
+	off := c.Off
+	if off == "" {
+		off = "00000000"
+	}
+	for i, on := range c.clockBits(now, len(pixels)) {
+		if on {
+			setColour(pixels[i], c.Colour)
+		} else {
+			setColour(pixels[i], off)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"go.uber.org/zap"
+)
+
+func TestClockBits(t *testing.T) {
+	at := time.Date(2024, 1, 1, 13, 37, 42, 0, time.UTC)
+	show := func(bits []bool) string {
+		s := ""
+		for _, b := range bits {
+			if b {
+				s += "1"
+			} else {
+				s += "0"
+			}
+		}
+		return s
+	}
+	binary := ClockConfig{Mode: "binary"}
+	if got := show(binary.clockBits(at, 11)); got != "01101"+"100101" {
+		t.Errorf("13:37 = %s", got)
+	}
+	if got := show(binary.clockBits(at, 17)); got != "01101"+"100101"+"101010" {
+		t.Errorf("13:37:42 = %s", got)
+	}
+	seconds := ClockConfig{Mode: "seconds"}
+	if got := show(seconds.clockBits(at, 5)); got != "00100" {
+		t.Errorf("second 42 of 5 pixels = %s", got)
+	}
+}
+
+func TestClockTurnsBitsOff(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	pixels := make([]*led.Led, 5)
+	for i := range pixels {
+		pixels[i] = &led.Led{}
+	}
+	c := ClockConfig{Mode: "seconds", Colour: "00ff0000"}
+	at := time.Date(2024, 1, 1, 13, 37, 42, 0, time.UTC)
+	c.show(pixels, at)
+	if pixels[2].Colour != "00ff0000" {
+		t.Fatalf("second 42 lit %s, want 00ff0000", pixels[2].Colour)
+	}
+	c.show(pixels, at.Add(time.Second))
+	if pixels[2].Colour != "00000000" {
+		t.Errorf("second 43 left pixel 2 %s, want it off", pixels[2].Colour)
+	}
+	if pixels[3].Colour != "00ff0000" {
+		t.Errorf("second 43 lit %s, want 00ff0000", pixels[3].Colour)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/rule"
+)
+
+// Condition is a boolean combination of unit states. Exactly one of Unit
+// (in State, active by default), All, Any or Not is set.
+type Condition struct {
+	Unit  string
+	State string
+	All   []Condition
+	Any   []Condition
+	Not   *Condition
+}
+
+// expr compiles the condition into a rule expression.
+func (c Condition) expr() (rule.Expr, error) {
+	set := 0
+	for _, ok := range []bool{c.Unit != "", len(c.All) > 0, len(c.Any) > 0, c.Not != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("needs exactly one of unit, all, any or not")
+	}
+	switch {
+	case c.Unit != "":
+		state := c.State
+		if state == "" {
+			state = "active"
+		}
+		if !knownState(state) {
+			return nil, fmt.Errorf("%s: unknown state %q", c.Unit, state)
+		}
+		return rule.Is(c.Unit, state), nil
+	case c.Not != nil:
+		x, err := c.Not.expr()
+		return rule.Not(x), err
+	}
+	combine, conditions := rule.All, c.All
+	if len(c.Any) > 0 {
+		combine, conditions = rule.Any, c.Any
+	}
+	xs := make([]rule.Expr, len(conditions))
+	for i, c := range conditions {
+		x, err := c.expr()
+		if err != nil {
+			return nil, err
+		}
+		xs[i] = x
+	}
+	return combine(xs...), nil
+}
+
+// Composite shows on Pixel whether When holds, as Name in the active state
+// while it does and in the failed state otherwise.
+type Composite struct {
+	Name  string
+	Pixel int
+	When  Condition
+}
+
+func (c Composite) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("composites: pixel %d needs a name", c.Pixel)
+	}
+	if _, err := c.When.expr(); err != nil {
+		return fmt.Errorf("composites: %s: %v", c.Name, err)
+	}
+	return nil
+}
+
+// addComposites turns the composites into rules on their reserved pixels.
+func addComposites(cs []Composite, pixels map[string]*led.Led) {
+	for _, c := range cs {
+		e, _ := c.When.expr()
+		rules = append(rules, compiledRule{when: e, pixel: pixels[c.Name], composite: true})
+		trackUnits(e.Units())
+	}
+	applyRules()
+}
@@ -0,0 +1,676 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/shift/systemd-status-leds/ease"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+type Service struct {
+	Unit    string `mapstructure:"name"`
+	Colours map[string]StateStyle
+	Segment string
+
+	MinDisplay time.Duration `mapstructure:"min_display"`
+
+	// Units, when set, are shown together on this pixel as Aggregate: worst,
+	// all-active or quorum (of Quorum units active).
+	Units     []string
+	Aggregate string
+	Quorum    int
+	// Instances shows the instances of a template Unit, such as
+	// getty@.service, as they come and go: aggregate, the default, on one
+	// pixel as Aggregate, or spread one to a pixel over Segment.
+	Instances string
+	// FullAt, for a slice, shows the number of units active in it as the
+	// brightness of its active colour, full at FullAt units.
+	FullAt int `mapstructure:"full_at"`
+
+	StatusText []StatusMatch `mapstructure:"status_text"`
+	// Watchdog checks that the service pings its WatchdogSec= in time.
+	Watchdog bool
+	// Interval is how often the status text, watchdog and slice of the
+	// service are checked, the monitor's 30s unless set.
+	Interval time.Duration
+	// Strip names the strip the service is shown on, the main one unless
+	// set.
+	Strip string
+	// Source is where the states come from: systemd when empty, external
+	// for events read through External, or file for File.
+	Source string
+	File   FileCheck
+	// Optional units are expected to be absent on some hosts, and shown
+	// quietly as absent rather than as missing when they are.
+	Optional bool
+}
+
+// StateStyle is how a service is shown in one state: its Colour, blinking
+// with Period and Duty if Period is set.
+type StateStyle struct {
+	Colour string
+	Period time.Duration
+	Duty   float64
+}
+
+func (s StateStyle) pattern() led.Pattern {
+	return led.Pattern{Period: s.Period, Duty: s.Duty}
+}
+
+func (s Service) validateColours() []error {
+	var errs []error
+	for state, style := range s.Colours {
+		where := s.Unit + ".colours." + state
+		if !knownState(state) {
+			errs = append(errs, fmt.Errorf("%s: unknown state %q", s.Unit+".colours", state))
+		}
+		if style.Colour != "" {
+			if err := checkColour(where, style.Colour); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if style.Period < 0 || style.Duty < 0 || style.Duty > 1 {
+			errs = append(errs, fmt.Errorf("%s: needs a positive period and a duty between 0 and 1", where))
+		}
+	}
+	return errs
+}
+
+type Config struct {
+	Version  int
+	Services []Service       `mapstructure:"services"`
+	Segments []strip.Segment `mapstructure:"segments"`
+	Strip    StripConfig
+	// Strips are further strips, on their own buses, that services can be
+	// placed on by name.
+	Strips   []StripConfig
+	Thermal  ThermalConfig
+	Sleep    SleepConfig
+	Button   ButtonConfig
+	Control  ControlConfig
+	External ExternalConfig
+	HTTP     HTTPConfig
+	Peer     PeerConfig
+	Systemd  SystemdConfig
+
+	Acknowledge AcknowledgeConfig
+	StateFile   string `mapstructure:"state_file"`
+	ExportFile  string `mapstructure:"export_file"`
+	History     HistoryConfig
+	// LockDir holds the lock of each strip's device, so a second instance
+	// can't drive it too.
+	LockDir   string `mapstructure:"lock_dir"`
+	Heartbeat HeartbeatConfig
+	OTLP      OTLPConfig
+	Log       LogConfig
+
+	Accessibility AccessibilityConfig
+	FlashLimit    strip.FlashLimit `mapstructure:"flash_limit"`
+	Theme         string
+	Flash         FlashConfig
+	OnFailure     OnFailureConfig `mapstructure:"on_failure"`
+	Escalation    []EscalationStep
+	Decay         DecayConfig
+	SelfTest      SelfTestConfig `mapstructure:"self_test"`
+	Clock         ClockConfig
+	Timesync      TimesyncConfig
+	Rules         []Rule
+	Composites    []Composite
+	Notifiers     []NotifierConfig
+	// ProblemsOnly leaves the pixels of active units dark, lighting only
+	// those with a problem.
+	ProblemsOnly bool            `mapstructure:"problems_only"`
+	PowerSave    PowerSaveConfig `mapstructure:"power_save"`
+	// Severity lists states from worst to best, ahead of the others in
+	// their usual order, for aggregating several units onto a pixel.
+	Severity   []string
+	MinDisplay time.Duration `mapstructure:"min_display"`
+	// Coalesce lets a pixel change state at most once per window, showing
+	// the last of a burst of changes when it ends.
+	Coalesce time.Duration
+
+	Profiles        []Profile
+	Profile         string
+	ProfileSchedule []ProfileSchedule `mapstructure:"profile_schedule"`
+}
+
+type StripConfig struct {
+	// Name identifies the strip to services and in metrics.
+	Name string
+	// Type is the LED part, setting Hertz, Channels and Order unless they
+	// are set themselves.
+	Type     string
+	Length   int
+	Channels int
+	Hertz    int
+	Order    string
+	// Backend drives the strip: spi or tlc5947 from Spidev, uart from the
+	// serial port at Device, pwm from the GPIO pin named by Device,
+	// sensehat from the framebuffer at Device, found if that is empty, or
+	// hub75 from the Flaschen Taschen server at Device, localhost if empty,
+	// or hid from the BlinkStick or blink(1) with serial Device, the first
+	// found if empty.
+	Backend string
+	Spidev  string
+	Device  string
+	// Panel is the size of a hub75 matrix, 64x32 unless set.
+	Panel   strip.Panel
+	Colours map[string]string
+
+	MaxMilliamps        int `mapstructure:"max_milliamps"`
+	MilliampsPerChannel int `mapstructure:"milliamps_per_channel"`
+	IdleMilliamps       int `mapstructure:"idle_milliamps"`
+
+	Interval time.Duration
+	Dither   bool
+	Reverse  bool
+	Offset   int
+	Rotate   time.Duration
+	// RotateAnchor, RFC 3339, is when rotation periods are counted from.
+	RotateAnchor string `mapstructure:"rotate_anchor"`
+	Background   strip.Background
+	// Balance turns down the stronger colours so white looks white.
+	Balance strip.Balance
+	// Realtime renders and writes the strip at a realtime priority on
+	// pinned CPUs.
+	Realtime strip.Realtime
+
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	ReopenAfter  int           `mapstructure:"reopen_after"`
+	// LatencyOutlier is how much later than expected a change or frame
+	// may reach the LEDs before it is logged.
+	LatencyOutlier time.Duration `mapstructure:"latency_outlier"`
+	Hotplug        bool
+}
+
+func (s StripConfig) Opts() strip.Opts {
+	opts := strip.Opts{
+		Name:     s.Name,
+		Power:    s.Power(),
+		Interval: s.Interval,
+		Dither:   s.Dither,
+		Reverse:  s.Reverse,
+		Offset:   s.Offset,
+		Rotate:   s.Rotate,
+
+		Background: s.Background,
+
+		WriteTimeout: s.WriteTimeout,
+		ReopenAfter:  s.ReopenAfter,
+
+		LatencyOutlier: s.LatencyOutlier,
+		Hotplug:        s.Hotplug,
+		Order:          s.Order,
+		Backend:        s.Backend,
+		Panel:          s.Panel,
+		Balance:        s.Balance,
+		FlashLimit:     C.FlashLimit,
+		Realtime:       s.Realtime,
+	}
+	// validated by Config.Validate
+	opts.RotateAnchor, _ = time.Parse(time.RFC3339, s.RotateAnchor)
+	return opts
+}
+
+// onSPI is whether the strip's backend opens Spidev rather than Device.
+func (s StripConfig) onSPI() bool {
+	return s.Backend == "" || s.Backend == strip.BackendSPI || s.Backend == strip.BackendTLC5947
+}
+
+// port is the device the strip's backend opens.
+func (s *StripConfig) port() *string {
+	if s.onSPI() {
+		return &s.Spidev
+	}
+	return &s.Device
+}
+
+// channels checks the strip's channels suit its backend, returning the
+// counts allowed.
+func (s StripConfig) channels() (bool, string) {
+	if s.Backend == strip.BackendTLC5947 {
+		return s.Channels == 1 || s.Channels == 3, "1 or 3"
+	}
+	return s.Channels == 3 || s.Channels == 4, "3 or 4"
+}
+
+// applyPart fills in what the strip's Type implies and was not set. A type
+// naming a backend, such as uart or tlc5947, picks it.
+func (s *StripConfig) applyPart() {
+	if _, ok := strip.Backends[s.Type]; ok && s.Backend == "" {
+		s.Backend = s.Type
+	}
+	part, ok := strip.Parts[s.Type]
+	if !ok {
+		return
+	}
+	if s.Hertz == 0 {
+		s.Hertz = part.Hertz
+	}
+	if s.Channels == 0 {
+		s.Channels = part.Channels
+	}
+	if s.Order == "" {
+		s.Order = part.Order
+	}
+}
+
+// validatePart checks the strip's Type, Order and Backend.
+func (s StripConfig) validatePart(where string) []error {
+	var errs []error
+	_, part := strip.Parts[s.Type]
+	if _, backend := strip.Backends[s.Type]; s.Type != "" && !part && !backend {
+		errs = append(errs, fmt.Errorf("%s: unknown type %q", where, s.Type))
+	}
+	if _, ok := strip.Backends[s.Backend]; s.Backend != "" && !ok {
+		errs = append(errs, fmt.Errorf("%s: unknown backend %q", where, s.Backend))
+	}
+	if !s.onSPI() && s.Device == "" && (s.Backend == strip.BackendUART || s.Backend == strip.BackendPWM) {
+		errs = append(errs, fmt.Errorf("%s: the %s backend needs a device", where, s.Backend))
+	}
+	if s.Backend == strip.BackendSenseHAT && s.Length > strip.SenseHATPixels {
+		errs = append(errs, fmt.Errorf("%s: the Sense HAT has %d pixels, length is %d", where, strip.SenseHATPixels, s.Length))
+	}
+	if s.Backend == strip.BackendHUB75 {
+		panel := s.Panel
+		if panel.Width == 0 && panel.Height == 0 {
+			panel = strip.DefaultPanel
+		}
+		if _, _, err := panel.Grid(s.Length); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", where, err))
+		}
+	}
+	if err := s.Balance.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", where, err))
+	}
+	if err := s.Realtime.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", where, err))
+	}
+	if s.Order != "" {
+		if err := strip.ValidateOrder(s.Order, s.Channels); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", where, err))
+		}
+	}
+	return errs
+}
+
+func (s StripConfig) Power() strip.Power {
+	return strip.Power{
+		MaxMilliamps:        s.MaxMilliamps,
+		MilliampsPerChannel: s.MilliampsPerChannel,
+		IdleMilliamps:       s.IdleMilliamps,
+	}
+}
+
+func setDefaults() {
+	viper.SetDefault("strip.name", "main")
+	viper.SetDefault("strip.write_timeout", "1s")
+	viper.SetDefault("strip.reopen_after", 5)
+	viper.SetDefault("strip.hotplug", true)
+	viper.SetDefault("strip.milliamps_per_channel", strip.DefaultMilliampsPerChannel)
+	viper.SetDefault("strip.idle_milliamps", strip.DefaultIdleMilliamps)
+	viper.SetDefault("thermal.sensor", "/sys/class/thermal/thermal_zone0/temp")
+	viper.SetDefault("thermal.interval", "30s")
+	viper.SetDefault("thermal.hysteresis", 5)
+	viper.SetDefault("sleep.fade", "5s")
+	viper.SetDefault("sleep.easing", "linear")
+	viper.SetDefault("decay.easing", "linear")
+	viper.SetDefault("heartbeat.easing", "ease-in-out")
+	viper.SetDefault("control.socket", "/run/systemd-status-leds.sock")
+	viper.SetDefault("theme", "classic")
+	viper.SetDefault("flash.colour", "ffffffff")
+	viper.SetDefault("on_failure.colour", "ff00ff00")
+	viper.SetDefault("on_failure.window", "30s")
+	viper.SetDefault("self_test.step", "20ms")
+	viper.SetDefault("clock.mode", "binary")
+	viper.SetDefault("clock.off", "00000000")
+	viper.SetDefault("timesync.interval", "30s")
+	viper.SetDefault("log.summary", "5m")
+	viper.SetDefault("otlp.interval", "30s")
+	viper.SetDefault("otlp.sample", 0.01)
+	viper.SetDefault("acknowledge.dim", 4)
+	viper.SetDefault("heartbeat.colour", "00001000")
+	viper.SetDefault("heartbeat.error_colour", "ff000000")
+	viper.SetDefault("heartbeat.period", "2s")
+	viper.SetDefault("button.long_press", "1s")
+	viper.SetDefault("button.short_action", "cycle-profile")
+	viper.SetDefault("button.long_action", "acknowledge")
+	viper.SetDefault("flash_limit.hertz", strip.DefaultFlashLimit.Hertz)
+	viper.SetDefault("flash_limit.delta", strip.DefaultFlashLimit.Delta)
+	viper.SetDefault("lock_dir", "/run/lock")
+	viper.SetDefault("history.retention", "720h")
+	viper.SetDefault("power_save.sweep", "3s")
+	viper.SetDefault("history.uptime.window", "24h")
+	viper.SetDefault("history.uptime.target", 90)
+	viper.SetDefault("systemd.poll", "5s")
+	viper.SetDefault("systemd.timeout", monitor.DefaultTimeout.String())
+	viper.SetDefault("systemd.varlink", monitor.DefaultVarlink)
+}
+
+// configWarnings holds the deprecations found by the last loadConfig.
+var configWarnings []string
+
+// loadConfig reads the config file at path, or ./config when path is empty,
+// into C.
+func loadConfig(path string) error {
+	viper.SetConfigType("yaml")
+	if path != "" {
+		viper.SetConfigFile(path)
+	} else {
+		viper.SetConfigName("config")
+		viper.AddConfigPath(".")
+	}
+	setDefaults()
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+	var err error
+	if configWarnings, err = loadSettings(); err != nil {
+		return err
+	}
+	if err := viper.Unmarshal(&C); err != nil {
+		return err
+	}
+	if err := loadCredentials(&C); err != nil {
+		return err
+	}
+	C.Strip.applyPart()
+	for i := range C.Strips {
+		C.Strips[i].applyPart()
+	}
+	sort.Slice(C.Thermal.Thresholds, func(i, j int) bool {
+		return C.Thermal.Thresholds[i].Celsius < C.Thermal.Thresholds[j].Celsius
+	})
+	return nil
+}
+
+var colourPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}$`)
+
+func checkColour(where string, colour string) error {
+	if !colourPattern.MatchString(colour) {
+		return fmt.Errorf("%s: %q is not an eight digit hex colour", where, colour)
+	}
+	return nil
+}
+
+func checkColours(where string, colours map[string]string) []error {
+	var errs []error
+	for state, colour := range colours {
+		if !knownState(state) {
+			errs = append(errs, fmt.Errorf("%s: unknown state %q", where, state))
+		}
+		if err := checkColour(where+"."+state, colour); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Validate returns every problem found in the configuration.
+func (c *Config) Validate() []error {
+	var errs []error
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.Strip.Length < 1 {
+		add(fmt.Errorf("strip.length must be positive, got %d", c.Strip.Length))
+	}
+	if ok, want := c.Strip.channels(); !ok {
+		add(fmt.Errorf("strip.channels must be %s, got %d", want, c.Strip.Channels))
+	}
+	add(c.Strip.Power().Validate(c.Strip.Length))
+	if bg := c.Strip.Background; bg.Mode != "" {
+		add(bg.Validate())
+		add(checkColour("strip.background.colour", bg.Colour))
+		if bg.Mode == "gradient" {
+			add(checkColour("strip.background.to", bg.To))
+		}
+	}
+	if c.Strip.RotateAnchor != "" {
+		if _, err := time.Parse(time.RFC3339, c.Strip.RotateAnchor); err != nil {
+			add(fmt.Errorf("strip.rotate_anchor: %v", err))
+		}
+	}
+	errs = append(errs, checkColours("strip.colours", c.Strip.Colours)...)
+	if err := strip.ValidateHertz(c.Strip.Hertz); c.Strip.Hertz != 0 && err != nil {
+		add(fmt.Errorf("strip: %v", err))
+	}
+	errs = append(errs, c.Strip.validatePart("strip")...)
+	if c.Strip.WriteTimeout < 0 || c.Strip.ReopenAfter < 0 || c.Strip.LatencyOutlier < 0 {
+		add(fmt.Errorf("strip.write_timeout, strip.reopen_after and strip.latency_outlier must not be negative"))
+	}
+	add(c.Systemd.Validate())
+	if c.Coalesce < 0 || c.MinDisplay < 0 {
+		add(fmt.Errorf("coalesce and min_display must not be negative"))
+	}
+	names := map[string]bool{c.Strip.Name: true}
+	for _, extra := range c.Strips {
+		if extra.Name == "" || names[extra.Name] {
+			add(fmt.Errorf("strips: every strip needs a unique name, got %q", extra.Name))
+		}
+		names[extra.Name] = true
+		if *extra.port() == "" {
+			add(fmt.Errorf("strips: %s: needs a spidev or device", extra.Name))
+		}
+		if extra.Length < 1 {
+			add(fmt.Errorf("strips: %s: length must be positive, got %d", extra.Name, extra.Length))
+		}
+		if ok, want := extra.channels(); !ok {
+			add(fmt.Errorf("strips: %s: channels must be %s, got %d", extra.Name, want, extra.Channels))
+		}
+		if err := strip.ValidateHertz(extra.Hertz); extra.Hertz != 0 && err != nil {
+			add(fmt.Errorf("strips: %s: %v", extra.Name, err))
+		}
+		errs = append(errs, extra.validatePart("strips: "+extra.Name)...)
+	}
+
+	if len(c.Thermal.Thresholds) > 0 && c.Thermal.Interval <= 0 {
+		add(fmt.Errorf("thermal.interval must be positive"))
+	}
+	for _, service := range c.Services {
+		if service.Strip != "" && !names[service.Strip] {
+			add(fmt.Errorf("%s: unknown strip %q", service.Unit, service.Strip))
+		}
+		add(service.validateGroup())
+		add(service.validateInstances())
+		add(service.validateSlice())
+		if service.Interval < 0 {
+			add(fmt.Errorf("%s: interval must not be negative", service.Unit))
+		}
+		switch service.Source {
+		case "", "external":
+		case "file":
+			add(service.File.Validate(service.Unit))
+		default:
+			add(fmt.Errorf("%s: unknown source %q", service.Unit, service.Source))
+		}
+		errs = append(errs, service.validateStatusText()...)
+		errs = append(errs, service.validateColours()...)
+	}
+	if c.Acknowledge.Colour != "" {
+		add(checkColour("acknowledge.colour", c.Acknowledge.Colour))
+	} else if c.Acknowledge.Dim == 0 {
+		add(fmt.Errorf("acknowledge needs a colour or a dim factor"))
+	}
+	if c.Flash.Duration > 0 {
+		add(checkColour("flash.colour", c.Flash.Colour))
+	}
+	errs = append(errs, validateEscalation(c.Escalation)...)
+	add(c.Decay.Validate())
+	add(c.History.Validate(c))
+	add(c.HTTP.Validate())
+	add(validateSeverity(c.Severity))
+	add(c.PowerSave.Validate())
+	for _, n := range c.Notifiers {
+		add(n.Validate())
+	}
+	if _, err := ease.ByName(c.Sleep.Easing); c.Sleep.Idle > 0 && c.Sleep.Easing != "" && err != nil {
+		add(fmt.Errorf("sleep: %v", err))
+	}
+	add(c.SelfTest.Validate())
+	add(c.Clock.Validate())
+	add(c.Timesync.Validate())
+	for _, r := range c.Rules {
+		add(r.Validate())
+	}
+	for _, composite := range c.Composites {
+		add(composite.Validate())
+	}
+	if c.OnFailure.Window > 0 {
+		add(checkColour("on_failure.colour", c.OnFailure.Colour))
+	}
+	add(validateTheme(c.Theme))
+	add(c.Accessibility.Validate())
+	add(c.FlashLimit.Validate())
+	add(c.Log.Validate())
+	if c.Heartbeat.Pixel > 0 {
+		if c.Heartbeat.Period <= 0 {
+			add(fmt.Errorf("heartbeat.period must be positive"))
+		}
+		add(checkColour("heartbeat.colour", c.Heartbeat.Colour))
+		add(checkColour("heartbeat.error_colour", c.Heartbeat.ErrorColour))
+		if _, err := ease.ByName(c.Heartbeat.Easing); c.Heartbeat.Easing != "" && err != nil {
+			add(fmt.Errorf("heartbeat: %v", err))
+		}
+	}
+	if c.Button.Pin != "" {
+		add(c.Button.Validate())
+	}
+	for _, p := range c.Profiles {
+		errs = append(errs, checkColours("profiles."+p.Name+".colours", p.Colours)...)
+		for unit, colours := range p.Services {
+			errs = append(errs, checkColours("profiles."+p.Name+"."+unit, colours)...)
+		}
+	}
+	if c.Profile != "" && findProfile(c.Profile) == nil {
+		add(fmt.Errorf("profile: unknown profile %q", c.Profile))
+	}
+	for _, entry := range c.ProfileSchedule {
+		if _, err := time.Parse("15:04", entry.At); err != nil {
+			add(fmt.Errorf("profile_schedule: %q is not HH:MM", entry.At))
+		}
+		if findProfile(entry.Profile) == nil {
+			add(fmt.Errorf("profile_schedule: unknown profile %q", entry.Profile))
+		}
+	}
+	for _, seg := range c.Segments {
+		if seg.Background != "" {
+			add(checkColour("segments."+seg.Name+".background", seg.Background))
+		}
+		if seg.Separator != "" {
+			add(checkColour("segments."+seg.Name+".separator", seg.Separator))
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, service := range c.Services {
+		units := service.Units
+		if len(units) == 0 {
+			units = []string{service.Unit}
+		}
+		for _, unit := range units {
+			if seen[unit] {
+				add(fmt.Errorf("%s is mapped more than once", unit))
+			}
+			seen[unit] = true
+		}
+	}
+	if c.Strip.Length > 0 {
+		errs = append(errs, c.checkLayout()...)
+	}
+	return errs
+}
+
+// checkLayout lays the services out on a strip without hardware to find those
+// that don't fit.
+func (c *Config) checkLayout() []error {
+	var errs []error
+	length := c.Strip.Length
+	s := &strip.Strip{Count: &length}
+	for _, seg := range c.Segments {
+		if err := s.AddSegment(seg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.Heartbeat.Pixel > 0 {
+		if _, err := s.Reserve("heartbeat", c.Heartbeat.Pixel); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.Timesync.Pixel > 0 {
+		if _, err := s.Reserve("timesync", c.Timesync.Pixel); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	reserved := map[int]bool{}
+	for _, r := range c.Rules {
+		if !reserved[r.Pixel] {
+			reserved[r.Pixel] = true
+			if _, err := s.Reserve("rule", r.Pixel); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, composite := range c.Composites {
+		if _, err := s.Reserve(composite.Name, composite.Pixel); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.Clock.Segment != "" {
+		pixels, err := s.ReserveSegment("clock", c.Clock.Segment)
+		if err != nil {
+			errs = append(errs, err)
+		} else if c.Clock.Mode == "binary" && len(pixels) < binaryClockBits {
+			errs = append(errs, fmt.Errorf("clock: binary needs %d pixels, segment %q has %d", binaryClockBits, c.Clock.Segment, len(pixels)))
+		}
+	}
+	extras := map[string]*strip.Strip{}
+	for _, extra := range c.Strips {
+		length := extra.Length
+		extras[extra.Name] = &strip.Strip{Count: &length}
+	}
+	for _, service := range c.Services {
+		var err error
+		on := s
+		if extra := extras[service.Strip]; extra != nil {
+			on = extra
+		}
+		switch {
+		case service.Instances == "spread":
+			_, err = on.ReserveSegment(service.Unit, service.Segment)
+		case service.Segment != "":
+			_, err = on.AddTo(service.Unit, service.Segment)
+		default:
+			_, err = on.Add(service.Unit)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", service.Unit, err))
+		}
+	}
+	return errs
+}
+
+// Configuration loads and validates the configuration, panicking on any
+// problem.
+func Configuration(path string) {
+	if err := loadConfig(path); err != nil {
+		logr.Panic("config file", zap.Error(err))
+	}
+	for _, warning := range configWarnings {
+		logr.Warn("config file", zap.String("deprecated", warning))
+	}
+	if errs := C.Validate(); len(errs) > 0 {
+		logr.Panic("config file", zap.Error(errors.Join(errs...)))
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shift/systemd-status-leds/strip"
+)
+
+func TestApplyPart(t *testing.T) {
+	s := StripConfig{Type: "ws2811", Channels: 4, Order: "grbw"}
+	s.applyPart()
+	if s.Hertz != 400_000 || s.Channels != 4 || s.Order != "grbw" {
+		t.Errorf("applyPart() = %+v, want the part's hertz and the explicit channels and order", s)
+	}
+	if errs := (StripConfig{Type: "ws2813"}).validatePart("strip"); len(errs) != 1 {
+		t.Errorf("unknown type: %v", errs)
+	}
+	if errs := (StripConfig{Channels: 3, Order: "grbw"}).validatePart("strip"); len(errs) != 1 {
+		t.Errorf("order too long for the channels: %v", errs)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	s := StripConfig{Type: "uart", Device: "/dev/ttyAMA0"}
+	s.applyPart()
+	if s.Backend != "uart" || *s.port() != "/dev/ttyAMA0" {
+		t.Errorf("applyPart() = %+v, want the uart backend on the device", s)
+	}
+	if errs := s.validatePart("strip"); len(errs) != 0 {
+		t.Errorf("validatePart() = %v", errs)
+	}
+	if errs := (StripConfig{Backend: "uart"}).validatePart("strip"); len(errs) != 1 {
+		t.Errorf("uart without a device: %v", errs)
+	}
+	if s := (StripConfig{Backend: "pwm", Spidev: "0.0", Device: "GPIO18"}); *s.port() != "GPIO18" {
+		t.Errorf("port() = %q, want the pin", *s.port())
+	}
+	tlc := StripConfig{Type: "tlc5947", Spidev: "0.0", Channels: 1}
+	tlc.applyPart()
+	if ok, _ := tlc.channels(); tlc.Backend != "tlc5947" || *tlc.port() != "0.0" || !ok {
+		t.Errorf("applyPart() = %+v, want single channel tlc5947 on the spidev", tlc)
+	}
+	if errs := tlc.validatePart("strip"); len(errs) != 0 {
+		t.Errorf("validatePart() = %v", errs)
+	}
+	if ok, _ := (StripConfig{Channels: 1}).channels(); ok {
+		t.Error("channels() accepted single channel NRZ pixels")
+	}
+	if errs := (StripConfig{Type: "sensehat", Backend: "sensehat", Length: 64}).validatePart("strip"); len(errs) != 0 {
+		t.Errorf("sensehat finds its own device: %v", errs)
+	}
+	if errs := (StripConfig{Backend: "sensehat", Length: 65}).validatePart("strip"); len(errs) != 1 {
+		t.Errorf("sensehat longer than the matrix: %v", errs)
+	}
+	if errs := (StripConfig{Backend: "hub75", Length: 300}).validatePart("strip"); len(errs) != 0 {
+		t.Errorf("hub75 on the default panel: %v", errs)
+	}
+	if errs := (StripConfig{Backend: "hub75", Length: 300, Panel: strip.Panel{Width: 16, Height: 16}}).validatePart("strip"); len(errs) != 1 {
+		t.Errorf("hub75 longer than the panel: %v", errs)
+	}
+	if errs := (StripConfig{Backend: "hid", Length: 8}).validatePart("strip"); len(errs) != 0 {
+		t.Errorf("hid finds the first gadget: %v", errs)
+	}
+	if errs := (StripConfig{Backend: "i2s", Device: "x"}).validatePart("strip"); len(errs) != 1 {
+		t.Errorf("unknown backend: %v", errs)
+	}
+}
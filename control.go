@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+type ControlConfig struct {
+	Socket string
+}
+
+// queries answer the control socket with lines of output rather than acting.
+var queries = map[string]func(io.Writer, []*strip.Strip){
+	"status":   func(w io.Writer, strips []*strip.Strip) { writeStatus(w, pixelsOf(strips)) },
+	"snapshot": func(w io.Writer, strips []*strip.Strip) { writeSnapshot(w, strips[0]) },
+}
+
+// controlLoop serves the control socket. Each connection sends a single line
+// naming an action or query, optionally followed by a unit, and gets back any
+// output followed by "ok" or "error: <reason>".
+func controlLoop(strips []*strip.Strip, c ControlConfig) {
+	_ = os.Remove(c.Socket)
+	l, err := net.Listen("unix", c.Socket)
+	if err != nil {
+		logr.Error("Unable to listen on control socket", zap.String("socket", c.Socket), zap.Error(err))
+		return
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			logr.Error("Control socket accept", zap.Error(err))
+			continue
+		}
+		go handleControl(strips, conn)
+	}
+}
+
+func handleControl(strips []*strip.Strip, conn net.Conn) {
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	name := fields[0]
+	if len(fields) == 1 && queries[name] != nil {
+		queries[name](conn, strips)
+		fmt.Fprintln(conn, "ok")
+		return
+	}
+	run := func() error {
+		switch {
+		case len(fields) == 1 && actions[name] != nil:
+			return actions[name](strips)
+		case len(fields) == 2 && unitActions[name] != nil:
+			return unitActions[name](strips, fields[1])
+		}
+		return fmt.Errorf("unknown action %q", strings.Join(fields, " "))
+	}
+	logr.Info("Control action", zap.Strings("action", fields))
+	touch()
+	if err := run(); err != nil {
+		fmt.Fprintf(conn, "error: %v\n", err)
+		return
+	}
+	fmt.Fprintln(conn, "ok")
+}
+
+// writeStatus writes a tab separated line per pixel showing a state: its unit,
+// pixel, state, when that last changed and how many times it has.
+func writeStatus(w io.Writer, pixels []*led.Led) {
+	for _, pixel := range pixels {
+		pixel.RLock()
+		if pixel.Status != "" {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\n", pixel.Unit, pixel.Number, pixel.Status,
+				pixel.Changed.Format(time.RFC3339), pixel.Transitions)
+		}
+		pixel.RUnlock()
+	}
+}
+
+// ctl sends action to a running daemon and waits for its reply.
+func ctl(socket string, action string) error {
+	return request(socket, action, os.Stdout)
+}
+
+// status prints the state of every unit shown by a running daemon.
+func status(socket string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UNIT\tPIXEL\tSTATE\tCHANGED\tTRANSITIONS")
+	if err := request(socket, "status", w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// request sends action to a running daemon, copying its output to out until
+// the closing "ok" or error.
+func request(socket string, action string, out io.Writer) error {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	fmt.Fprintln(conn, action)
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch reply = strings.TrimSpace(reply); {
+		case reply == "ok":
+			return nil
+		case strings.HasPrefix(reply, "error: "):
+			return fmt.Errorf("%s", strings.TrimPrefix(reply, "error: "))
+		}
+		fmt.Fprintln(out, reply)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+func TestWriteStatus(t *testing.T) {
+	a, b := &led.Led{Unit: "a.service", Number: 1}, &led.Led{Unit: "b.service", Number: 2}
+	a.SetStatus("active")
+	a.SetStatus("failed")
+	a.SetStatus("active")
+	a.Changed = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var out bytes.Buffer
+	writeStatus(&out, []*led.Led{a, b})
+	want := "a.service\t1\tactive\t2024-01-02T03:04:05Z\t2\n"
+	if out.String() != want {
+		t.Errorf("writeStatus() = %q, want %q", out.String(), want)
+	}
+}
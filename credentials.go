@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// credential is the credential name that systemd passed the service, with
+// LoadCredential= or LoadCredentialEncrypted=, in $CREDENTIALS_DIRECTORY,
+// less a trailing newline. It reports false if systemd passed none by that
+// name.
+func credential(name string) (string, bool, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return "", false, nil
+	}
+	if name == "" || strings.ContainsRune(name, '/') {
+		return "", false, fmt.Errorf("invalid credential name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimSuffix(string(data), "\n"), true, nil
+}
+
+// credentialPath is the file of the credential name if systemd passed one,
+// or else name, taken as a path.
+func credentialPath(name string) string {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" || strings.ContainsRune(name, '/') {
+		return name
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return name
+	}
+	return path
+}
+
+// loadCredentials replaces the secrets of the configuration by the
+// credentials named for them, where systemd passed those.
+func loadCredentials(c *Config) error {
+	if c.HTTP.Credential != "" {
+		token, ok, err := credential(c.HTTP.Credential)
+		if err != nil {
+			return fmt.Errorf("http: %v", err)
+		}
+		if ok {
+			c.HTTP.Token = token
+		}
+	}
+	if c.Peer.Credential != "" {
+		token, ok, err := credential(c.Peer.Credential)
+		if err != nil {
+			return fmt.Errorf("peer: %v", err)
+		}
+		if ok {
+			c.Peer.Token = token
+		}
+	}
+	for i := range c.Notifiers {
+		n := &c.Notifiers[i]
+		if n.Credential == "" {
+			continue
+		}
+		secret, ok, err := credential(n.Credential)
+		if err != nil {
+			return fmt.Errorf("notifiers: %s: %v", n.Type, err)
+		}
+		if ok {
+			*n.secret() = secret
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCredentials(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "smtp-password"), []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	c := &Config{Notifiers: []NotifierConfig{
+		{Type: "smtp", Password: "plain", Credential: "smtp-password"},
+		{Type: "slack", URL: "https://hooks.example.com/fallback", Credential: "slack-webhook"},
+		{Type: "ntfy", Token: "tk"},
+	}}
+	if err := loadCredentials(c); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Notifiers[0].Password; got != "s3cret" {
+		t.Errorf("smtp password = %q, want the credential's", got)
+	}
+	if got := c.Notifiers[1].URL; got != "https://hooks.example.com/fallback" {
+		t.Errorf("slack url = %q, want the configured one without a credential", got)
+	}
+	if got := c.Notifiers[2].Token; got != "tk" {
+		t.Errorf("ntfy token = %q, want tk", got)
+	}
+
+	c.Notifiers[0].Credential = "../etc/passwd"
+	if err := loadCredentials(c); err == nil {
+		t.Error("credential name outside the directory accepted")
+	}
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	c.Notifiers[0].Password = "plain"
+	if err := loadCredentials(c); err != nil || c.Notifiers[0].Password != "plain" {
+		t.Errorf("without credentials: %v, password %q", err, c.Notifiers[0].Password)
+	}
+}
+
+func TestCredentialPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "http-key"), []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	for name, want := range map[string]string{
+		"http-key":          filepath.Join(dir, "http-key"),
+		"http-cert":         "http-cert",
+		"/etc/leds/key.pem": "/etc/leds/key.pem",
+	} {
+		if got := credentialPath(name); got != want {
+			t.Errorf("credentialPath(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+// debouncer holds back state changes of a pixel until its current state has
+// been shown for the minimum display time and the coalescing window.
+type debouncer struct {
+	mu      sync.Mutex
+	shown   time.Time
+	pending string
+	timer   *time.Timer
+}
+
+var (
+	debouncersMu sync.Mutex
+	debouncers   = map[*led.Led]*debouncer{}
+)
+
+func debouncerFor(pixel *led.Led) *debouncer {
+	debouncersMu.Lock()
+	defer debouncersMu.Unlock()
+	d, ok := debouncers[pixel]
+	if !ok {
+		d = &debouncer{}
+		debouncers[pixel] = d
+	}
+	return d
+}
+
+func minDisplay(unit string) time.Duration {
+	for _, service := range C.Services {
+		if service.serves(unit) && service.MinDisplay > 0 {
+			return service.MinDisplay
+		}
+	}
+	return C.MinDisplay
+}
+
+// showState is setState for state changes reported by systemd. Each state is
+// shown for at least the minimum display time, only the latest of the changes
+// arriving meanwhile is shown after it. Failures are shown at once, but for
+// C.Coalesce, which holds back every change but the last of a burst.
+func showState(pixel *led.Led, state string) {
+	min := minDisplay(pixel.Unit)
+	if min <= 0 && C.Coalesce <= 0 {
+		setState(pixel, state)
+		return
+	}
+	d := debouncerFor(pixel)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	since := time.Since(d.shown)
+	wait := min - since
+	if state == "failed" {
+		wait = 0
+	}
+	wait = max(wait, C.Coalesce-since)
+	if wait <= 0 && (d.timer == nil || state == "failed") {
+		if d.timer != nil {
+			d.timer.Stop()
+			d.timer = nil
+		}
+		d.shown = time.Now()
+		setState(pixel, state)
+		return
+	}
+	d.pending = state
+	// A failure cuts short the minimum display time of the state before.
+	if state == "failed" && d.timer != nil && d.timer.Stop() {
+		d.timer.Reset(wait)
+	}
+	if d.timer == nil {
+		d.timer = time.AfterFunc(wait, func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.timer = nil
+			d.shown = time.Now()
+			setState(pixel, d.pending)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"go.uber.org/zap"
+)
+
+func TestShowStateCoalesces(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	C = Config{Coalesce: 50 * time.Millisecond}
+	defer func() { C = Config{} }()
+	pixel := &led.Led{}
+	pixel.Unit = "a.service"
+
+	for _, state := range []string{"activating", "active", "failed", "reloading"} {
+		showState(pixel, state)
+	}
+	if pixel.Status != "activating" {
+		t.Errorf("during the burst: %q, want the first state", pixel.Status)
+	}
+	// The pending state is shown holding the debouncer's lock.
+	d := debouncerFor(pixel)
+	status := func() (string, int) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return pixel.Status, pixel.Transitions
+	}
+	deadline := time.Now().Add(time.Second)
+	for s, _ := status(); s != "reloading" && time.Now().Before(deadline); s, _ = status() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s, transitions := status(); s != "reloading" || transitions != 1 {
+		t.Errorf("after the burst: %q after %d transitions, want the last state after one", s, transitions)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shift/systemd-status-leds/ease"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/strip"
+)
+
+// DecayConfig dims active pixels over Over since their last state change,
+// down to Floor percent and shaped by Easing, so recent restarts stand out. A
+// zero Over disables it.
+type DecayConfig struct {
+	Over   time.Duration
+	Floor  float64
+	Easing string
+}
+
+func (d DecayConfig) Validate() error {
+	if d.Over > 0 && (d.Floor < 0 || d.Floor > 100) {
+		return fmt.Errorf("decay.floor must be a percentage, got %v", d.Floor)
+	}
+	if _, err := ease.ByName(d.Easing); d.Over > 0 && d.Easing != "" && err != nil {
+		return fmt.Errorf("decay: %v", err)
+	}
+	return nil
+}
+
+// dim returns how much to dim a pixel that changed age ago, from 0 to
+// 1 - Floor/100.
+func (d DecayConfig) dim(age time.Duration) float64 {
+	shape, err := ease.ByName(d.Easing)
+	if err != nil {
+		shape = ease.Linear
+	}
+	return (1 - d.Floor/100) * shape(float64(age)/float64(d.Over))
+}
+
+func decay(pixel *led.Led, now time.Time, d DecayConfig) {
+	if pixel.Status != "active" {
+		pixel.SetDim(0)
+		return
+	}
+	pixel.SetDim(d.dim(now.Sub(pixel.Changed)))
+}
+
+func decayLoop(strips []*strip.Strip, d DecayConfig) {
+	for now := range time.Tick(time.Second) {
+		for _, pixel := range pixelsOf(strips) {
+			decay(pixel, now, d)
+		}
+	}
+}
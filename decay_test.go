@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+func TestDecay(t *testing.T) {
+	d := DecayConfig{Over: time.Hour, Floor: 40}
+	changed := time.Now()
+	pixel := &led.Led{Status: "active", Changed: changed}
+	for _, tc := range []struct {
+		age  time.Duration
+		want float64
+	}{
+		{0, 0},
+		{30 * time.Minute, 0.3},
+		{time.Hour, 0.6},
+		{48 * time.Hour, 0.6},
+	} {
+		decay(pixel, changed.Add(tc.age), d)
+		if diff := pixel.Dim - tc.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("dim after %s = %v, want %v", tc.age, pixel.Dim, tc.want)
+		}
+	}
+	pixel.Status = "failed"
+	decay(pixel, changed.Add(time.Hour), d)
+	if pixel.Dim != 0 {
+		t.Errorf("failed pixel dimmed by %v", pixel.Dim)
+	}
+}
+
+func TestDecayEasing(t *testing.T) {
+	d := DecayConfig{Over: time.Hour, Floor: 0, Easing: "cubic"}
+	if got := d.dim(15 * time.Minute); got-0.0625 > 1e-9 || got-0.0625 < -1e-9 {
+		t.Errorf("cubic dim after 15m = %v, want 0.0625", got)
+	}
+	if err := (DecayConfig{Over: time.Hour, Easing: "elastic"}).Validate(); err == nil {
+		t.Error("unknown easing accepted")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shift/systemd-status-leds/monitor"
+)
+
+// demoStories are what a unit goes through in the demo, a state each step,
+// all ending up active again.
+var demoStories = [][]monitor.Event{
+	{{State: "reloading", SubState: "reload"}, {State: "active", SubState: "running"}},
+	{{State: "deactivating", SubState: "stop-sigterm"}, {State: "inactive", SubState: "dead", Result: "success"}, {State: "activating", SubState: "start"}, {State: "active", SubState: "running"}},
+	{{State: "failed", SubState: "failed", Result: "exit-code"}, {State: "failed", SubState: "failed", Result: "exit-code"}, {State: "activating", SubState: "auto-restart"}, {State: "active", SubState: "running"}},
+	{{State: "activating", SubState: "auto-restart", Result: "watchdog"}, {State: "active", SubState: "running"}},
+	{{State: "inactive", SubState: "dead", UnitFileState: "masked"}, {State: "inactive", SubState: "dead", UnitFileState: "masked"}, {State: "active", SubState: "running"}},
+	{{State: "inactive", SubState: "dead", UnitFileState: "disabled"}, {State: "active", SubState: "running"}},
+	{{State: "inactive", SubState: "dead", ConditionFailed: true}, {State: "active", SubState: "running"}},
+}
+
+// demoInstances is how many made-up instances of each template the demo shows.
+const demoInstances = 2
+
+// demoPlayer plays the stories on units, a few at a time.
+type demoPlayer struct {
+	units   []monitor.Event
+	rand    *rand.Rand
+	playing map[string][]monitor.Event
+}
+
+// demoUnits are the units shown, with made-up instances of the templates
+// laid out.
+func demoUnits() []monitor.Event {
+	var units []monitor.Event
+	for _, pixel := range tracked {
+		units = append(units, monitor.Event{Unit: pixel.Unit})
+	}
+	for _, service := range C.Services {
+		if templates[service.Unit] == nil {
+			continue
+		}
+		at := strings.IndexByte(service.Unit, '@')
+		for i := 1; i <= demoInstances; i++ {
+			units = append(units, monitor.Event{Unit: fmt.Sprintf("%sdemo%d%s", service.Unit[:at+1], i, service.Unit[at+1:]), Template: service.Unit})
+		}
+	}
+	return units
+}
+
+// step moves every unit playing a story on to its next state, and starts a
+// story on an idle unit now and then. It returns the changes.
+func (d *demoPlayer) step() []monitor.Event {
+	var changes []monitor.Event
+	for _, unit := range d.units {
+		story, ok := d.playing[unit.Unit]
+		if !ok {
+			continue
+		}
+		e := story[0]
+		e.Unit, e.Template = unit.Unit, unit.Template
+		changes = append(changes, e)
+		if len(story) == 1 {
+			delete(d.playing, unit.Unit)
+		} else {
+			d.playing[unit.Unit] = story[1:]
+		}
+	}
+	if len(d.units) > 0 && len(d.playing) < (len(d.units)+2)/3 && d.rand.Intn(2) == 0 {
+		unit := d.units[d.rand.Intn(len(d.units))]
+		if _, ok := d.playing[unit.Unit]; !ok {
+			d.playing[unit.Unit] = demoStories[d.rand.Intn(len(demoStories))]
+		}
+	}
+	return changes
+}
+
+// demoLoop shows every unit active, then plays the stories a step every step.
+func demoLoop(d *demoPlayer, step time.Duration, r monitor.Renderer) {
+	for _, unit := range d.units {
+		r.Render(monitor.Event{Unit: unit.Unit, Template: unit.Template, State: "active", SubState: "running", Initial: true})
+	}
+	for range time.Tick(step) {
+		for _, e := range d.step() {
+			r.Render(e)
+		}
+	}
+}
+
+// demo shows the configuration on the strips, without systemd, playing
+// made-up but realistic state changes on its units, so a setup can be shown
+// off or checked on a machine whose services never change. It runs until
+// stopped and returns the process exit status.
+func demo(args []string) int {
+	flags := flag.NewFlagSet("demo", flag.ExitOnError)
+	path := flags.String("config", "", "configuration file")
+	step := flags.Duration("step", 2*time.Second, "time between state changes")
+	seed := flags.Int64("seed", 0, "seed for the state changes, random unless set")
+	_ = flags.Parse(args)
+
+	if *step <= 0 {
+		fmt.Fprintln(os.Stderr, "--step must be positive")
+		return 2
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	Configuration(*path)
+	configureLimits(C.Log)
+
+	s, extras, err := standalone(connected{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	d := &demoPlayer{units: demoUnits(), rand: rand.New(rand.NewSource(*seed)), playing: map[string][]monitor.Event{}}
+	go demoLoop(d, *step, renderer{})
+	go closeOnStop(s, extras)
+	runStrips(s, extras)
+	return 0
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+func TestDemoStories(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	C = Config{Services: []Service{{Unit: "nginx.service"}, {Unit: "getty@.service"}}}
+	defer func() { C, tracked, templates = Config{}, nil, map[string]*instances{} }()
+	length, channels := 3, 3
+	s, err := strip.New(logr, &strip.Terminal{Out: io.Discard, Channels: channels}, &length, &channels, strip.Opts{Power: strip.Power{MilliampsPerChannel: strip.DefaultMilliampsPerChannel}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout(s, nil)
+	units := demoUnits()
+	if len(units) != 1+demoInstances || units[1].Unit != "getty@demo1.service" || units[1].Template != "getty@.service" {
+		t.Fatalf("units: %+v", units)
+	}
+
+	d := &demoPlayer{units: units, rand: rand.New(rand.NewSource(1)), playing: map[string][]monitor.Event{}}
+	last := map[string]monitor.Event{}
+	for i := 0; i < 500; i++ {
+		for _, e := range d.step() {
+			last[e.Unit] = e
+		}
+	}
+	if len(last) == 0 {
+		t.Fatal("no unit changed state")
+	}
+	for _, e := range last {
+		if _, playing := d.playing[e.Unit]; !playing && e.State != "active" {
+			t.Errorf("%s ended its story %s, want active", e.Unit, e.State)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"periph.io/x/conn/v3/i2c/i2creg"
+	"periph.io/x/conn/v3/spi/spireg"
+	"periph.io/x/host/v3"
+)
+
+// listDevices prints the SPI and I2C ports periph knows about, any of whose
+// names or aliases can be used as the strip's spidev.
+func listDevices() int {
+	if _, err := host.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("SPI:")
+	for _, ref := range spireg.All() {
+		fmt.Printf("  %-12s %s\n", ref.Name, strings.Join(ref.Aliases, ", "))
+	}
+	fmt.Println("I2C:")
+	for _, ref := range i2creg.All() {
+		fmt.Printf("  %-12s %s\n", ref.Name, strings.Join(ref.Aliases, ", "))
+	}
+	return 0
+}
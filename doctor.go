@@ -0,0 +1,258 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+	"golang.org/x/sys/unix"
+)
+
+// spiMaxSpeed is SPI_IOC_RD_MAX_SPEED_HZ from linux/spi/spidev.h.
+const spiMaxSpeed = 0x80046b04
+
+type check struct {
+	name   string
+	run    func() (string, error)
+	remedy string
+}
+
+// spidevPath maps the configured spidev, e.g. "0.0" or "SPI0.0", to its
+// device node.
+func spidevPath(spidev string) string {
+	if strings.HasPrefix(spidev, "/") {
+		return spidev
+	}
+	return "/dev/spidev" + strings.TrimPrefix(strings.ToUpper(spidev), "SPI")
+}
+
+func doctorChecks() []check {
+	var checks []check
+	switch C.Strip.Backend {
+	case strip.BackendUART:
+		checks = uartChecks(C.Strip.Device)
+	case strip.BackendPWM:
+		checks = pinChecks()
+	case strip.BackendSenseHAT:
+		checks = senseHATChecks(C.Strip.Device)
+	case strip.BackendHUB75:
+		// Frames go over UDP, there is nothing local to check.
+	case strip.BackendHID:
+		checks = hidChecks()
+	default:
+		checks = spiChecks(spidevPath(C.Strip.Spidev))
+	}
+	return append(checks, systemdChecks()...)
+}
+
+func spiChecks(dev string) []check {
+	return []check{
+		{
+			name: "spidev exists",
+			run: func() (string, error) {
+				_, err := os.Stat(dev)
+				return dev, err
+			},
+			remedy: "enable SPI, e.g. dtparam=spi=on in /boot/config.txt or a device tree overlay, and reboot",
+		},
+		{
+			name: "spidev writable",
+			run: func() (string, error) {
+				return dev, unix.Access(dev, unix.W_OK)
+			},
+			remedy: "run as root or add the user to the group owning " + dev + " (usually spi)",
+		},
+		groupCheck("spi"),
+		{
+			name: "SPI max speed",
+			run: func() (string, error) {
+				fd, err := unix.Open(dev, unix.O_RDONLY, 0)
+				if err != nil {
+					return "", err
+				}
+				defer unix.Close(fd)
+				hz, err := unix.IoctlGetUint32(fd, spiMaxSpeed)
+				return fmt.Sprintf("%d Hz", hz), err
+			},
+			remedy: "check the spidev driver is bound to " + dev,
+		},
+	}
+}
+
+func uartChecks(dev string) []check {
+	return []check{
+		{
+			name: "serial port exists",
+			run: func() (string, error) {
+				_, err := os.Stat(dev)
+				return dev, err
+			},
+			remedy: "enable the UART, e.g. enable_uart=1 in /boot/config.txt, and stop any console or getty using it",
+		},
+		{
+			name: "serial port writable",
+			run: func() (string, error) {
+				return dev, unix.Access(dev, unix.W_OK)
+			},
+			remedy: "run as root or add the user to the group owning " + dev + " (usually dialout)",
+		},
+		groupCheck("dialout"),
+	}
+}
+
+func pinChecks() []check {
+	return []check{
+		{
+			name: "/dev/mem writable",
+			run: func() (string, error) {
+				return "/dev/mem", unix.Access("/dev/mem", unix.W_OK)
+			},
+			remedy: "run as root, streaming from a GPIO pin drives PWM and DMA through /dev/mem",
+		},
+	}
+}
+
+func senseHATChecks(dev string) []check {
+	return []check{
+		{
+			name: "Sense HAT framebuffer writable",
+			run: func() (string, error) {
+				if dev == "" {
+					var err error
+					if dev, err = strip.SenseHATDevice(); err != nil {
+						return "", err
+					}
+				}
+				return dev, unix.Access(dev, unix.W_OK)
+			},
+			remedy: "load the Sense HAT overlay, dtoverlay=rpi-sense in /boot/config.txt, and run as root or add the user to the video group",
+		},
+	}
+}
+
+func hidChecks() []check {
+	return []check{
+		{
+			name: "hidraw writable",
+			run: func() (string, error) {
+				devs, _ := filepath.Glob("/dev/hidraw*")
+				if len(devs) == 0 {
+					return "", errors.New("no hidraw devices")
+				}
+				for _, dev := range devs {
+					if err := unix.Access(dev, unix.W_OK); err != nil {
+						return dev, err
+					}
+				}
+				return strings.Join(devs, ","), nil
+			},
+			remedy: "plug the gadget in, and run as root or add a udev rule giving the user its hidraw device, e.g. MODE=\"0660\", GROUP=\"plugdev\"",
+		},
+	}
+}
+
+// groupCheck checks the user is root or in group.
+func groupCheck(group string) check {
+	return check{
+		name: "group membership",
+		run: func() (string, error) {
+			u, err := user.Current()
+			if err != nil {
+				return "", err
+			}
+			if u.Uid == "0" {
+				return "root", nil
+			}
+			ids, err := u.GroupIds()
+			if err != nil {
+				return "", err
+			}
+			var names []string
+			for _, id := range ids {
+				if g, err := user.LookupGroupId(id); err == nil {
+					names = append(names, g.Name)
+				}
+			}
+			for _, name := range names {
+				if name == group {
+					return strings.Join(names, ","), nil
+				}
+			}
+			return strings.Join(names, ","), fmt.Errorf("%s is not in the %s group", u.Username, group)
+		},
+		remedy: "usermod -aG " + group + " <user>, then log in again",
+	}
+}
+
+func systemdChecks() []check {
+	if C.Systemd.Transport == "varlink" {
+		return []check{{
+			name: "systemd reachable",
+			run: func() (string, error) {
+				if !(&monitor.Varlink{Path: C.Systemd.Varlink}).Connected() {
+					return "", fmt.Errorf("can't connect to %s", C.Systemd.Varlink)
+				}
+				return "varlink", nil
+			},
+			remedy: "io.systemd.Unit needs systemd 257 or later, and the daemon may need to run as root to connect",
+		}}
+	}
+	return []check{
+		{
+			name: "systemd reachable",
+			run: func() (string, error) {
+				conn, err := C.Systemd.Bus.systemd()
+				if err != nil {
+					return "", err
+				}
+				conn.Close()
+				return "system bus", nil
+			},
+			remedy: "make sure dbus is running and the daemon may talk to the system bus, running as root is simplest",
+		},
+		{
+			name: "Subscribe permitted",
+			run: func() (string, error) {
+				conn, err := C.Systemd.Bus.systemd()
+				if err != nil {
+					return "", err
+				}
+				defer conn.Close()
+				return "", conn.Subscribe()
+			},
+			remedy: "the bus policy must allow calling org.freedesktop.systemd1.Manager.Subscribe, run as root or relax the policy, or changes are only polled every systemd.poll",
+		},
+	}
+}
+
+// doctor diagnoses the environment, printing a remedy for every failed check.
+// It returns the process exit status.
+func doctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	path := flags.String("config", "", "configuration file")
+	_ = flags.Parse(args)
+	if err := loadConfig(*path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status := 0
+	for _, c := range doctorChecks() {
+		detail, err := c.run()
+		if err != nil {
+			status = 1
+			fmt.Printf("FAIL %-20s %v\n     fix: %s\n", c.name, err, c.remedy)
+			continue
+		}
+		fmt.Printf("ok   %-20s %s\n", c.name, detail)
+	}
+	return status
+}
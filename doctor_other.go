@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// doctor only knows how to diagnose Linux hosts.
+func doctor(args []string) int {
+	fmt.Fprintln(os.Stderr, "doctor only runs on Linux")
+	return 1
+}
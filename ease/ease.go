@@ -0,0 +1,94 @@
+// Package ease provides easing functions shaping fades and animations.
+//
+// Each maps progress t, from 0 at the start to 1 at the end, to how far the
+// effect has gone: 0 at the start and 1 at the end, moving between them at
+// its own pace. Progress outside 0 to 1 is clamped.
+package ease
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Func is an easing function.
+type Func func(t float64) float64
+
+func clamp(t float64) float64 {
+	return math.Min(math.Max(t, 0), 1)
+}
+
+// Linear moves at a constant pace.
+func Linear(t float64) float64 {
+	return clamp(t)
+}
+
+// InOut starts and ends slowly, following half a cosine.
+func InOut(t float64) float64 {
+	return (1 - math.Cos(math.Pi*clamp(t))) / 2
+}
+
+// Cubic starts and ends more slowly than InOut, faster in the middle.
+func Cubic(t float64) float64 {
+	t = clamp(t)
+	if t < 0.5 {
+		return 4 * t * t * t
+	}
+	u := 2*t - 2
+	return 1 + u*u*u/2
+}
+
+// Bounce overshoots the end and bounces back to it, like a dropped ball.
+func Bounce(t float64) float64 {
+	const n, d = 7.5625, 2.75
+	t = clamp(t)
+	switch {
+	case t < 1/d:
+		return n * t * t
+	case t < 2/d:
+		t -= 1.5 / d
+		return n*t*t + 0.75
+	case t < 2.5/d:
+		t -= 2.25 / d
+		return n*t*t + 0.9375
+	default:
+		t -= 2.625 / d
+		return n*t*t + 0.984375
+	}
+}
+
+var funcs = map[string]Func{
+	"linear":      Linear,
+	"ease-in-out": InOut,
+	"cubic":       Cubic,
+	"bounce":      Bounce,
+}
+
+// ByName returns the easing function called name in configuration.
+func ByName(name string) (Func, error) {
+	f, ok := funcs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown easing %q, expected one of %v", name, Names())
+	}
+	return f, nil
+}
+
+// Names lists the easing functions by name.
+func Names() []string {
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pulse eases up over the first half of each cycle and back down over the
+// second, for progress t counted in cycles.
+func Pulse(f Func, t float64) float64 {
+	t -= math.Floor(t)
+	if t < 0.5 {
+		return f(2 * t)
+	}
+	return f(2 - 2*t)
+}
@@ -0,0 +1,55 @@
+package ease
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestKeyTimes(t *testing.T) {
+	for name, want := range map[string][5]float64{
+		// t = 0, 0.25, 0.5, 0.75, 1
+		"linear":      {0, 0.25, 0.5, 0.75, 1},
+		"ease-in-out": {0, (1 - math.Sqrt2/2) / 2, 0.5, (1 + math.Sqrt2/2) / 2, 1},
+		"cubic":       {0, 0.0625, 0.5, 0.9375, 1},
+		"bounce":      {0, 0.47265625, 0.765625, 0.97265625, 1},
+	} {
+		f, err := ByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, w := range want {
+			if got := f(float64(i) / 4); !near(got, w) {
+				t.Errorf("%s(%v) = %v, want %v", name, float64(i)/4, got, w)
+			}
+		}
+	}
+}
+
+func TestClamp(t *testing.T) {
+	for _, name := range Names() {
+		f, _ := ByName(name)
+		if f(-1) != 0 || !near(f(2), 1) {
+			t.Errorf("%s not clamped: f(-1) = %v, f(2) = %v", name, f(-1), f(2))
+		}
+	}
+}
+
+func TestByNameUnknown(t *testing.T) {
+	if _, err := ByName("elastic"); err == nil {
+		t.Error("ByName(elastic) succeeded")
+	}
+}
+
+func TestPulse(t *testing.T) {
+	for _, c := range []struct{ t, want float64 }{
+		{0, 0}, {0.25, 0.5}, {0.5, 1}, {0.75, 0.5}, {1, 0}, {1.25, 0.5}, {-0.25, 0.5},
+	} {
+		if got := Pulse(Linear, c.t); !near(got, c.want) {
+			t.Errorf("Pulse(Linear, %v) = %v, want %v", c.t, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/strip"
+)
+
+// EscalationStep changes how a failed pixel is shown once it has been failed
+// for After: blinking with Period and Duty, and with its colour scaled to
+// Brightness percent.
+type EscalationStep struct {
+	After      time.Duration
+	Period     time.Duration
+	Duty       float64
+	Brightness float64 // percent, 100 unless set
+}
+
+func validateEscalation(steps []EscalationStep) []error {
+	var errs []error
+	for i, step := range steps {
+		if i > 0 && step.After <= steps[i-1].After {
+			errs = append(errs, fmt.Errorf("escalation: steps must be in increasing order of after"))
+		}
+		if step.Period < 0 || step.Duty < 0 || step.Duty > 1 {
+			errs = append(errs, fmt.Errorf("escalation: step after %s needs a positive period and a duty between 0 and 1", step.After))
+		}
+		if step.Brightness < 0 {
+			errs = append(errs, fmt.Errorf("escalation: step after %s has a negative brightness", step.After))
+		}
+	}
+	return errs
+}
+
+// escalationStep returns the step reached by a failure of age, or nil.
+func escalationStep(steps []EscalationStep, age time.Duration) *EscalationStep {
+	var reached *EscalationStep
+	for i := range steps {
+		if age >= steps[i].After {
+			reached = &steps[i]
+		}
+	}
+	return reached
+}
+
+// escalate shows a failed pixel as the escalation step it has reached.
+// Acknowledged failures don't escalate.
+func escalate(pixel *led.Led, now time.Time) {
+	if pixel.Status != "failed" || pixel.Acknowledged {
+		return
+	}
+	step := escalationStep(C.Escalation, now.Sub(pixel.Changed))
+	if step == nil {
+		return
+	}
+	colour := colourFor(pixel.Unit, "failed")
+	if step.Brightness > 0 {
+		colour = scaleColour(colour, step.Brightness/100)
+	}
+	pattern := led.Pattern{Period: step.Period, Duty: step.Duty}
+	if pixel.Colour != colour || pixel.Pattern != pattern {
+		setColour(pixel, colour)
+		pixel.SetPattern(pattern)
+	}
+}
+
+func escalationLoop(strips []*strip.Strip) {
+	for now := range time.Tick(time.Second) {
+		for _, pixel := range pixelsOf(strips) {
+			escalate(pixel, now)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+func TestEscalate(t *testing.T) {
+	C = Config{
+		Theme: "classic",
+		Escalation: []EscalationStep{
+			{After: time.Minute, Period: time.Second, Duty: 0.5},
+			{After: time.Hour, Period: 200 * time.Millisecond, Duty: 0.5, Brightness: 200},
+		},
+	}
+	defer func() { C = Config{} }()
+	failed := time.Now()
+	pixel := &led.Led{Unit: "a.service", Status: "failed", Colour: "55002200", Changed: failed}
+
+	escalate(pixel, failed.Add(time.Second))
+	if pixel.Pattern != (led.Pattern{}) {
+		t.Errorf("fresh failure escalated to %+v", pixel.Pattern)
+	}
+	escalate(pixel, failed.Add(2*time.Minute))
+	if pixel.Pattern.Period != time.Second || pixel.Colour != "55002200" {
+		t.Errorf("after 2m: %s %+v", pixel.Colour, pixel.Pattern)
+	}
+	escalate(pixel, failed.Add(2*time.Hour))
+	if pixel.Pattern.Period != 200*time.Millisecond || pixel.Colour != "aa004400" {
+		t.Errorf("after 2h: %s %+v", pixel.Colour, pixel.Pattern)
+	}
+
+	pixel.Acknowledged = true
+	pixel.Pattern = led.Pattern{}
+	escalate(pixel, failed.Add(2*time.Hour))
+	if pixel.Pattern != (led.Pattern{}) {
+		t.Error("acknowledged failure escalated")
+	}
+}
+
+func TestValidateEscalation(t *testing.T) {
+	if errs := validateEscalation([]EscalationStep{{After: time.Hour}, {After: time.Minute}}); len(errs) == 0 {
+		t.Error("steps out of order accepted")
+	}
+	if errs := validateEscalation([]EscalationStep{{After: time.Minute, Duty: 2}}); len(errs) == 0 {
+		t.Error("duty over 1 accepted")
+	}
+}
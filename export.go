@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+type exportedUnit struct {
+	Unit         string `json:"unit"`
+	Pixel        int    `json:"pixel"`
+	State        string `json:"state"`
+	SubState     string `json:"sub_state,omitempty"`
+	Colour       string `json:"colour"`
+	Acknowledged bool   `json:"acknowledged"`
+}
+
+type export struct {
+	Updated time.Time      `json:"updated"`
+	Units   []exportedUnit `json:"units"`
+}
+
+func currentExport(s *strip.Strip) export {
+	e := export{Updated: time.Now(), Units: []exportedUnit{}}
+	for _, pixel := range s.Pixels {
+		e.Units = append(e.Units, exportedUnit{
+			Unit:         pixel.Unit,
+			Pixel:        pixel.Number,
+			State:        pixel.Status,
+			SubState:     pixel.SubState,
+			Colour:       pixel.Colour,
+			Acknowledged: pixel.Acknowledged,
+		})
+	}
+	return e
+}
+
+// exportLoop rewrites the export file on every change so local tools, e.g.
+// node_exporter's textfile collector scripts, can read the current status.
+func exportLoop(s *strip.Strip, path string, changes <-chan struct{}) {
+	for range changes {
+		data, err := json.MarshalIndent(currentExport(s), "", "  ")
+		if err == nil {
+			err = writeFileAtomic(path, append(data, '\n'))
+		}
+		if err != nil {
+			logr.ErrorL("export", "Failed to write export file", zap.String("path", path), zap.Error(err))
+		}
+	}
+}
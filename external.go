@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/shift/systemd-status-leds/monitor"
+	"go.uber.org/zap"
+)
+
+// ExternalConfig reads the states of services with source external as JSON
+// lines, {"name":"backup","state":"failed"}, from standard input and/or the
+// named pipe Fifo.
+type ExternalConfig struct {
+	Stdin bool
+	Fifo  string
+}
+
+// externalEvent is one line written by an external source.
+type externalEvent struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// systemdUnits lists the tracked units that come from systemd rather than
+// another source.
+func systemdUnits() []string {
+	other := map[string]bool{}
+	for _, service := range C.Services {
+		if service.Source != "" {
+			other[service.Unit] = true
+			for _, unit := range service.Units {
+				other[unit] = true
+			}
+		}
+	}
+	var names []string
+	for _, pixel := range tracked {
+		if !other[pixel.Unit] {
+			names = append(names, pixel.Unit)
+		}
+	}
+	return names
+}
+
+// readExternal renders each event read from r until it ends.
+func readExternal(r io.Reader, render monitor.Renderer) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event externalEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			logr.Warn("Ignoring malformed external event", zap.ByteString("line", scanner.Bytes()), zap.Error(err))
+			continue
+		}
+		if !externalUnit(event.Name) {
+			logr.Warn("Ignoring external event for an unknown service", zap.String("name", event.Name))
+			continue
+		}
+		if !knownState(event.State) {
+			logr.Warn("Ignoring external event with an unknown state", zap.String("name", event.Name), zap.String("state", event.State))
+			continue
+		}
+		render.Render(monitor.Event{Unit: event.Name, State: event.State})
+	}
+	return scanner.Err()
+}
+
+// externalUnit reports whether name is a service with source external.
+func externalUnit(name string) bool {
+	for _, service := range C.Services {
+		if service.Source != "external" {
+			continue
+		}
+		if service.Unit == name {
+			return true
+		}
+		for _, unit := range service.Units {
+			if unit == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fifoLoop reads events from the named pipe path, reopening it each time its
+// last writer closes it.
+func fifoLoop(path string, render monitor.Renderer) {
+	for {
+		if err := readFifo(path, render); err != nil {
+			logr.Error("Unable to read the external fifo", zap.String("fifo", path), zap.Error(err))
+			return
+		}
+	}
+}
+
+func readFifo(path string, render monitor.Renderer) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return fmt.Errorf("not a named pipe")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readExternal(f, render)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"go.uber.org/zap"
+)
+
+func TestReadExternal(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	C = Config{Services: []Service{{Unit: "backup", Source: "external"}, {Unit: "nginx.service"}}}
+	defer func() { C = Config{} }()
+	input := strings.Join([]string{
+		`{"name":"backup","state":"failed"}`,
+		``,
+		`not json`,
+		`{"name":"nginx.service","state":"failed"}`,
+		`{"name":"backup","state":"faild"}`,
+		`{"name":"backup","state":"active"}`,
+	}, "\n")
+
+	var got collect
+	if err := readExternal(strings.NewReader(input), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := collect{{Unit: "backup", State: "failed"}, {Unit: "backup", State: "active"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rendered %v, want %v", got, want)
+	}
+}
+
+func TestSystemdUnits(t *testing.T) {
+	C = Config{Services: []Service{{Unit: "backup", Source: "external"}, {Unit: "nginx.service"}}}
+	tracked = []*led.Led{{Unit: "backup"}, {Unit: "nginx.service"}}
+	defer func() { C, tracked = Config{}, nil }()
+	if got := systemdUnits(); !reflect.DeepEqual(got, []string{"nginx.service"}) {
+		t.Errorf("systemdUnits() = %v", got)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/shift/systemd-status-leds/monitor"
+)
+
+// defaultFileInterval is how often a file is checked when the service does
+// not say.
+const defaultFileInterval = time.Minute
+
+// FileCheck derives a service's state from a file, checked every Interval:
+// it is failed when Path is missing, last modified more than MaxAge ago or,
+// with Contains, does not contain it. Otherwise it is active.
+type FileCheck struct {
+	Path     string
+	MaxAge   time.Duration `mapstructure:"max_age"`
+	Contains string
+	Interval time.Duration
+}
+
+func (f FileCheck) Validate(unit string) error {
+	if f.Path == "" {
+		return fmt.Errorf("%s: source file needs file.path", unit)
+	}
+	if f.MaxAge < 0 || f.Interval < 0 {
+		return fmt.Errorf("%s: file.max_age and file.interval must not be negative", unit)
+	}
+	return nil
+}
+
+// state checks the file as of now.
+func (f FileCheck) state(now time.Time) string {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return "failed"
+	}
+	if f.MaxAge > 0 && now.Sub(info.ModTime()) > f.MaxAge {
+		return "failed"
+	}
+	if f.Contains != "" {
+		data, err := os.ReadFile(f.Path)
+		if err != nil || !bytes.Contains(data, []byte(f.Contains)) {
+			return "failed"
+		}
+	}
+	return "active"
+}
+
+// fileLoop renders the state of the service's file, the first time as its
+// initial state.
+func fileLoop(service Service, render monitor.Renderer) {
+	interval := service.File.Interval
+	if interval == 0 {
+		interval = defaultFileInterval
+	}
+	initial := true
+	for ; ; time.Sleep(interval) {
+		render.Render(monitor.Event{Unit: service.Unit, State: service.File.state(time.Now()), Initial: initial})
+		initial = false
+	}
+}
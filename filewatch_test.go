@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCheckState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.stamp")
+	now := time.Now()
+	if got := (FileCheck{Path: path}).state(now); got != "failed" {
+		t.Errorf("missing file: %s, want failed", got)
+	}
+	if err := os.WriteFile(path, []byte("backup ok\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		check FileCheck
+		at    time.Time
+		want  string
+	}{
+		{FileCheck{Path: path}, now, "active"},
+		{FileCheck{Path: path, MaxAge: time.Hour}, now, "active"},
+		{FileCheck{Path: path, MaxAge: time.Hour}, now.Add(2 * time.Hour), "failed"},
+		{FileCheck{Path: path, Contains: "ok"}, now, "active"},
+		{FileCheck{Path: path, Contains: "error"}, now, "failed"},
+	} {
+		if got := c.check.state(c.at); got != c.want {
+			t.Errorf("%+v at %v: %s, want %s", c.check, c.at.Sub(now), got, c.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+// severity orders states from worst to best for aggregation, unless the
+// configuration's severity puts some first.
+var severity = []string{"failed", "watchdog", "missing", "masked", "deactivating", "activating", "reloading", "disabled", "inactive", "skipped", "absent", "armed", "active"}
+
+func rank(state string) int {
+	for i, s := range C.Severity {
+		if s == state {
+			return i
+		}
+	}
+	for i, s := range severity {
+		if s == state {
+			return len(C.Severity) + i
+		}
+	}
+	return len(C.Severity) + len(severity)
+}
+
+func validateSeverity(order []string) error {
+	seen := map[string]bool{}
+	for _, state := range order {
+		if !knownState(state) {
+			return fmt.Errorf("severity: unknown state %q", state)
+		}
+		if seen[state] {
+			return fmt.Errorf("severity: %s is listed twice", state)
+		}
+		seen[state] = true
+	}
+	return nil
+}
+
+// group drives one pixel from the states of several units.
+type group struct {
+	mu        sync.Mutex
+	pixel     *led.Led
+	members   []*led.Led
+	aggregate string
+	quorum    int
+}
+
+var (
+	groupsMu sync.Mutex
+	groups   = map[*led.Led]*group{}
+
+	// tracked are the leds following a systemd unit: ungrouped pixels and
+	// the members of groups.
+	tracked []*led.Led
+)
+
+func (s Service) validateGroup() error {
+	if len(s.Units) == 0 {
+		return nil
+	}
+	switch s.Aggregate {
+	case "", "worst", "all-active":
+	case "quorum":
+		if s.Quorum < 1 || s.Quorum > len(s.Units) {
+			return fmt.Errorf("%s: quorum must be between 1 and %d", s.Unit, len(s.Units))
+		}
+	default:
+		return fmt.Errorf("%s: unknown aggregate %q", s.Unit, s.Aggregate)
+	}
+	return nil
+}
+
+// track registers the units driving pixel.
+func track(pixel *led.Led, service Service) {
+	if len(service.Units) == 0 {
+		tracked = append(tracked, pixel)
+		return
+	}
+	g := &group{pixel: pixel, aggregate: service.Aggregate, quorum: service.Quorum}
+	for _, unit := range service.Units {
+		member := &led.Led{}
+		member.Unit = unit
+		g.members = append(g.members, member)
+		tracked = append(tracked, member)
+		groups[member] = g
+	}
+}
+
+func groupOf(member *led.Led) *group {
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	return groups[member]
+}
+
+// state aggregates the states of the members, or "" until one is known.
+func (g *group) state() string {
+	worst, active, known := "", 0, 0
+	for _, m := range g.members {
+		if m.Status == "" {
+			continue
+		}
+		known++
+		if m.Status == "active" {
+			active++
+		}
+		if worst == "" || rank(m.Status) < rank(worst) {
+			worst = m.Status
+		}
+	}
+	switch {
+	case known == 0:
+		return ""
+	case g.aggregate == "all-active" && active < len(g.members):
+		return "failed"
+	case g.aggregate == "quorum" && active >= g.quorum:
+		return "active"
+	}
+	return worst
+}
+
+// update shows the aggregated state on the group's pixel.
+func (g *group) update() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if state := g.state(); state != "" && state != g.pixel.Status {
+		setState(g.pixel, state)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+func TestGroupState(t *testing.T) {
+	for _, tc := range []struct {
+		aggregate string
+		quorum    int
+		states    []string
+		want      string
+	}{
+		{"worst", 0, []string{"active", "activating", "failed"}, "failed"},
+		{"worst", 0, []string{"active", "inactive"}, "inactive"},
+		{"worst", 0, []string{"", ""}, ""},
+		{"all-active", 0, []string{"active", "active"}, "active"},
+		{"all-active", 0, []string{"active", "inactive"}, "failed"},
+		{"all-active", 0, []string{"active", ""}, "failed"},
+		{"quorum", 2, []string{"active", "active", "failed"}, "active"},
+		{"quorum", 2, []string{"active", "failed", "inactive"}, "failed"},
+	} {
+		g := &group{aggregate: tc.aggregate, quorum: tc.quorum}
+		for _, state := range tc.states {
+			m := &led.Led{}
+			m.Status = state
+			g.members = append(g.members, m)
+		}
+		if got := g.state(); got != tc.want {
+			t.Errorf("%s %v = %q, want %q", tc.aggregate, tc.states, got, tc.want)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	defer func() { C = Config{} }()
+	g := &group{aggregate: "worst"}
+	for _, state := range []string{"active", "inactive", "activating"} {
+		m := &led.Led{}
+		m.Status = state
+		g.members = append(g.members, m)
+	}
+	if got := g.state(); got != "activating" {
+		t.Errorf("worst by default = %q, want activating", got)
+	}
+	C.Severity = []string{"inactive"}
+	if got := g.state(); got != "inactive" {
+		t.Errorf("worst with inactive first = %q, want inactive", got)
+	}
+	if rank("failed") != 1 || rank("inactive") != 0 {
+		t.Errorf("ranks: failed %d, inactive %d", rank("failed"), rank("inactive"))
+	}
+	for _, order := range [][]string{{"broken"}, {"failed", "failed"}} {
+		if err := validateSeverity(order); err == nil {
+			t.Errorf("severity %v accepted", order)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/shift/systemd-status-leds/ease"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+// HeartbeatConfig dedicates a pixel to the health of the daemon: Colour
+// pulses while frames are written, systemd is connected and the loops
+// following it and rendering are running, ErrorColour shows solid otherwise.
+type HeartbeatConfig struct {
+	Pixel       int
+	Colour      string
+	ErrorColour string `mapstructure:"error_colour"`
+	Period      time.Duration
+	// Easing shapes the pulse.
+	Easing string
+}
+
+// heartbeatLoop shows the health of the daemon on pixel: the frames of
+// strips, conn and, unless nil, the loop following systemd beating events.
+func heartbeatLoop(conn interface{ Connected() bool }, events *liveness, strips []*strip.Strip, pixel *led.Led, h HeartbeatConfig) {
+	start := time.Now()
+	shape, err := ease.ByName(h.Easing)
+	if err != nil {
+		shape = ease.InOut
+	}
+	healthy := true
+	for range time.Tick(h.Period / 20) {
+		err := writeErr(strips)
+		if err == nil {
+			err = stalled(events, strips, start, time.Now())
+		}
+		ok := err == nil && conn.Connected()
+		if ok != healthy {
+			healthy = ok
+			logr.Info("Heartbeat health changed", zap.Bool("healthy", ok), zap.Bool("systemd", conn.Connected()), zap.Error(err))
+		}
+		if !ok {
+			setColour(pixel, h.ErrorColour)
+			continue
+		}
+		phase := float64(time.Since(start)%h.Period) / float64(h.Period)
+		setColour(pixel, scaleColour(h.Colour, 0.2+0.8*ease.Pulse(shape, phase)))
+	}
+}
+
+// writeErr is the error of the first strip whose last frame failed to write.
+func writeErr(strips []*strip.Strip) error {
+	for _, s := range strips {
+		if err := s.WriteErr(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// liveness is when a loop last showed it was running.
+type liveness struct{ at atomic.Int64 }
+
+// eventLoop beats while the loop following systemd runs.
+var eventLoop liveness
+
+// beat records that the loop is running.
+func (l *liveness) beat() { l.at.Store(time.Now().UnixNano()) }
+
+// since is when the loop last beat, or start if it hasn't since.
+func (l *liveness) since(start time.Time) time.Time {
+	if at := time.Unix(0, l.at.Load()); at.After(start) {
+		return at
+	}
+	return start
+}
+
+// stalled reports a loop that has shown no sign of running for too long:
+// the one following systemd, which beats events at least every
+// monitor.AliveInterval and after each call, each bounded by
+// systemd.timeout, or a strip's, which writes a frame every interval unless
+// a write is stuck. Both are timed from start at the earliest.
+func stalled(events *liveness, strips []*strip.Strip, start, now time.Time) error {
+	if events != nil {
+		if quiet := now.Sub(events.since(start)); quiet > 2*C.Systemd.Timeout+monitor.AliveInterval {
+			return fmt.Errorf("no sign of the event loop for %v", quiet.Round(time.Second))
+		}
+	}
+	for _, s := range strips {
+		last := s.Framed()
+		if last.Before(start) {
+			last = start
+		}
+		if quiet := now.Sub(last); quiet > 3*s.Interval+s.WriteTimeout {
+			return fmt.Errorf("strip %q wrote no frame for %v", s.Name, quiet.Round(time.Second))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+func TestStalled(t *testing.T) {
+	C = Config{}
+	C.Systemd.Timeout = time.Second
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	length, channels := 2, 3
+	s, err := strip.New(logr, &strip.Terminal{Out: io.Discard, Channels: channels}, &length, &channels, strip.Opts{Interval: 10 * time.Millisecond, Power: strip.Power{MilliampsPerChannel: strip.DefaultMilliampsPerChannel}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	strips := []*strip.Strip{s}
+	start := time.Now().Add(-time.Minute)
+
+	if err := stalled(nil, strips, time.Now(), time.Now()); err != nil {
+		t.Errorf("just started: %v", err)
+	}
+	if err := stalled(nil, strips, start, time.Now()); err == nil || !strings.Contains(err.Error(), "no frame") {
+		t.Errorf("no frame for a minute: %v", err)
+	}
+	if err := s.Draw(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := stalled(nil, strips, start, time.Now()); err != nil {
+		t.Errorf("after a frame: %v", err)
+	}
+
+	var events liveness
+	if err := stalled(&events, strips, start, time.Now()); err == nil || !strings.Contains(err.Error(), "event loop") {
+		t.Errorf("no beat for a minute: %v", err)
+	}
+	events.beat()
+	if err := stalled(&events, strips, start, time.Now()); err != nil {
+		t.Errorf("after a beat: %v", err)
+	}
+	if err := stalled(&events, strips, start, time.Now().Add(3*time.Second+monitor.AliveInterval)); err == nil || !strings.Contains(err.Error(), "event loop") {
+		t.Errorf("no beat for longer than two calls: %v", err)
+	}
+}
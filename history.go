@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shift/systemd-status-leds/monitor"
+	"go.uber.org/zap"
+)
+
+// HistoryConfig records every unit's state transitions to File, one JSON
+// line each, for the history command. Transitions older than Retention are
+// dropped, but for the last one of each unit, so its state is still known;
+// a zero Retention keeps them all. An empty File disables it.
+type HistoryConfig struct {
+	File      string
+	Retention time.Duration
+	Uptime    UptimeConfig
+}
+
+func (h HistoryConfig) Validate(c *Config) error {
+	if h.Retention < 0 {
+		return fmt.Errorf("history.retention must not be negative, got %v", h.Retention)
+	}
+	return h.Uptime.Validate(c)
+}
+
+// historyEntry is one line of the history file: a unit entering State at
+// Time.
+type historyEntry struct {
+	Time     time.Time `json:"time"`
+	Unit     string    `json:"unit"`
+	State    string    `json:"state"`
+	SubState string    `json:"sub_state,omitempty"`
+	Result   string    `json:"result,omitempty"`
+}
+
+// historian appends each unit's state transitions to the history file
+// before rendering them.
+type historian struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	out  *json.Encoder
+	last map[string]string
+	next monitor.Renderer
+}
+
+func openHistory(path string, next monitor.Renderer) (*historian, error) {
+	h := &historian{path: path, last: map[string]string{}, next: next}
+	if err := h.open(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *historian) open() error {
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	h.f, h.out = f, json.NewEncoder(f)
+	return nil
+}
+
+func (h *historian) Render(e monitor.Event) {
+	state := e.State
+	if e.Gone {
+		state = "inactive"
+	}
+	h.mu.Lock()
+	var err error
+	if h.last[e.Unit] != state {
+		h.last[e.Unit] = state
+		err = h.out.Encode(historyEntry{Time: time.Now(), Unit: e.Unit, State: state, SubState: e.SubState, Result: e.Result})
+	}
+	h.mu.Unlock()
+	if err != nil {
+		logr.ErrorL("history", "Unable to record state history", zap.String("unit", e.Unit), zap.Error(err))
+	}
+	h.next.Render(e)
+}
+
+// entries reads the history so far.
+func (h *historian) entries() ([]historyEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.read()
+}
+
+func (h *historian) read() ([]historyEntry, error) {
+	f, err := os.Open(h.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readHistory(f)
+}
+
+// prune drops the transitions before before, keeping the last of each unit.
+func (h *historian) prune(before time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries, err := h.read()
+	if err != nil {
+		return err
+	}
+	tmp := h.path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	for _, entry := range kept(entries, before) {
+		if err := enc.Encode(entry); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return err
+	}
+	h.f.Close()
+	return h.open()
+}
+
+// kept is entries without the transitions before before, but for the last
+// of each unit, found in one pass from the end.
+func kept(entries []historyEntry, before time.Time) []historyEntry {
+	seen := map[string]bool{}
+	drop := make([]bool, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entry := entries[i]; entry.Time.Before(before) {
+			drop[i] = seen[entry.Unit]
+			seen[entry.Unit] = true
+		}
+	}
+	var out []historyEntry
+	for i, entry := range entries {
+		if !drop[i] {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// pruneLoop prunes the history hourly, keeping retention of it.
+func (h *historian) pruneLoop(retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	for now := time.Now(); ; now = <-time.After(time.Hour) {
+		if err := h.prune(now.Add(-retention)); err != nil {
+			logr.ErrorL("history", "Unable to prune state history", zap.Error(err))
+		}
+	}
+}
+
+// readHistory parses a history file, one JSON entry per line.
+func readHistory(r io.Reader) ([]historyEntry, error) {
+	var entries []historyEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// uptime is how long unit was active between since and now, out of how long
+// its state is known for.
+func uptime(entries []historyEntry, unit string, since, now time.Time) (up, known time.Duration) {
+	t := uptimes(entries, since, now)[unit]
+	return t.up, t.known
+}
+
+// unitUptime is how long a unit was active, out of how long its state is
+// known for.
+type unitUptime struct{ up, known time.Duration }
+
+// uptimes is the uptime of every unit in entries between since and now, in
+// one pass over them.
+func uptimes(entries []historyEntry, since, now time.Time) map[string]unitUptime {
+	times := map[string]unitUptime{}
+	last := map[string]historyEntry{}
+	add := func(from historyEntry, to time.Time) {
+		start := from.Time
+		if start.Before(since) {
+			start = since
+		}
+		if !to.After(start) {
+			return
+		}
+		t := times[from.Unit]
+		t.known += to.Sub(start)
+		if from.State == "active" {
+			t.up += to.Sub(start)
+		}
+		times[from.Unit] = t
+	}
+	for _, entry := range entries {
+		if from, ok := last[entry.Unit]; ok {
+			add(from, entry.Time)
+		}
+		last[entry.Unit] = entry
+	}
+	for _, from := range last {
+		add(from, now)
+	}
+	return times
+}
+
+// history prints a unit's transitions since a time ago and how long it was
+// up, returning the process exit status.
+func history(args []string) int {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	path := flags.String("config", "", "configuration file")
+	since := flags.Duration("since", 24*time.Hour, "how far back to look")
+	_ = flags.Parse(args)
+	if flags.NArg() > 0 {
+		// Flags may follow the unit too.
+		_ = flags.Parse(append(flags.Args()[1:], flags.Arg(0)))
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: history [--config config] [--since 24h] <unit>")
+		return 2
+	}
+	unit := flags.Arg(0)
+	Configuration(*path)
+	if C.History.File == "" {
+		fmt.Fprintln(os.Stderr, "history.file is not set")
+		return 1
+	}
+	f, err := os.Open(C.History.File)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	entries, err := readHistory(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", C.History.File, err)
+		return 1
+	}
+	printHistory(os.Stdout, entries, unit, time.Now().Add(-*since), time.Now())
+	return 0
+}
+
+func printHistory(w io.Writer, entries []historyEntry, unit string, since, now time.Time) {
+	for _, entry := range entries {
+		if entry.Unit != unit || entry.Time.Before(since) {
+			continue
+		}
+		line := entry.Time.Local().Format(time.DateTime) + "  " + entry.State
+		if entry.SubState != "" {
+			line += " (" + entry.SubState + ")"
+		}
+		if entry.Result != "" && entry.Result != "success" {
+			line += " result " + entry.Result
+		}
+		fmt.Fprintln(w, line)
+	}
+	up, known := uptime(entries, unit, since, now)
+	if known == 0 {
+		fmt.Fprintf(w, "%s: no history since %s\n", unit, since.Local().Format(time.DateTime))
+		return
+	}
+	fmt.Fprintf(w, "%s: up %.2f%% of %s\n", unit, 100*float64(up)/float64(known), known.Round(time.Second))
+}
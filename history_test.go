@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shift/systemd-status-leds/monitor"
+)
+
+func TestHistoryTransitions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	var rendered collect
+	h, err := openHistory(path, &rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []monitor.Event{
+		{Unit: "a.service", State: "active", SubState: "running", Initial: true},
+		{Unit: "a.service", State: "active", SubState: "running"},
+		{Unit: "a.service", State: "failed", SubState: "failed", Result: "exit-code"},
+		{Unit: "b@1.service", State: "active", Template: "b@.service"},
+		{Unit: "b@1.service", Gone: true},
+	} {
+		h.Render(e)
+	}
+	if len(rendered) != 5 {
+		t.Errorf("rendered %d events, want 5", len(rendered))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readHistory(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []string
+	for _, entry := range entries {
+		states = append(states, entry.Unit+" "+entry.State)
+	}
+	want := []string{"a.service active", "a.service failed", "b@1.service active", "b@1.service inactive"}
+	if len(states) != len(want) {
+		t.Fatalf("recorded %v, want %v", states, want)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Errorf("recorded %v, want %v", states, want)
+			break
+		}
+	}
+}
+
+func TestHistoryPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h, err := openHistory(path, &collect{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, entry := range []historyEntry{
+		{Time: now.Add(-3 * time.Hour), Unit: "a.service", State: "active"},
+		{Time: now.Add(-2 * time.Hour), Unit: "a.service", State: "failed"},
+		{Time: now.Add(-2 * time.Hour), Unit: "b.service", State: "active"},
+		{Time: now.Add(-time.Minute), Unit: "a.service", State: "active"},
+	} {
+		if err := h.out.Encode(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.prune(now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	h.Render(monitor.Event{Unit: "c.service", State: "active"})
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readHistory(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || entries[0].State != "failed" || entries[1].Unit != "b.service" || entries[3].Unit != "c.service" {
+		t.Errorf("after pruning: %+v", entries)
+	}
+}
+
+func TestUptime(t *testing.T) {
+	now := time.Now()
+	entries := []historyEntry{
+		{Time: now.Add(-30 * time.Hour), Unit: "a.service", State: "active"},
+		{Time: now.Add(-6 * time.Hour), Unit: "a.service", State: "failed"},
+		{Time: now.Add(-5 * time.Hour), Unit: "b.service", State: "failed"},
+		{Time: now.Add(-4 * time.Hour), Unit: "a.service", State: "active"},
+	}
+	up, known := uptime(entries, "a.service", now.Add(-24*time.Hour), now)
+	if up != 22*time.Hour || known != 24*time.Hour {
+		t.Errorf("a.service up %v of %v, want 22h of 24h", up, known)
+	}
+	up, known = uptime(entries, "b.service", now.Add(-24*time.Hour), now)
+	if up != 0 || known != 5*time.Hour {
+		t.Errorf("b.service up %v of %v, want 0 of 5h", up, known)
+	}
+	if _, known := uptime(entries, "c.service", now.Add(-24*time.Hour), now); known != 0 {
+		t.Errorf("c.service known for %v, want 0", known)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/shift/systemd-status-leds/mdns"
+	"github.com/shift/systemd-status-leds/strip"
+	"github.com/shift/systemd-status-leds/telemetry"
+	"go.uber.org/zap"
+)
+
+// HTTPConfig enables the HTTP listener on Listen, serving the strip's
+// current frame at /snapshot.png and /snapshot.svg. Debug adds
+// net/http/pprof under /debug/pprof/ and a dump of every goroutine at
+// /debug/goroutines.
+//
+// Token, when set, is required of every request as a bearer token, and
+// Credential names a systemd credential holding it instead. Cert and Key
+// serve HTTPS from PEM files, each a path or the name of a systemd
+// credential.
+//
+// MDNS announces the listener on the local network as Name, the host name
+// unless set, of the DNS-SD service _statusleds._tcp.
+type HTTPConfig struct {
+	Listen     string
+	Debug      bool
+	Token      string
+	Credential string
+	Cert       string
+	Key        string
+	MDNS       bool `mapstructure:"mdns"`
+	Name       string
+}
+
+// mdnsService is the DNS-SD service the listener is announced as.
+const mdnsService = "_statusleds._tcp"
+
+func (c HTTPConfig) Validate() error {
+	if (c.Cert == "") != (c.Key == "") {
+		return fmt.Errorf("http: cert and key go together")
+	}
+	if c.MDNS {
+		if c.Listen == "" {
+			return fmt.Errorf("http: mdns needs a listen address")
+		}
+		host, _, err := net.SplitHostPort(c.Listen)
+		if err != nil {
+			return fmt.Errorf("http: listen: %v", err)
+		}
+		if ip := net.ParseIP(host); host == "localhost" || ip != nil && ip.IsLoopback() {
+			return fmt.Errorf("http: mdns can't announce a listener on loopback")
+		}
+	}
+	return nil
+}
+
+// announcedIPs are the IPv4 addresses the listener on listen is reached at:
+// those of every interface up and able to multicast, if it listens on all.
+func announcedIPs(listen string) ([]net.IP, int, error) {
+	host, p, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, 0, err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch ip := net.ParseIP(host); {
+	case ip != nil && !ip.IsUnspecified():
+		return []net.IP{ip}, port, nil
+	case ip == nil && host != "":
+		ips, err := net.LookupIP(host)
+		return ips, port, err
+	}
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, 0, err
+	}
+	var ips []net.IP
+	for _, i := range interfaces {
+		if i.Flags&net.FlagUp == 0 || i.Flags&net.FlagMulticast == 0 || i.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := i.Addrs()
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				ips = append(ips, ipnet.IP)
+			}
+		}
+	}
+	return ips, port, nil
+}
+
+// announce announces the listener over mDNS, with TXT records telling
+// clients how to talk to it.
+func announce(c HTTPConfig) {
+	ips, port, err := announcedIPs(c.Listen)
+	if err != nil {
+		logr.Error("Unable to announce the HTTP listener", zap.Error(err))
+		return
+	}
+	host, _ := os.Hostname()
+	host, _, _ = strings.Cut(host, ".")
+	name := c.Name
+	if name == "" {
+		name = host
+	}
+	text := []string{"path=/", "tls=0", "auth=none"}
+	if c.Cert != "" {
+		text[1] = "tls=1"
+	}
+	if c.Token != "" {
+		text[2] = "auth=bearer"
+	}
+	s := mdns.Service{Instance: name, Service: mdnsService, Host: host, Port: port, Text: text, IPs: ips}
+	s.Renamed = func(s mdns.Service) {
+		logr.Warn("mDNS name taken, renaming", zap.String("name", s.Instance), zap.String("host", s.Host))
+	}
+	logr.Info("Announcing over mDNS", zap.String("name", name), zap.String("service", mdnsService), zap.Int("port", port))
+	if err := mdns.Announce(context.Background(), s); err != nil {
+		logr.Error("mDNS announcement failed", zap.Error(err))
+	}
+}
+
+// httpMux builds the handlers served on the HTTP listener.
+func httpMux(c HTTPConfig, s *strip.Strip) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", telemetry.Handler())
+	if s != nil {
+		mux.HandleFunc("/snapshot.png", snapshotHandler(s))
+		mux.HandleFunc("/snapshot.svg", snapshotHandler(s))
+		mux.HandleFunc("/frames", framesHandler(s))
+	}
+	if stream != nil {
+		mux.Handle("/events", stream)
+	}
+	if c.Debug {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.HandleFunc("/debug/goroutines", goroutines)
+	}
+	return mux
+}
+
+// httpHandler is the mux, behind the token if there is one.
+func httpHandler(c HTTPConfig, s *strip.Strip) http.Handler {
+	mux := httpMux(c, s)
+	if c.Token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="systemd-status-leds"`)
+			http.Error(w, "unauthorised", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// goroutines writes the stack of every goroutine.
+func goroutines(w http.ResponseWriter, r *http.Request) {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(buf)
+}
+
+func httpLoop(c HTTPConfig, s *strip.Strip) {
+	tls := c.Cert != ""
+	logr.Info("HTTP listening", zap.String("listen", c.Listen), zap.Bool("debug", c.Debug), zap.Bool("tls", tls), zap.Bool("token", c.Token != ""))
+	server := &http.Server{Addr: c.Listen, Handler: httpHandler(c, s)}
+	if c.MDNS {
+		go announce(c)
+	}
+	var err error
+	if tls {
+		err = server.ListenAndServeTLS(credentialPath(c.Cert), credentialPath(c.Key))
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil {
+		logr.Error("HTTP listener failed", zap.String("listen", c.Listen), zap.Error(err))
+	}
+}
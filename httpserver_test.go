@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDebug(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		mux := httpMux(HTTPConfig{Debug: debug}, nil)
+		for _, path := range []string{"/debug/goroutines", "/debug/pprof/"} {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if got := rec.Code == http.StatusOK; got != debug {
+				t.Errorf("debug %v: GET %s = %d", debug, path, rec.Code)
+			}
+		}
+	}
+	rec := httptest.NewRecorder()
+	httpMux(HTTPConfig{Debug: true}, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/goroutines", nil))
+	if !strings.Contains(rec.Body.String(), "TestHTTPDebug") {
+		t.Errorf("goroutine dump lacks the test's own goroutine")
+	}
+}
+
+func TestHTTPToken(t *testing.T) {
+	h := httpHandler(HTTPConfig{Debug: true, Token: "s3cret"}, nil)
+	for _, tc := range []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Basic s3cret", http.StatusUnauthorized},
+		{"Bearer s3cret", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/debug/goroutines", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("Authorization %q: %d, want %d", tc.auth, rec.Code, tc.want)
+		}
+	}
+	if err := (HTTPConfig{Cert: "cert.pem"}).Validate(); err == nil {
+		t.Error("cert without a key accepted")
+	}
+}
+
+func TestHTTPMDNS(t *testing.T) {
+	for listen, ok := range map[string]bool{
+		":9090":            true,
+		"192.168.1.5:9090": true,
+		"127.0.0.1:9090":   false,
+		"localhost:9090":   false,
+		"[::1]:9090":       false,
+		"":                 false,
+		"192.168.1.5":      false,
+	} {
+		if err := (HTTPConfig{Listen: listen, MDNS: true}).Validate(); (err == nil) != ok {
+			t.Errorf("mdns on %q: %v", listen, err)
+		}
+	}
+	ips, port, err := announcedIPs("192.168.1.5:9090")
+	if err != nil || port != 9090 || len(ips) != 1 || ips[0].String() != "192.168.1.5" {
+		t.Errorf("announcedIPs = %v, %d, %v", ips, port, err)
+	}
+	ips, _, err = announcedIPs(":9090")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range ips {
+		if ip.IsLoopback() || ip.To4() == nil {
+			t.Errorf("announced %v", ip)
+		}
+	}
+}
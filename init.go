@@ -0,0 +1,100 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+)
+
+//go:embed example.yaml
+var exampleConfig string
+
+// initConfig writes the example configuration to path, refusing to replace
+// an existing file. It returns the process exit status.
+func initConfig(args []string) int {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	withSystemd := flags.Bool("systemd", false, "map the currently failed and enabled services")
+	_ = flags.Parse(args)
+	path := "config"
+	if flags.NArg() > 0 {
+		path = flags.Arg(0)
+	}
+
+	config := exampleConfig
+	if *withSystemd {
+		units, err := stubUnits()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "systemd:", err)
+			return 1
+		}
+		config = withServices(config, units)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if _, err := f.WriteString(config); err != nil {
+		f.Close()
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("wrote", path)
+	return 0
+}
+
+// stubUnits lists the failed units followed by the enabled services.
+func stubUnits() ([]string, error) {
+	conn, err := systemd.New()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	seen := map[string]bool{}
+	var units []string
+	failed, err := conn.ListUnitsFiltered([]string{"failed"})
+	if err != nil {
+		return nil, err
+	}
+	for _, unit := range failed {
+		seen[unit.Name] = true
+		units = append(units, unit.Name)
+	}
+	files, err := conn.ListUnitFilesByPatterns([]string{"enabled"}, []string{"*.service"})
+	if err != nil {
+		return nil, err
+	}
+	var enabled []string
+	for _, file := range files {
+		name := file.Path[strings.LastIndex(file.Path, "/")+1:]
+		if !seen[name] && !strings.HasSuffix(name, "@.service") {
+			seen[name] = true
+			enabled = append(enabled, name)
+		}
+	}
+	sort.Strings(enabled)
+	return append(units, enabled...), nil
+}
+
+// withServices replaces the example's services with one per unit.
+func withServices(config string, units []string) string {
+	start := strings.Index(config, "services:\n")
+	end := start + strings.Index(config[start:], "\n\n")
+	var b strings.Builder
+	b.WriteString("services:")
+	for _, unit := range units {
+		fmt.Fprintf(&b, "\n    - name: %s", unit)
+	}
+	return config[:start] + b.String() + config[end:]
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestWithServices(t *testing.T) {
+	config := withServices(exampleConfig, []string{"a.service", "b.service"})
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Services) != 2 || c.Services[0].Unit != "a.service" || c.Services[1].Unit != "b.service" {
+		t.Errorf("services = %+v", c.Services)
+	}
+	if c.Strip.Length == 0 {
+		t.Error("strip section lost")
+	}
+}
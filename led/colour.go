@@ -0,0 +1,25 @@
+package led
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Colour is a pixel's red, green, blue and white channels.
+type Colour [4]byte
+
+// ParseColour parses eight hex digits, red green blue white, e.g. "ff000000".
+func ParseColour(s string) (Colour, error) {
+	if len(s) != 8 {
+		return Colour{}, fmt.Errorf("colour %q is not eight hex digits", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return Colour{}, fmt.Errorf("colour %q is not eight hex digits", s)
+	}
+	return Colour{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}, nil
+}
+
+func (c Colour) String() string {
+	return fmt.Sprintf("%02x%02x%02x%02x", c[0], c[1], c[2], c[3])
+}
@@ -0,0 +1,36 @@
+package led
+
+import "testing"
+
+func TestParseColour(t *testing.T) {
+	c, err := ParseColour("ff8001Aa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != (Colour{0xff, 0x80, 0x01, 0xaa}) {
+		t.Errorf("got %v", c)
+	}
+	if c.String() != "ff8001aa" {
+		t.Errorf("String() = %q", c.String())
+	}
+	for _, bad := range []string{"", "ff0000", "ff00000000", "gg000000", "-1000000"} {
+		if _, err := ParseColour(bad); err == nil {
+			t.Errorf("ParseColour(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestSetColour(t *testing.T) {
+	var l Led
+	if err := l.SetColour("FF000000"); err != nil || l.Colour != "ff000000" {
+		t.Fatalf("SetColour(FF000000) = %v, colour %q", err, l.Colour)
+	}
+	for _, bad := range []string{"", "red", "ff0000"} {
+		if err := l.SetColour(bad); err == nil {
+			t.Errorf("SetColour(%q) succeeded", bad)
+		}
+		if l.Colour != "ff000000" {
+			t.Errorf("SetColour(%q) replaced the colour with %q", bad, l.Colour)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+// Package led holds the state of a single pixel: the unit it shows, its
+// Colour, blink Pattern and flash. A strip.Strip renders Leds; nothing here
+// touches hardware, so other daemons can drive pixels with their own states.
+package led
@@ -2,6 +2,7 @@ package led
 
 import (
 	"sync"
+	"time"
 )
 
 type Led struct {
@@ -14,9 +15,31 @@ type Led struct {
 	Number int
 	Unit   string
 	Status string
+	// SubState is systemd's finer grained state, e.g. "running" or "exited".
+	SubState string
+	// Changed is when Status last changed.
+	Changed time.Time
+	// Transitions counts changes of Status after the first.
+	Transitions int
+
+	Acknowledged bool
+	Pattern      Pattern
+
+	// Dim, between 0 and 1, darkens the pixel by that fraction.
+	Dim float64
+
+	// FlashColour is shown instead of Colour until FlashUntil.
+	FlashColour string
+	FlashUntil  time.Time
 }
 
 func (l *Led) SetStatus(state string) {
+	if l.Status != state {
+		if l.Status != "" {
+			l.Transitions++
+		}
+		l.Changed = time.Now()
+	}
 	l.Status = state
 }
 
@@ -36,6 +59,36 @@ func (l *Led) SetWhite(w int64) {
 	l.White = w
 }
 
-func (l *Led) SetColour(colour string) {
-	l.Colour = colour
+// SetColour sets the colour, eight hex digits as ParseColour takes. It keeps
+// the previous colour and returns an error for anything else.
+func (l *Led) SetColour(colour string) error {
+	c, err := ParseColour(colour)
+	if err != nil {
+		return err
+	}
+	l.Colour = c.String()
+	return nil
+}
+
+func (l *Led) SetAcknowledged(ack bool) {
+	l.Acknowledged = ack
+}
+
+func (l *Led) SetSubState(state string) {
+	l.SubState = state
+}
+
+func (l *Led) SetPattern(p Pattern) {
+	l.Pattern = p
+}
+
+func (l *Led) SetDim(f float64) {
+	l.Dim = f
+}
+
+func (l *Led) Flash(colour string, d time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	l.FlashColour = colour
+	l.FlashUntil = time.Now().Add(d)
 }
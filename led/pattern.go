@@ -0,0 +1,19 @@
+package led
+
+import "time"
+
+// Pattern blinks a pixel: it is lit for Duty of every Period. The zero
+// Pattern is always lit.
+type Pattern struct {
+	Period time.Duration
+	Duty   float64
+}
+
+// On reports whether a pixel with this pattern is lit at t.
+func (p Pattern) On(t time.Time) bool {
+	if p.Period <= 0 {
+		return true
+	}
+	phase := float64(t.UnixNano()%int64(p.Period)) / float64(p.Period)
+	return phase < p.Duty
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/strip"
+)
+
+// writeLegend writes each state with its colour, a swatch of it and its
+// blink pattern, if any.
+func writeLegend(w io.Writer) {
+	for _, state := range states {
+		colour := colourFor("", state)
+		fmt.Fprintf(w, "%-13s %-8s %s", state, colour, swatch(colour))
+		if p := patternFor("", state); p.Period > 0 {
+			fmt.Fprintf(w, " blinks every %s, lit %d%%", p.Period, int(p.Duty*100))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// swatch draws colour, white added to red, green and blue, on a truecolour
+// terminal.
+func swatch(colour string) string {
+	v, err := strconv.ParseUint(colour, 16, 32)
+	if err != nil || len(colour) != 8 {
+		return "  "
+	}
+	white := int(v & 0xff)
+	c := func(shift int) int { return min(int(v>>shift&0xff)+white, 255) }
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm██\x1b[0m", c(24), c(16), c(8))
+}
+
+// legend prints what each state's colour is and, with -show, lights the
+// strip in each state in turn. It returns the process exit status.
+func legend(args []string) int {
+	flags := flag.NewFlagSet("legend", flag.ExitOnError)
+	path := flags.String("config", "", "configuration file")
+	show := flags.Bool("show", false, "light the strip in each state in turn")
+	step := flags.Duration("step", 3*time.Second, "how long -show shows each state")
+	_ = flags.Parse(args)
+	if err := loadConfig(*path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !*show {
+		writeLegend(os.Stdout)
+		return 0
+	}
+
+	if err := lockDevice(*C.Strip.port()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	opts := C.Strip.Opts()
+	opts.Interval = 50 * time.Millisecond
+	s, err := strip.Init(logr, C.Strip.port(), &C.Strip.Length, &C.Strip.Channels, &C.Strip.Hertz, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var pixels []*led.Led
+	for n := 1; n <= C.Strip.Length; n++ {
+		pixel, err := s.Reserve("legend", n)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		pixels = append(pixels, pixel)
+	}
+	go strip.Run(s)
+	for _, state := range states {
+		colour := colourFor("", state)
+		fmt.Printf("%-13s %-8s %s\n", state, colour, swatch(colour))
+		for _, pixel := range pixels {
+			setColour(pixel, colour)
+			pixel.SetPattern(patternFor("", state))
+		}
+		time.Sleep(*step)
+	}
+	if err := s.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
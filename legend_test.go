@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteLegend(t *testing.T) {
+	C = Config{Theme: "classic", Strip: StripConfig{Colours: map[string]string{"failed": "99000000"}}, Accessibility: AccessibilityConfig{Patterns: true}}
+	defer func() { C = Config{} }()
+	var b strings.Builder
+	writeLegend(&b)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != len(states) {
+		t.Fatalf("%d lines, want one per state:\n%s", len(lines), b.String())
+	}
+	failed := lines[3]
+	if !strings.HasPrefix(failed, "failed        99000000") || !strings.Contains(failed, "\x1b[38;2;153;0;0m") {
+		t.Errorf("failed line = %q, want the strip's colour and its swatch", failed)
+	}
+	if !strings.Contains(failed, "blinks every 500ms, lit 50%") {
+		t.Errorf("failed line = %q, want its accessibility pattern", failed)
+	}
+	if !strings.HasPrefix(lines[0], "active        "+themes["classic"]["active"]) {
+		t.Errorf("active line = %q, want the theme's colour", lines[0])
+	}
+}
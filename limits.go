@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shift/systemd-status-leds/loglimit"
+)
+
+// LogConfig overrides the rate limits of repetitive log lines, by limiter
+// name, and how often suppressed lines are summarised.
+type LogConfig struct {
+	Summary time.Duration
+	Limits  map[string]loglimit.Limit
+}
+
+// defaultLimits are the limiters in use and their default limits.
+var defaultLimits = map[string]loglimit.Limit{
+	"dbus":    {Lines: 1, Interval: 10 * time.Second, Burst: 3},
+	"waiting": {Lines: 1, Interval: time.Minute, Burst: 1},
+	"power":   {Lines: 1, Interval: time.Minute, Burst: 1},
+	"thermal": {Lines: 1, Interval: 10 * time.Minute, Burst: 1},
+	"state":   {Lines: 1, Interval: time.Minute, Burst: 1},
+	"export":  {Lines: 1, Interval: time.Minute, Burst: 1},
+	"otlp":    {Lines: 1, Interval: 10 * time.Minute, Burst: 1},
+	"latency": {Lines: 1, Interval: time.Minute, Burst: 1},
+}
+
+func (c LogConfig) Validate() error {
+	for name, limit := range c.Limits {
+		if _, ok := defaultLimits[name]; !ok {
+			return fmt.Errorf("log.limits: unknown limiter %q", name)
+		}
+		if limit.Lines <= 0 || limit.Interval <= 0 {
+			return fmt.Errorf("log.limits.%s: lines and interval must be positive", name)
+		}
+	}
+	return nil
+}
+
+func configureLimits(c LogConfig) {
+	for name, limit := range defaultLimits {
+		if override, ok := c.Limits[name]; ok {
+			limit = override
+		}
+		logr.SetLimit(name, limit)
+	}
+	if c.Summary > 0 {
+		go logr.SummaryLoop(c.Summary)
+	}
+}
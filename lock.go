@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// locks are the open lock files, held until the daemon exits.
+var locks []*os.File
+
+// lockPath is the lock file of device in C.LockDir.
+func lockPath(device string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimPrefix(device, "/"))
+	return filepath.Join(C.LockDir, "systemd-status-leds-"+name+".lock")
+}
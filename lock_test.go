@@ -0,0 +1,35 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLockDevice(t *testing.T) {
+	C = Config{LockDir: t.TempDir()}
+	defer func() { C = Config{} }()
+	defer func(held int) {
+		for _, f := range locks[held:] {
+			f.Close()
+		}
+		locks = locks[:held]
+	}(len(locks))
+
+	if err := lockDevice("/dev/spidev0.0"); err != nil {
+		t.Fatal(err)
+	}
+	err := lockDevice("/dev/spidev0.0")
+	if err == nil || !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("second lock = %v, want it held by this process", err)
+	}
+	if err := lockDevice("/dev/spidev0.1"); err != nil {
+		t.Errorf("lock of another device = %v", err)
+	}
+	if got := lockPath("/dev/spidev0.0"); !strings.HasSuffix(got, "/systemd-status-leds-dev_spidev0.0.lock") {
+		t.Errorf("lockPath() = %q", got)
+	}
+}
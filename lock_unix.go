@@ -0,0 +1,38 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// lockDevice takes an exclusive lock on device's lock file for as long as the
+// daemon runs, so two instances don't interleave their frames on it. It fails
+// naming the process holding the lock. An empty C.LockDir turns locking off.
+func lockDevice(device string) error {
+	if C.LockDir == "" {
+		return nil
+	}
+	path := lockPath(device)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("lock %s: %v", device, err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		holder, _ := os.ReadFile(path)
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return fmt.Errorf("%s is already driven by another instance, pid %s per %s", device, strings.TrimSpace(string(holder)), path)
+		}
+		return fmt.Errorf("lock %s: %v", device, err)
+	}
+	_ = f.Truncate(0)
+	_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	locks = append(locks, f)
+	return nil
+}
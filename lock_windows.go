@@ -0,0 +1,4 @@
+package main
+
+// lockDevice does nothing, Windows has no flock.
+func lockDevice(device string) error { return nil }
@@ -0,0 +1,111 @@
+// Package loglimit wraps limlog so the limiters can be replaced at runtime and
+// suppressed lines are counted for periodic summaries.
+package loglimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jar-o/limlog"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Limit allows Lines log lines per Interval with bursts of up to Burst.
+type Limit struct {
+	Lines    float64
+	Interval time.Duration
+	Burst    int
+}
+
+type Logger struct {
+	*limlog.Limlog
+
+	mu         sync.Mutex
+	limiters   map[string]*rate.Limiter
+	suppressed map[string]int
+}
+
+func New(l *limlog.Limlog) *Logger {
+	return &Logger{
+		Limlog:     l,
+		limiters:   map[string]*rate.Limiter{},
+		suppressed: map[string]int{},
+	}
+}
+
+// SetLimiter sets, or replaces, the limit of a named limiter.
+func (l *Logger) SetLimiter(limiter string, logLines float64, interval time.Duration, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if burst < 1 {
+		burst = 1
+	}
+	l.limiters[limiter] = rate.NewLimiter(rate.Limit(logLines/interval.Seconds()), burst)
+}
+
+// SetLimit is SetLimiter taking a Limit.
+func (l *Logger) SetLimit(limiter string, limit Limit) {
+	l.SetLimiter(limiter, limit.Lines, limit.Interval, limit.Burst)
+}
+
+// allow reports whether a line may be logged, counting it as suppressed if
+// not. Lines for limiters that were never set are logged, unlike limlog.
+func (l *Logger) allow(limiter string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lim, ok := l.limiters[limiter]
+	if !ok || lim.Allow() {
+		return true
+	}
+	l.suppressed[limiter]++
+	return false
+}
+
+func (l *Logger) ErrorL(limiter string, v ...interface{}) {
+	if l.allow(limiter) {
+		l.L.Error(v...)
+	}
+}
+
+func (l *Logger) WarnL(limiter string, v ...interface{}) {
+	if l.allow(limiter) {
+		l.L.Warn(v...)
+	}
+}
+
+func (l *Logger) InfoL(limiter string, v ...interface{}) {
+	if l.allow(limiter) {
+		l.L.Info(v...)
+	}
+}
+
+func (l *Logger) DebugL(limiter string, v ...interface{}) {
+	if l.allow(limiter) {
+		l.L.Debug(v...)
+	}
+}
+
+// Summarize logs how many lines each limiter suppressed since the last call.
+func (l *Logger) Summarize() {
+	l.mu.Lock()
+	counts := l.suppressed
+	l.suppressed = map[string]int{}
+	l.mu.Unlock()
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l.L.Warn("Suppressed similar messages", zap.String("limiter", name), zap.Int("count", counts[name]))
+	}
+}
+
+// SummaryLoop calls Summarize every interval.
+func (l *Logger) SummaryLoop(interval time.Duration) {
+	for range time.Tick(interval) {
+		l.Summarize()
+	}
+}
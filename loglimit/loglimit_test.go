@@ -0,0 +1,35 @@
+package loglimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jar-o/limlog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSuppressedSummary(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := New(limlog.NewLimlogWithZap(zap.New(core)))
+	l.SetLimiter("dbus", 1, time.Hour, 1)
+	for i := 0; i < 5; i++ {
+		l.ErrorL("dbus", "Failed to get property:")
+	}
+	l.InfoL("unset", "Logged without a limiter")
+	if got := logs.FilterMessage("Failed to get property:").Len(); got != 1 {
+		t.Errorf("logged %d lines, want 1", got)
+	}
+	if got := logs.FilterMessage("Logged without a limiter").Len(); got != 1 {
+		t.Errorf("unset limiter logged %d lines, want 1", got)
+	}
+	l.Summarize()
+	summary := logs.FilterMessage("Suppressed similar messages").All()
+	if len(summary) != 1 || summary[0].ContextMap()["count"] != int64(4) {
+		t.Errorf("summary = %v, want 4 suppressed", summary)
+	}
+	l.Summarize()
+	if got := logs.FilterMessage("Suppressed similar messages").Len(); got != 1 {
+		t.Errorf("empty summary logged")
+	}
+}
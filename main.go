@@ -1,65 +1,145 @@
 package main // github.com/shift/systemd-status-leds
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
 	systemd "github.com/coreos/go-systemd/v22/dbus" // change namespace
 	systemdUtil "github.com/coreos/go-systemd/v22/util"
-	"github.com/godbus/dbus/v5" // namespace collides with systemd wrapper
 	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"github.com/shift/systemd-status-leds/monitor"
 	"github.com/shift/systemd-status-leds/strip"
 
 	"github.com/jar-o/limlog"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
-type Service struct {
-	Unit   string            `mapstructure:"name"`
-	States map[string]string `mapstrcture:"states_map"`
-}
-
-type Config struct {
-	Services []Service `mapstructure:"services"`
-	Strip    struct {
-		Length   int
-		Channels int
-		Hertz    int
-		Spidev   string
-		colours  map[string]string
-	}
-}
-
 var (
-	logr *limlog.Limlog
+	logr *loglimit.Logger
 	C    Config
+	sysd *systemd.Conn
+
+	states = []string{"active", "inactive", "reloading", "failed", "activating", "deactivating", "masked", "disabled", "skipped", "watchdog", "armed", "absent", "missing"}
 )
 
-func Configuration() {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	err := viper.ReadInConfig()
-	if err != nil {
-		logr.Panic("config file", zap.Error(err))
-	}
-	err = viper.Unmarshal(&C)
-	if err != nil {
-		logr.Panic("config file", zap.Error(err))
+func knownState(state string) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
 	}
+	return false
 }
 
 func main() {
 	// First thigns first, logging...
 	cfg := limlog.NewZapConfigWithLevel(zap.DebugLevel)
-	logr = limlog.NewLimlogZapWithConfig(cfg)
+	logr = loglimit.New(limlog.NewLimlogZapWithConfig(cfg))
 	z := logr.L.GetLogger().(*zap.Logger)
 	defer z.Sync()
 
-	Configuration()
+	cmd, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "run":
+		run(args)
+	case "validate":
+		os.Exit(validate(args))
+	case "simulate":
+		os.Exit(simulate(args))
+	case "demo":
+		os.Exit(demo(args))
+	case "mirror":
+		os.Exit(mirror(args))
+	case "doctor":
+		os.Exit(doctor(args))
+	case "init":
+		os.Exit(initConfig(args))
+	case "list-devices":
+		os.Exit(listDevices())
+	case "calibrate":
+		os.Exit(calibrate(args))
+	case "legend":
+		os.Exit(legend(args))
+	case "snapshot":
+		os.Exit(snapshot(args))
+	case "history":
+		os.Exit(history(args))
+	case "ctl":
+		flags := flag.NewFlagSet("ctl", flag.ExitOnError)
+		path := flags.String("config", "", "configuration file")
+		_ = flags.Parse(args)
+		Configuration(*path)
+		action := strings.Join(flags.Args(), " ")
+		if err := ctl(C.Control.Socket, action); err != nil {
+			logr.Fatal("ctl", zap.String("action", action), zap.Error(err))
+		}
+	case "status":
+		flags := flag.NewFlagSet("status", flag.ExitOnError)
+		path := flags.String("config", "", "configuration file")
+		_ = flags.Parse(args)
+		Configuration(*path)
+		if err := status(C.Control.Socket); err != nil {
+			logr.Fatal("status", zap.Error(err))
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected run, init, validate, simulate, demo, mirror, doctor, calibrate, legend, list-devices, ctl, status, snapshot or history\n", cmd)
+		os.Exit(2)
+	}
+}
+
+func run(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	path := flags.String("config", "", "configuration file")
+	record := flags.String("record", "", "write every unit state received to this replay file")
+	once := flags.Bool("oneshot", false, "show the current states in a single frame and exit, leaving the strip lit")
+	_ = flags.Parse(args)
+
+	Configuration(*path)
+	configureLimits(C.Log)
+	var render monitor.Renderer = renderer{}
+	var hist *historian
+	if C.History.File != "" {
+		h, err := openHistory(C.History.File, render)
+		if err != nil {
+			logr.Panic("unable to open the history file", zap.Error(err))
+		}
+		go h.pruneLoop(C.History.Retention)
+		hist, render = h, h
+	}
+	if len(C.Notifiers) > 0 {
+		render = newNotifying(C.Notifiers, render)
+	}
+	if C.HTTP.Listen != "" {
+		stream = newEventStream(render)
+		render = stream
+	}
+	if *record != "" {
+		f, err := os.OpenFile(*record, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			logr.Panic("unable to open the record file", zap.Error(err))
+		}
+		defer f.Close()
+		render = newRecorder(f, render)
+	}
+	z := logr.L.GetLogger().(*zap.Logger)
 	z.Info("Strip",
-		zap.String("spidev", C.Strip.Spidev),
+		zap.String("backend", C.Strip.Backend),
+		zap.String("device", *C.Strip.port()),
 		zap.Int("length", C.Strip.Length),
 		zap.Int("channels", C.Strip.Channels),
 		zap.Int("hertz", C.Strip.Hertz),
+		zap.Int("max_milliamps", C.Strip.MaxMilliamps),
+		zap.Duration("interval", C.Strip.Interval),
+		zap.Bool("dither", C.Strip.Dither),
 	)
 	for _, service := range C.Services {
 		z.Info("Service",
@@ -67,106 +147,356 @@ func main() {
 		)
 	}
 
-	strip, err := strip.Init(logr, &C.Strip.Spidev, &C.Strip.Length, &C.Strip.Channels, &C.Strip.Hertz)
-
+	strip, extras, err := initStrips()
 	if err != nil {
-		logr.Panic("unable to initalise the strip", zap.Error(err))
+		logr.Fatal("unable to initalise the strips", zap.Error(err))
+	}
+	strips := allStrips(strip, extras)
+
+	if C.OTLP.Endpoint != "" {
+		go otlpLoop(C.OTLP)
+	}
+	if C.HTTP.Listen != "" {
+		go httpLoop(C.HTTP, strip)
+	}
+
+	if len(C.Thermal.Thresholds) > 0 {
+		go thermalLoop(strips, C.Thermal)
 	}
 
 	if !systemdUtil.IsRunningSystemd() {
 		logr.Panic("systemd is not running", zap.Error(err))
 	}
 
-	conn, err := systemd.New()
-
+	conn, poll, err := C.Systemd.connect()
 	if err != nil {
 		logr.Panic("systemd unable to connect, running as root?", zap.Error(err))
 	}
-	err = conn.Subscribe()
-	if err != nil {
-		logr.Panic("systemd subscribed failed", zap.Error(err))
+	if pixel := layout(strip, extras); pixel != nil {
+		go heartbeatLoop(conn, &eventLoop, strips, pixel, C.Heartbeat)
+	}
+	if C.Profile != "" {
+		_ = switchProfile(strips, C.Profile)
+	}
+	if C.StateFile != "" {
+		restoreState(strips, C.StateFile)
+	}
+	if *once {
+		if err := oneshot(conn, render, strips); err != nil {
+			logr.Fatal("oneshot", zap.Error(err))
+		}
+		return
+	}
+	if C.StateFile != "" {
+		go persistLoop(strips, C.StateFile, watchChanges())
+	}
+	if hist != nil && C.History.Uptime.Mode != "" {
+		go uptimeLoop(strips, hist, C.History.Uptime)
+	}
+	if C.ExportFile != "" {
+		go exportLoop(strip, C.ExportFile, watchChanges())
+	}
+	if C.External.Stdin {
+		go func() {
+			if err := readExternal(os.Stdin, render); err != nil {
+				logr.Error("Unable to read external events from stdin", zap.Error(err))
+			}
+		}()
+	}
+	if C.External.Fifo != "" {
+		go fifoLoop(C.External.Fifo, render)
 	}
-	set := conn.NewSubscriptionSet() // no error should be returned
 	for _, service := range C.Services {
-		pixel, err := strip.Add(service.Unit)
-		if err != nil {
-			logr.Panic("Error calling Strip.Add:", zap.Error(err))
+		if service.Source == "file" {
+			go fileLoop(service, render)
 		}
-		go addService(conn, set, pixel)
 	}
-	strip.UpdateLoop()
+	go func() {
+		_ = monitor.Run(context.Background(), monitorConfig(conn, poll), render)
+	}()
+	go profileLoop(strips)
+	if C.PowerSave.Enabled && C.PowerSave.Sweep > 0 {
+		go sweep(strips, C.PowerSave.Sweep)
+	}
+	if len(C.Escalation) > 0 {
+		go escalationLoop(strips)
+	}
+	if C.Decay.Over > 0 {
+		go decayLoop(strips, C.Decay)
+	}
+	if C.SelfTest.At != "" {
+		go selfTestLoop(strip, C.SelfTest)
+	}
+	if C.Sleep.Idle > 0 {
+		go sleepLoop(strips, C.Sleep)
+	}
+	if C.Button.Pin != "" {
+		go buttonLoop(strips, C.Button)
+	}
+	if C.Control.Socket != "" {
+		go controlLoop(strips, C.Control)
+	}
+	go closeOnStop(strip, extras)
+	runStrips(strip, extras)
+}
+
+// monitorConfig follows the configured units over conn, polling them every
+// poll if set.
+func monitorConfig(conn monitor.Conn, poll time.Duration) monitor.Config {
+	return monitor.Config{Conn: conn, Units: systemdUnits(), Templates: templateUnits(), Logger: logr, StatusText: statusTextUnits(), Watchdog: watchdogUnits(), Intervals: checkIntervals(), Slices: sliceUnits(), Poll: poll, Timeout: C.Systemd.Timeout, Alive: eventLoop.beat}
+}
+
+// allStrips are the main strip and the extras, in the configured order.
+func allStrips(s *strip.Strip, extras map[string]*strip.Strip) []*strip.Strip {
+	strips := []*strip.Strip{s}
+	for _, c := range C.Strips {
+		strips = append(strips, extras[c.Name])
+	}
+	return strips
+}
+
+// pixelsOf are the unit pixels of every strip.
+func pixelsOf(strips []*strip.Strip) []*led.Led {
+	var pixels []*led.Led
+	for _, s := range strips {
+		pixels = append(pixels, s.Pixels...)
+	}
+	return pixels
+}
 
+// runStrips drives the main strip and the extras from one frame clock, until
+// they are closed.
+func runStrips(s *strip.Strip, extras map[string]*strip.Strip) {
+	strip.Run(allStrips(s, extras)...)
 }
 
-func addService(conn *systemd.Conn, set *systemd.SubscriptionSet, pixelRef *led.Led) {
-	subChannel, subErrors := set.Subscribe()
-	pixel := *pixelRef
-	var svc = pixel.Unit
-	var activeSet = false
-	var invalid = false
-	var previous bool
-	for {
-		previous = invalid
-		invalid = false
-		loadstate, err := conn.GetUnitProperty(svc, "LoadState")
+// closeOnStop closes every strip once the daemon is told to stop, leaving
+// them dark, which ends runStrips.
+func closeOnStop(s *strip.Strip, extras map[string]*strip.Strip) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, stopSignals...)
+	sig := <-signals
+	logr.Info("Stopping", zap.String("signal", sig.String()))
+	for _, s := range allStrips(s, extras) {
+		if err := s.Close(); err != nil {
+			logr.Error("Unable to close the strip", zap.String("strip", s.Name), zap.Error(err))
+		}
+	}
+}
+
+// initStrips locks and opens the main strip and the extras.
+func initStrips() (*strip.Strip, map[string]*strip.Strip, error) {
+	extras := map[string]*strip.Strip{}
+	for i := range C.Strips {
+		c := &C.Strips[i]
+		if err := lockDevice(*c.port()); err != nil {
+			return nil, nil, err
+		}
+		extra, err := strip.Init(logr, c.port(), &c.Length, &c.Channels, &c.Hertz, c.Opts())
 		if err != nil {
-			logr.Error("Failed to get property:", zap.Error(err))
-			invalid = true
+			return nil, nil, fmt.Errorf("%s: %v", c.Name, err)
 		}
+		extras[c.Name] = extra
+	}
+	if err := lockDevice(*C.Strip.port()); err != nil {
+		return nil, nil, err
+	}
+	s, err := strip.Init(logr, C.Strip.port(), &C.Strip.Length, &C.Strip.Channels, &C.Strip.Hertz, C.Strip.Opts())
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, extras, nil
+}
+
+// standalone sets up the strips for a command showing states that don't come
+// from systemd, with the heartbeat following conn.
+func standalone(conn interface{ Connected() bool }) (*strip.Strip, map[string]*strip.Strip, error) {
+	s, extras, err := initStrips()
+	if err != nil {
+		return nil, nil, err
+	}
+	strips := allStrips(s, extras)
+	if pixel := layout(s, extras); pixel != nil {
+		go heartbeatLoop(conn, nil, strips, pixel, C.Heartbeat)
+	}
+	if C.Profile != "" {
+		_ = switchProfile(strips, C.Profile)
+	}
+	if len(C.Escalation) > 0 {
+		go escalationLoop(strips)
+	}
+	if C.Decay.Over > 0 {
+		go decayLoop(strips, C.Decay)
+	}
+	if C.PowerSave.Enabled && C.PowerSave.Sweep > 0 {
+		go sweep(strips, C.PowerSave.Sweep)
+	}
+	return s, extras, nil
+}
 
-		if !invalid {
-			var notFound = (loadstate.Value == dbus.MakeVariant("not-found"))
-			if notFound {
-				logr.Info("Failed to find service")
-				invalid = true
+// layout adds the segments and the configured services to the main strip, or
+// the extra strip a service names, returning the heartbeat pixel if one is
+// configured.
+func layout(strip *strip.Strip, extras map[string]*strip.Strip) (heartbeat *led.Led) {
+	var err error
+	for _, segment := range C.Segments {
+		if err := strip.AddSegment(segment); err != nil {
+			logr.Panic("Error calling Strip.AddSegment:", zap.Error(err))
+		}
+	}
+	if C.Heartbeat.Pixel > 0 {
+		heartbeat, err = strip.Reserve("heartbeat", C.Heartbeat.Pixel)
+		if err != nil {
+			logr.Panic("Error calling Strip.Reserve:", zap.Error(err))
+		}
+	}
+	if C.Timesync.Pixel > 0 {
+		pixel, err := strip.Reserve("timesync", C.Timesync.Pixel)
+		if err != nil {
+			logr.Panic("Error calling Strip.Reserve:", zap.Error(err))
+		}
+		go timesyncLoop(pixel, C.Timesync)
+	}
+	rulePixels := map[int]*led.Led{}
+	for _, r := range C.Rules {
+		if rulePixels[r.Pixel] == nil {
+			if rulePixels[r.Pixel], err = strip.Reserve("rule", r.Pixel); err != nil {
+				logr.Panic("Error calling Strip.Reserve:", zap.Error(err))
 			}
 		}
-
-		if previous != invalid { // if invalid changed, send signal
+	}
+	compositePixels := map[string]*led.Led{}
+	for _, composite := range C.Composites {
+		if compositePixels[composite.Name], err = strip.Reserve(composite.Name, composite.Pixel); err != nil {
+			logr.Panic("Error calling Strip.Reserve:", zap.Error(err))
 		}
-
-		if invalid {
-			logr.Info("Waiting for service")
-			if activeSet {
-				activeSet = false
-				set.Remove(svc) // no return value should ever occur
+	}
+	if C.Clock.Segment != "" {
+		pixels, err := strip.ReserveSegment("clock", C.Clock.Segment)
+		if err != nil {
+			logr.Panic("Error calling Strip.ReserveSegment:", zap.Error(err))
+		}
+		go clockLoop(pixels, C.Clock)
+	}
+	for _, service := range C.Services {
+		var pixel *led.Led
+		on := strip
+		if extra := extras[service.Strip]; extra != nil {
+			on = extra
+		}
+		if service.Instances == "spread" {
+			pixels, err := on.ReserveSegment(service.Unit, service.Segment)
+			if err != nil {
+				logr.Panic("Error calling Strip.ReserveSegment:", zap.Error(err))
 			}
+			addInstances(service, nil, pixels)
+			continue
+		}
+		if service.Segment != "" {
+			pixel, err = on.AddTo(service.Unit, service.Segment)
+		} else {
+			pixel, err = on.Add(service.Unit)
+		}
+		if err != nil {
+			logr.Panic("Error calling Strip.Add:", zap.Error(err))
+		}
+		if isTemplate(service.Unit) {
+			addInstances(service, pixel, nil)
+			continue
+		}
+		track(pixel, service)
+	}
+	addRules(C.Rules, rulePixels)
+	addComposites(C.Composites, compositePixels)
+	return heartbeat
+}
+
+// renderer shows unit states on the tracked pixels.
+type renderer struct{}
 
+func (renderer) Render(e monitor.Event) {
+	if e.Gone {
+		if t := templates[e.Template]; t != nil {
+			t.remove(e.Unit)
+		}
+		return
+	}
+	if !knownState(e.State) {
+		logr.Error("Unknown service statre", zap.String("event", e.State))
+		return
+	}
+	state := displayState(e)
+	setStatusText(e.Unit, e.StatusText)
+	setSliceActive(e.Unit, e.Active)
+	switch {
+	case e.State == "failed" && !e.Initial:
+		watchHandlers(e.Unit)
+	case e.State == "activating" || e.State == "active":
+		handlerStarted(e.Unit)
+	}
+	pixels := tracked
+	if t := templates[monitor.Template(e.Unit)]; t != nil {
+		pixels = nil
+		if pixel := t.pixel(e.Unit); pixel != nil {
+			pixels = []*led.Led{pixel}
+		}
+	}
+	for _, pixel := range pixels {
+		if pixel.Unit != e.Unit {
+			continue
+		}
+		pixel.SetSubState(e.SubState)
+		if e.Initial {
+			setState(pixel, state)
 		} else {
-			if !activeSet {
-				activeSet = true
-				set.Add(svc) // no return value should ever occur
-			}
+			showState(pixel, state)
+		}
+	}
+}
 
-			select {
-			case event := <-subChannel:
-				if event[svc] != nil {
-					switch event[svc].ActiveState {
-					case "active":
-						pixelRef.SetColour(C.Strip.colours["active"])
-					case "inactive":
-						pixelRef.SetColour("44000005")
-						pixelRef.SetColour(C.Strip.colours["inactive"])
-					case "reloading":
-						pixelRef.SetColour("60606060")
-						pixelRef.SetColour(C.Strip.colours["reloading"])
-					case "failed":
-						pixelRef.SetColour("99000000")
-						pixelRef.SetColour(C.Strip.colours["failed"])
-					case "activating":
-						pixelRef.SetColour("00330010")
-						pixelRef.SetColour(C.Strip.colours["activating"])
-					case "deactivating":
-						pixelRef.SetColour("22000010")
-						pixelRef.SetColour(C.Strip.colours["deactivating"])
-					default:
-						logr.Error("Unknown service statre", zap.String("event", event[svc].ActiveState))
-					}
-				}
-
-			case err := <-subErrors:
-				logr.Error("Unknown error, changes to systemd?", zap.Error(err))
+// optionalUnit reports whether unit is shown by an optional service.
+func optionalUnit(unit string) bool {
+	for _, service := range C.Services {
+		if !service.Optional {
+			continue
+		}
+		if service.serves(unit) {
+			return true
+		}
+		for _, member := range service.Units {
+			if member == unit {
+				return true
 			}
 		}
 	}
+	return false
+}
+
+// displayState tells masked, condition skipped and disabled units apart from
+// other inactive ones, shows services restarted by or about to miss their
+// watchdog, and path and automount units waiting to trigger as armed rather
+// than running. A unit systemd doesn't know is absent if it is optional and
+// missing if not.
+func displayState(e monitor.Event) string {
+	switch {
+	case e.NotFound && optionalUnit(e.Unit):
+		return "absent"
+	case e.NotFound:
+		return "missing"
+	case e.State == "active" && e.SubState == "waiting" && (strings.HasSuffix(e.Unit, ".path") || strings.HasSuffix(e.Unit, ".automount")):
+		return "armed"
+	case e.WatchdogLate:
+		return "watchdog"
+	case e.Result == "watchdog" && (e.State == "inactive" || e.State == "activating"):
+		return "watchdog"
+	case e.State != "inactive":
+		return e.State
+	case e.UnitFileState == "masked":
+		return "masked"
+	case e.ConditionFailed:
+		return "skipped"
+	case e.UnitFileState == "disabled":
+		return "disabled"
+	}
+	return e.State
 }
@@ -0,0 +1,471 @@
+// Package mdns announces a service on the local network with multicast DNS
+// (RFC 6762) and DNS-SD (RFC 6763), answering queries for it until stopped.
+package mdns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Service is an instance of a DNS-SD service, such as "kitchen" of
+// "_statusleds._tcp", on Port of Host.local at IPs. Renamed, if set, is told
+// of the names Announce takes instead when another host answers for these.
+type Service struct {
+	Instance string
+	Service  string
+	Host     string
+	Port     int
+	Text     []string
+	IPs      []net.IP
+	Renamed  func(Service)
+}
+
+// TTL is how long the records may be cached.
+const TTL = 120
+
+var group = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// DNS record types and classes.
+const (
+	typeA   = 1
+	typePTR = 12
+	typeTXT = 16
+	typeSRV = 33
+	typeANY = 255
+
+	classIN = 1
+	// cacheFlush marks records only this host answers for.
+	cacheFlush = 0x8000
+)
+
+const (
+	// probeWait is the time between probes, and after the last before the
+	// names are taken as free.
+	probeWait = 250 * time.Millisecond
+	// probes is how many probes are sent for a set of names.
+	probes = 3
+	// maxRenames is how many times the names are changed before giving up.
+	maxRenames = 10
+)
+
+// servicesName lists the services on the network, for DNS-SD's service type
+// enumeration (RFC 6763 section 9).
+const servicesName = "_services._dns-sd._udp.local."
+
+func (s Service) serviceName() string  { return s.Service + ".local." }
+func (s Service) instanceName() string { return s.Instance + "." + s.serviceName() }
+func (s Service) hostName() string     { return s.Host + ".local." }
+
+// packet is a message received on conn.
+type packet struct {
+	msg  []byte
+	from *net.UDPAddr
+	conn *net.UDPConn
+}
+
+// Announce answers queries for s on every up, multicast capable interface
+// until ctx is done. It first probes for the names of s (RFC 6762 section
+// 8), numbering them while another host answers for them, then announces it,
+// and says goodbye at the end.
+func Announce(ctx context.Context, s Service) error {
+	conns, err := listen()
+	if err != nil {
+		return err
+	}
+	packets := make(chan packet)
+	lost := make(chan error, len(conns))
+	done := make(chan struct{})
+	for _, conn := range conns {
+		defer conn.Close()
+		go receive(conn, packets, lost, done)
+	}
+	defer close(done)
+	alive := len(conns)
+
+	if s, err = probe(ctx, s, conns, packets); err != nil || ctx.Err() != nil {
+		return err
+	}
+	if err := multicast(conns, s.response(0, TTL)); err != nil {
+		return err
+	}
+	// The announcement is repeated once, a second later.
+	again := time.After(time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			// Goodbye: the records with a TTL of 0, so they are forgotten.
+			_ = multicast(conns, s.response(0, 0))
+			return nil
+		case <-again:
+			if err := multicast(conns, s.response(0, TTL)); err != nil {
+				return err
+			}
+		case err := <-lost:
+			if alive--; alive == 0 {
+				return err
+			}
+		case p := <-packets:
+			id, ok := s.asked(p.msg)
+			if !ok {
+				continue
+			}
+			to := group
+			if p.from.Port != group.Port {
+				// A one-shot query, answered directly.
+				to = p.from
+			} else {
+				id = 0
+			}
+			_, _ = p.conn.WriteToUDP(s.response(id, TTL), to)
+		}
+	}
+}
+
+// listen joins the group on every up, multicast capable interface with an
+// IPv4 address, or on the system's default one if none can.
+func listen() ([]*net.UDPConn, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var conns []*net.UDPConn
+	for i := range ifaces {
+		ifi := &ifaces[i]
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 || !hasIPv4(ifi) {
+			continue
+		}
+		conn, err := net.ListenMulticastUDP("udp4", ifi, group)
+		if err != nil {
+			continue
+		}
+		if err := ownInterfaceOnly(conn); err != nil {
+			conn.Close()
+			continue
+		}
+		conns = append(conns, conn)
+	}
+	if len(conns) == 0 {
+		conn, err := net.ListenMulticastUDP("udp4", nil, group)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
+
+func hasIPv4(ifi *net.Interface) bool {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// receive passes on the messages conn receives until done is closed, or
+// until it fails, passing on the error to lost.
+func receive(conn *net.UDPConn, packets chan<- packet, lost chan<- error, done <-chan struct{}) {
+	for {
+		buf := make([]byte, 9000)
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			lost <- err
+			return
+		}
+		select {
+		case packets <- packet{msg: buf[:n], from: from, conn: conn}:
+		case <-done:
+			return
+		}
+	}
+}
+
+// multicast sends msg to the group on every interface, failing only if it
+// reaches none of them.
+func multicast(conns []*net.UDPConn, msg []byte) error {
+	var errs []error
+	for _, conn := range conns {
+		if _, err := conn.WriteToUDP(msg, group); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(conns) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// probe returns s with names no other host answers for: as they are, or
+// numbered, "kitchen (2)" on "pi-2.local." and so on, if they are taken.
+func probe(ctx context.Context, s Service, conns []*net.UDPConn, packets <-chan packet) (Service, error) {
+	instance, host := s.Instance, s.Host
+	for n := 1; ; n++ {
+		takenInstance, takenHost, err := s.probe(ctx, conns, packets)
+		if err != nil || ctx.Err() != nil || !takenInstance && !takenHost {
+			return s, err
+		}
+		if n > maxRenames {
+			return s, fmt.Errorf("mdns: %s and %s are taken", s.instanceName(), s.hostName())
+		}
+		if takenInstance {
+			s.Instance = fmt.Sprintf("%s (%d)", instance, n+1)
+		}
+		if takenHost {
+			s.Host = fmt.Sprintf("%s-%d", host, n+1)
+		}
+		if s.Renamed != nil {
+			s.Renamed(s)
+		}
+	}
+}
+
+// probe queries for the names of s three times, probeWait apart, and
+// reports which of them another host answers for.
+func (s Service) probe(ctx context.Context, conns []*net.UDPConn, packets <-chan packet) (instance, host bool, err error) {
+	query := s.probeQuery()
+	// A random delay first, so hosts starting together don't probe in step.
+	next := time.NewTimer(time.Duration(rand.Int63n(int64(probeWait))))
+	defer next.Stop()
+	for sent := 0; ; {
+		select {
+		case <-ctx.Done():
+			return false, false, nil
+		case <-next.C:
+			if sent == probes {
+				return false, false, nil
+			}
+			if err := multicast(conns, query); err != nil {
+				return false, false, err
+			}
+			sent++
+			next.Reset(probeWait)
+		case p := <-packets:
+			if instance, host = s.taken(p.msg); instance || host {
+				return instance, host, nil
+			}
+		}
+	}
+}
+
+// taken reports whether msg is a response from another host with records
+// for the instance or host name of s. Records just like those of s, as
+// from this host on another interface, are no conflict.
+func (s Service) taken(msg []byte) (instance, host bool) {
+	if len(msg) < 12 || msg[2]&0x80 == 0 {
+		return false, false
+	}
+	answers, err := parseRecords(msg)
+	if err != nil {
+		return false, false
+	}
+	ours := map[string]bool{}
+	records, _ := parseRecords(s.probeQuery())
+	for _, r := range records {
+		ours[r.key()] = true
+	}
+	for _, r := range answers {
+		if ours[r.key()] {
+			continue
+		}
+		instance = instance || strings.EqualFold(r.owner, s.instanceName())
+		host = host || strings.EqualFold(r.owner, s.hostName())
+	}
+	return instance, host
+}
+
+// asked reports whether msg is a query for s, and its ID.
+func (s Service) asked(msg []byte) (uint16, bool) {
+	if len(msg) < 12 || msg[2]&0x80 != 0 {
+		return 0, false
+	}
+	id := binary.BigEndian.Uint16(msg)
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	off := 12
+	for i := 0; i < questions; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil || next+4 > len(msg) {
+			return 0, false
+		}
+		qtype := binary.BigEndian.Uint16(msg[next:])
+		off = next + 4
+		switch {
+		case strings.EqualFold(name, s.serviceName()) && (qtype == typePTR || qtype == typeANY),
+			strings.EqualFold(name, servicesName) && (qtype == typePTR || qtype == typeANY),
+			strings.EqualFold(name, s.instanceName()) && (qtype == typeSRV || qtype == typeTXT || qtype == typeANY),
+			strings.EqualFold(name, s.hostName()) && (qtype == typeA || qtype == typeANY):
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// response is an answer with every record of s.
+func (s Service) response(id uint16, ttl uint32) []byte {
+	answers := [][]byte{
+		record(s.serviceName(), typePTR, classIN, ttl, name(s.instanceName())),
+		record(servicesName, typePTR, classIN, ttl, name(s.serviceName())),
+	}
+	answers = append(answers, s.unique(classIN|cacheFlush, ttl)...)
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg, id)
+	binary.BigEndian.PutUint16(msg[2:], 0x8400) // response, authoritative
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(answers)))
+	for _, a := range answers {
+		msg = append(msg, a...)
+	}
+	return msg
+}
+
+// probeQuery asks for any record of the instance and host names, with the
+// records s would take for them as its authority section.
+func (s Service) probeQuery() []byte {
+	authority := s.unique(classIN, TTL)
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[4:], 2)
+	binary.BigEndian.PutUint16(msg[8:], uint16(len(authority)))
+	for _, n := range []string{s.instanceName(), s.hostName()} {
+		msg = append(msg, name(n)...)
+		msg = binary.BigEndian.AppendUint16(msg, typeANY)
+		msg = binary.BigEndian.AppendUint16(msg, classIN)
+	}
+	for _, a := range authority {
+		msg = append(msg, a...)
+	}
+	return msg
+}
+
+// unique is the records of s only this host answers for: SRV, TXT and A.
+func (s Service) unique(class uint16, ttl uint32) [][]byte {
+	srv := binary.BigEndian.AppendUint16(make([]byte, 4), uint16(s.Port))
+	records := [][]byte{record(s.instanceName(), typeSRV, class, ttl, append(srv, name(s.hostName())...))}
+	var txt []byte
+	for _, t := range s.Text {
+		if len(t) > 255 {
+			t = t[:255]
+		}
+		txt = append(append(txt, byte(len(t))), t...)
+	}
+	if len(txt) == 0 {
+		txt = []byte{0}
+	}
+	records = append(records, record(s.instanceName(), typeTXT, class, ttl, txt))
+	for _, ip := range s.IPs {
+		if ip4 := ip.To4(); ip4 != nil {
+			records = append(records, record(s.hostName(), typeA, class, ttl, ip4))
+		}
+	}
+	return records
+}
+
+// resource is a record of a received message.
+type resource struct {
+	owner string
+	rtype uint16
+	data  []byte
+}
+
+// key tells records apart by owner, type and data, but not TTL or class.
+func (r resource) key() string {
+	return strings.ToLower(r.owner) + "/" + strconv.Itoa(int(r.rtype)) + "/" + string(r.data)
+}
+
+// parseRecords returns the records of every section of msg past its
+// questions.
+func parseRecords(msg []byte) ([]resource, error) {
+	if len(msg) < 12 {
+		return nil, errName
+	}
+	off := 12
+	for i := 0; i < int(binary.BigEndian.Uint16(msg[4:])); i++ {
+		_, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next + 4
+	}
+	count := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+	var records []resource
+	for i := 0; i < count; i++ {
+		owner, next, err := readName(msg, off)
+		if err != nil || next+10 > len(msg) {
+			return nil, errName
+		}
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		if next+10+length > len(msg) {
+			return nil, errName
+		}
+		records = append(records, resource{owner: owner, rtype: binary.BigEndian.Uint16(msg[next:]), data: msg[next+10 : next+10+length]})
+		off = next + 10 + length
+	}
+	return records, nil
+}
+
+func record(owner string, rtype, class uint16, ttl uint32, data []byte) []byte {
+	b := name(owner)
+	b = binary.BigEndian.AppendUint16(b, rtype)
+	b = binary.BigEndian.AppendUint16(b, class)
+	b = binary.BigEndian.AppendUint32(b, ttl)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// name encodes a domain name as labels, without compression.
+func name(n string) []byte {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(n, "."), ".") {
+		if len(label) > 63 {
+			label = label[:63]
+		}
+		b = append(append(b, byte(len(label))), label...)
+	}
+	return append(b, 0)
+}
+
+var errName = errors.New("mdns: malformed name")
+
+// readName decodes the name at off in msg, following compression pointers,
+// and returns it with the offset just past it.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errName
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(msg) || jumps > 10 {
+				return "", 0, errName
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+l > len(msg) {
+				return "", 0, errName
+			}
+			labels = append(labels, string(msg[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
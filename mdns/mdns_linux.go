@@ -0,0 +1,25 @@
+//go:build linux
+
+package mdns
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// ownInterfaceOnly has conn receive only what arrives on the interface it
+// joined the group on, not on the others, as Linux does by default.
+func ownInterfaceOnly(conn *net.UDPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MULTICAST_ALL, 0)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
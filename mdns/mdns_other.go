@@ -0,0 +1,11 @@
+//go:build !linux
+
+package mdns
+
+import "net"
+
+// ownInterfaceOnly does nothing: elsewhere a socket only receives what
+// arrives for the groups it joined.
+func ownInterfaceOnly(conn *net.UDPConn) error {
+	return nil
+}
@@ -0,0 +1,131 @@
+package mdns
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func query(id uint16, qname string, qtype uint16) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg, id)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	msg = append(msg, name(qname)...)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	return binary.BigEndian.AppendUint16(msg, classIN)
+}
+
+func TestAsked(t *testing.T) {
+	s := Service{Instance: "kitchen", Service: "_statusleds._tcp", Host: "pi", Port: 9090}
+	for _, tc := range []struct {
+		name  string
+		qtype uint16
+		want  bool
+	}{
+		{"_statusleds._tcp.local.", typePTR, true},
+		{"_STATUSLEDS._tcp.local.", typePTR, true},
+		{"kitchen._statusleds._tcp.local.", typeSRV, true},
+		{"pi.local.", typeA, true},
+		{"_services._dns-sd._udp.local.", typePTR, true},
+		{"_http._tcp.local.", typePTR, false},
+		{"pi.local.", typeTXT, false},
+	} {
+		id, ok := s.asked(query(7, tc.name, tc.qtype))
+		if ok != tc.want || (ok && id != 7) {
+			t.Errorf("query %s %d: %v id %d, want %v", tc.name, tc.qtype, ok, id, tc.want)
+		}
+	}
+	response := s.response(0, TTL)
+	if _, ok := s.asked(response); ok {
+		t.Error("a response taken for a query")
+	}
+	if _, ok := s.asked([]byte{0, 0, 0}); ok {
+		t.Error("a truncated message taken for a query")
+	}
+}
+
+func TestResponse(t *testing.T) {
+	s := Service{Instance: "kitchen", Service: "_statusleds._tcp", Host: "pi", Port: 9090, Text: []string{"path=/"}, IPs: []net.IP{net.IPv4(192, 168, 1, 5), net.ParseIP("::1")}}
+	records, err := parseRecords(s.response(0, TTL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("%d answers, want two PTR, SRV, TXT and one A", len(records))
+	}
+	found := map[string][]byte{}
+	for _, r := range records {
+		found[r.owner+" "+strconv.Itoa(int(r.rtype))] = r.data
+	}
+	if target, _, _ := readName(found["_statusleds._tcp.local. 12"], 0); target != "kitchen._statusleds._tcp.local." {
+		t.Errorf("PTR %q", target)
+	}
+	if target, _, _ := readName(found[servicesName+" 12"], 0); target != "_statusleds._tcp.local." {
+		t.Errorf("service type PTR %q", target)
+	}
+	srv := found["kitchen._statusleds._tcp.local. 33"]
+	if port := binary.BigEndian.Uint16(srv[4:]); port != 9090 {
+		t.Errorf("SRV port %d, want 9090", port)
+	}
+	if target, _, _ := readName(srv, 6); target != "pi.local." {
+		t.Errorf("SRV target %q", target)
+	}
+	if txt := found["kitchen._statusleds._tcp.local. 16"]; string(txt) != "\x06path=/" {
+		t.Errorf("TXT %q", txt)
+	}
+	if a := found["pi.local. 1"]; !net.IP(a).Equal(net.IPv4(192, 168, 1, 5)) {
+		t.Errorf("A %v", net.IP(a))
+	}
+}
+
+func TestTaken(t *testing.T) {
+	s := Service{Instance: "kitchen", Service: "_statusleds._tcp", Host: "pi", Port: 9090, IPs: []net.IP{net.IPv4(192, 168, 1, 5)}}
+	other := s
+	other.Port = 8080
+	other.IPs = []net.IP{net.IPv4(192, 168, 1, 6)}
+	elsewhere := s
+	elsewhere.Instance = "hall"
+	for _, tc := range []struct {
+		name           string
+		msg            []byte
+		instance, host bool
+	}{
+		{"another host's records", other.response(0, TTL), true, true},
+		{"another instance", elsewhere.response(0, TTL), false, false},
+		{"our own records", s.response(0, TTL), false, false},
+		{"a probe", other.probeQuery(), false, false},
+	} {
+		instance, host := s.taken(tc.msg)
+		if instance != tc.instance || host != tc.host {
+			t.Errorf("%s: instance %v host %v, want %v %v", tc.name, instance, host, tc.instance, tc.host)
+		}
+	}
+}
+
+func TestProbeQuery(t *testing.T) {
+	s := Service{Instance: "kitchen", Service: "_statusleds._tcp", Host: "pi", Port: 9090, IPs: []net.IP{net.IPv4(192, 168, 1, 5)}}
+	msg := s.probeQuery()
+	if _, ok := s.asked(msg); !ok {
+		t.Error("the probe is no query for the service")
+	}
+	records, err := parseRecords(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Errorf("%d proposed records, want SRV, TXT and A", len(records))
+	}
+}
+
+func TestReadNameCompressed(t *testing.T) {
+	msg := append(name("local."), 2, 'p', 'i', 0xc0, 0)
+	got, next, err := readName(msg, 7)
+	if err != nil || got != "pi.local." || next != len(msg) {
+		t.Errorf("readName = %q, %d, %v", got, next, err)
+	}
+	loop := []byte{0xc0, 0}
+	if _, _, err := readName(loop, 0); err == nil {
+		t.Error("pointer loop accepted")
+	}
+}
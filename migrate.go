@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/viper"
+)
+
+// ConfigVersion is the config layout this build writes and expects. Files
+// without a version are version 1.
+const ConfigVersion = 3
+
+// migrations[v] upgrades raw settings from version v to v+1, returning a
+// deprecation warning for everything it had to change.
+var migrations = map[int]func(settings map[string]interface{}) []string{
+	1: quoteColours,
+	2: nestColours,
+}
+
+// migrate upgrades the settings viper read to ConfigVersion.
+func migrate(settings map[string]interface{}) (warnings []string, err error) {
+	version := 1
+	if v, ok := settings["version"]; ok {
+		if version, err = strconv.Atoi(fmt.Sprint(v)); err != nil {
+			return nil, fmt.Errorf("version: %q is not a number", v)
+		}
+	}
+	if version > ConfigVersion {
+		return nil, fmt.Errorf("version %d is newer than the supported %d", version, ConfigVersion)
+	}
+	if version < ConfigVersion {
+		warnings = append(warnings, fmt.Sprintf("config version %d is deprecated, migrating to %d; set version: %d", version, ConfigVersion, ConfigVersion))
+	}
+	for ; version < ConfigVersion; version++ {
+		warnings = append(warnings, migrations[version](settings)...)
+	}
+	settings["version"] = ConfigVersion
+	return warnings, nil
+}
+
+// loadSettings migrates what viper read and merges the result back.
+func loadSettings() ([]string, error) {
+	settings := viper.AllSettings()
+	warnings, err := migrate(settings)
+	if err != nil {
+		return nil, err
+	}
+	return warnings, viper.MergeConfigMap(settings)
+}
+
+// quoteColours recovers colours written without quotes, which YAML reads as
+// decimal or, with a leading zero, octal integers.
+func quoteColours(settings map[string]interface{}) []string {
+	var warnings []string
+	fix := func(where string, v interface{}) interface{} {
+		n, ok := v.(int)
+		if !ok {
+			return v
+		}
+		colour := strconv.Itoa(n)
+		if len(colour) != 8 {
+			colour = fmt.Sprintf("%08o", n)
+		}
+		warnings = append(warnings, fmt.Sprintf("%s: quote colour %s", where, colour))
+		return colour
+	}
+	fixMap := func(where string, v interface{}) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, colour := range m {
+			m[k] = fix(where+"."+k, colour)
+		}
+	}
+	fixKeys := func(where string, m map[string]interface{}, keys ...string) {
+		for _, k := range keys {
+			if v, ok := m[k]; ok {
+				m[k] = fix(where+"."+k, v)
+			}
+		}
+	}
+	section := func(k string) map[string]interface{} {
+		m, _ := settings[k].(map[string]interface{})
+		return m
+	}
+	list := func(k string) []map[string]interface{} {
+		var out []map[string]interface{}
+		l, _ := settings[k].([]interface{})
+		for _, v := range l {
+			if m, ok := v.(map[string]interface{}); ok {
+				out = append(out, m)
+			}
+		}
+		return out
+	}
+
+	if s := section("strip"); s != nil {
+		fixMap("strip.colours", s["colours"])
+	}
+	for _, k := range []string{"acknowledge", "flash"} {
+		if s := section(k); s != nil {
+			fixKeys(k, s, "colour")
+		}
+	}
+	if s := section("heartbeat"); s != nil {
+		fixKeys("heartbeat", s, "colour", "error_colour")
+	}
+	for _, service := range list("services") {
+		fixMap(fmt.Sprint(service["name"], ".states_map"), service["states_map"])
+	}
+	for _, seg := range list("segments") {
+		fixKeys(fmt.Sprint("segments.", seg["name"]), seg, "background", "separator")
+	}
+	for _, p := range list("profiles") {
+		where := fmt.Sprint("profiles.", p["name"])
+		fixMap(where+".colours", p["colours"])
+		if services, ok := p["services"].(map[string]interface{}); ok {
+			for unit, colours := range services {
+				fixMap(where+"."+unit, colours)
+			}
+		}
+	}
+	return warnings
+}
+
+// nestColours moves each service's flat states_map of colours into a
+// colours block per state.
+func nestColours(settings map[string]interface{}) []string {
+	var warnings []string
+	services, _ := settings["services"].([]interface{})
+	for _, v := range services {
+		service, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		states, ok := service["states_map"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		colours, _ := service["colours"].(map[string]interface{})
+		if colours == nil {
+			colours = map[string]interface{}{}
+		}
+		for state, colour := range states {
+			colours[state] = map[string]interface{}{"colour": colour}
+		}
+		service["colours"] = colours
+		delete(service, "states_map")
+		warnings = append(warnings, fmt.Sprintf("%v: states_map is replaced by colours", service["name"]))
+	}
+	return warnings
+}
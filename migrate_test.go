@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestMigrateQuotesColours(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(`
+strip:
+  colours:
+    active: 00ff0000
+    inactive: 01010101
+    failed: 55002200
+services:
+  - name: a.service
+    states_map:
+      active: 00442200
+`)); err != nil {
+		t.Fatal(err)
+	}
+	settings := v.AllSettings()
+	warnings, err := migrate(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) == 0 {
+		t.Error("no deprecation warnings")
+	}
+	colours := settings["strip"].(map[string]interface{})["colours"].(map[string]interface{})
+	for state, want := range map[string]string{"active": "00ff0000", "inactive": "01010101", "failed": "55002200"} {
+		if got := colours[state]; got != want {
+			t.Errorf("strip.colours.%s = %v, want %s", state, got, want)
+		}
+	}
+	service := settings["services"].([]interface{})[0].(map[string]interface{})
+	if got := service["colours"].(map[string]interface{})["active"].(map[string]interface{})["colour"]; got != "00442200" {
+		t.Errorf("colours.active.colour = %v, want 00442200", got)
+	}
+	if _, ok := service["states_map"]; ok {
+		t.Error("states_map kept")
+	}
+	if settings["version"] != ConfigVersion {
+		t.Errorf("version = %v, want %d", settings["version"], ConfigVersion)
+	}
+}
+
+func TestMigrateRejectsNewer(t *testing.T) {
+	if _, err := migrate(map[string]interface{}{"version": ConfigVersion + 1}); err == nil {
+		t.Error("newer version accepted")
+	}
+}
+
+func TestMigrateNestsColours(t *testing.T) {
+	settings := map[string]interface{}{
+		"version": 2,
+		"services": []interface{}{
+			map[string]interface{}{"name": "a.service", "states_map": map[string]interface{}{"failed": "ff000000"}},
+		},
+	}
+	if _, err := migrate(settings); err != nil {
+		t.Fatal(err)
+	}
+	service := settings["services"].([]interface{})[0].(map[string]interface{})
+	want := map[string]interface{}{"failed": map[string]interface{}{"colour": "ff000000"}}
+	if got := service["colours"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("colours = %v, want %v", got, want)
+	}
+}
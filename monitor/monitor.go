@@ -0,0 +1,686 @@
+// Package monitor follows the state of systemd units and reports every change
+// to a Renderer.
+package monitor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"go.uber.org/zap"
+)
+
+// Conn is the part of a systemd D-Bus connection the monitor uses.
+type Conn interface {
+	GetUnitPropertyContext(ctx context.Context, unit string, propertyName string) (*systemd.Property, error)
+	GetUnitPropertiesContext(ctx context.Context, unit string) (map[string]interface{}, error)
+	GetUnitTypePropertiesContext(ctx context.Context, unit string, unitType string) (map[string]interface{}, error)
+	ListUnitsByNamesContext(ctx context.Context, units []string) ([]systemd.UnitStatus, error)
+	ListUnitsByPatternsContext(ctx context.Context, states []string, patterns []string) ([]systemd.UnitStatus, error)
+	SubscribeUnitsCustom(interval time.Duration, buffer int, isChanged func(*systemd.UnitStatus, *systemd.UnitStatus) bool, filterUnit func(string) bool) (<-chan map[string]*systemd.UnitStatus, <-chan error)
+}
+
+// Signaller is a Conn passing on the property changes systemd signals, as a
+// go-systemd connection does once subscribed. Over one, changes are followed
+// from the signals rather than by listing every unit each second.
+type Signaller interface {
+	SetPropertiesSubscriber(updateCh chan<- *systemd.PropertiesUpdate, errCh chan<- error)
+}
+
+// signalBuffer is how many property changes may queue up before some are
+// dropped, and the units listed again to catch up.
+const signalBuffer = 256
+
+// Config selects the units to follow and how.
+type Config struct {
+	Conn  Conn
+	Units []string
+	// Templates are template units, such as getty@.service, whose instances
+	// are followed as they come and go.
+	Templates []string
+	Logger    *loglimit.Logger
+	// StatusText lists the services whose sd_notify STATUS= text is
+	// reported and Watchdog those whose watchdog pings are checked. Both are
+	// polled every PollInterval, DefaultPollInterval unless set, as they
+	// change without a state change.
+	StatusText   []string
+	Watchdog     []string
+	PollInterval time.Duration
+	// Intervals poll the units in it at their own interval rather than
+	// PollInterval, slower for checks that are expensive or rate limited.
+	Intervals map[string]time.Duration
+	// Slices lists the slices whose active units are counted, also every
+	// PollInterval.
+	Slices []string
+	// Poll, when set, lists the states of the units every Poll instead of
+	// subscribing to their changes, for when systemd refuses a
+	// subscription.
+	Poll time.Duration
+	// Timeout bounds every call to systemd, DefaultTimeout unless set.
+	Timeout time.Duration
+	// Alive, if set, is called every AliveInterval while Run follows the
+	// units and after each call to systemd, so a caller can tell it isn't
+	// stuck.
+	Alive func()
+
+	// aliases maps the canonical names of the units in Units that are
+	// aliases to the names they are followed by.
+	aliases map[string]string
+}
+
+const (
+	DefaultPollInterval = 30 * time.Second
+	DefaultTimeout      = 5 * time.Second
+	AliveInterval       = time.Second
+)
+
+// initialWorkers is how many units have their details fetched at once at
+// start up.
+const initialWorkers = 8
+
+// Event is a unit's state. Initial events report the state at start up,
+// the others changes since.
+type Event struct {
+	Unit     string
+	State    string
+	SubState string
+	// Template is the template in Config.Templates that Unit is an instance
+	// of, and Gone reports an instance systemd no longer lists, with no
+	// state.
+	Template string
+	Gone     bool
+	// NotFound reports a unit systemd has no unit file for, inactive until
+	// one turns up.
+	NotFound bool
+	// UnitFileState, e.g. "masked" or "disabled", and ConditionFailed,
+	// whether the unit's conditions kept it from starting last time, are
+	// only fetched for inactive units.
+	UnitFileState   string
+	ConditionFailed bool
+	Initial         bool
+	// StatusText is only fetched for the services in Config.StatusText.
+	StatusText string
+	// Result is why a service last stopped, e.g. "watchdog", fetched for
+	// services that aren't running.
+	Result string
+	// WatchdogLate reports a running service in Config.Watchdog close to
+	// missing its WatchdogSec=.
+	WatchdogLate bool
+	// Active counts the active units in a slice in Config.Slices.
+	Active int
+}
+
+// Renderer shows the states of units.
+type Renderer interface {
+	Render(Event)
+}
+
+// Run reports the current state of every unit, then their changes, until ctx
+// is done.
+func Run(ctx context.Context, c Config, r Renderer) error {
+	if c.Conn == nil {
+		return errors.New("monitor: no connection")
+	}
+	c.setDefaults()
+	c.resolveAliases()
+	shown := map[string]Event{}
+	listed := initial(c, r, shown)
+	if c.Poll > 0 {
+		poll(ctx, c, r, shown)
+	} else {
+		dispatch(ctx, c, r, shown, listed)
+	}
+	return ctx.Err()
+}
+
+// Once reports the current state of every unit, without following their
+// changes.
+func Once(c Config, r Renderer) error {
+	if c.Conn == nil {
+		return errors.New("monitor: no connection")
+	}
+	c.setDefaults()
+	c.resolveAliases()
+	if !initial(c, r, map[string]Event{}) {
+		return errors.New("monitor: unable to list the units")
+	}
+	return nil
+}
+
+func (c *Config) setDefaults() {
+	if c.PollInterval <= 0 {
+		c.PollInterval = DefaultPollInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+}
+
+// resolveAliases looks up the canonical name of every unit, as systemd lists
+// and signals an alias, such as sshd.service for ssh.service, by the unit's
+// canonical name only. A unit that isn't loaded yet is taken to be no alias.
+func (c *Config) resolveAliases() {
+	c.aliases = map[string]string{}
+	for _, unit := range c.Units {
+		var id *systemd.Property
+		err := c.call("GetUnitProperty", unit, func(ctx context.Context) (err error) {
+			id, err = c.Conn.GetUnitPropertyContext(ctx, unit, "Id")
+			return err
+		})
+		if err != nil {
+			c.failed("Failed to resolve the unit's name", unit, err)
+			continue
+		}
+		canonical, _ := id.Value.Value().(string)
+		if canonical != "" && canonical != unit && !contains(c.Units, canonical) {
+			c.Logger.Info("Following alias", zap.String("unit", unit), zap.String("canonical", canonical))
+			c.aliases[canonical] = unit
+		}
+	}
+}
+
+// named is the name unit is followed by: the alias configured for it, if any.
+func (c Config) named(unit string) string {
+	if alias, ok := c.aliases[unit]; ok {
+		return alias
+	}
+	return unit
+}
+
+// call makes a call to systemd, traced as method on unit, that gives up after
+// c.Timeout.
+func (c Config) call(method string, unit string, call func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	defer c.alive()
+	return Traced(method, unit, func() error { return call(ctx) })
+}
+
+// alive tells the caller Run isn't stuck.
+func (c Config) alive() {
+	if c.Alive != nil {
+		c.Alive()
+	}
+}
+
+// failed logs a failed call. A timeout is only a warning, as systemd may just
+// be busy: what it didn't answer is left unknown until the next poll, rather
+// than asked again at once.
+func (c Config) failed(msg string, unit string, err error) {
+	if timedOut(err) {
+		c.Logger.WarnL("dbus-timeout", "systemd didn't answer in time:", zap.String("call", msg), zap.String("unit", unit), zap.Duration("timeout", c.Timeout))
+		return
+	}
+	c.Logger.ErrorL("dbus", msg, zap.String("unit", unit), zap.Error(err))
+}
+
+func timedOut(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// initial renders the current state of every unit and instance, rather than
+// waiting for each to change before its pixel leaves the loading colour. Their
+// details are fetched in parallel, each unit rendered as soon as its are, so
+// one hung call doesn't hold back the others. It reports whether the units
+// could be listed.
+func initial(c Config, r Renderer, shown map[string]Event) bool {
+	units, err := listUnits(c)
+	if err != nil {
+		c.Logger.Error("Failed to fetch initial unit states", zap.Error(err))
+		return false
+	}
+	fetched := make(chan Event)
+	slots := make(chan struct{}, initialWorkers)
+	pending := 0
+	for _, unit := range units {
+		if unit.LoadState == "not-found" {
+			c.Logger.Info("Waiting for unit", zap.String("unit", unit.Name))
+			e := Event{Unit: unit.Name, State: "inactive", SubState: "dead", NotFound: true, Initial: true}
+			shown[e.Unit] = e
+			r.Render(e)
+			continue
+		}
+		c.Logger.Debug("Initial state",
+			zap.String("unit", unit.Name),
+			zap.String("active", unit.ActiveState),
+			zap.String("sub", unit.SubState),
+		)
+		e := Event{Unit: unit.Name, Template: templateOf(c, unit.Name), State: unit.ActiveState, SubState: unit.SubState, Initial: true}
+		pending++
+		go func() {
+			slots <- struct{}{}
+			details(c, &e)
+			<-slots
+			fetched <- e
+		}()
+	}
+	for ; pending > 0; pending-- {
+		e := <-fetched
+		shown[e.Unit] = e
+		r.Render(e)
+	}
+	return true
+}
+
+// Template returns the template unit, such as getty@.service, that unit is an
+// instance of, or "" if it isn't an instance.
+func Template(unit string) string {
+	at := strings.IndexByte(unit, '@')
+	dot := strings.LastIndexByte(unit, '.')
+	if at < 0 || dot <= at+1 {
+		return ""
+	}
+	return unit[:at+1] + unit[dot:]
+}
+
+// instancePatterns are the unit name patterns, such as getty@*.service,
+// matching the instances of templates.
+func instancePatterns(templates []string) []string {
+	var patterns []string
+	for _, template := range templates {
+		at := strings.IndexByte(template, '@')
+		patterns = append(patterns, template[:at+1]+"*"+template[at+1:])
+	}
+	return patterns
+}
+
+// dispatch renders the changes of every unit and instance from a single
+// subscription, routing them by unit. Over a Signaller the units are also
+// listed every c.PollInterval, to notice those systemd unloaded, which
+// signal no property change, and so are they when listed is false, as they
+// couldn't be at start up.
+func dispatch(ctx context.Context, c Config, r Renderer, shown map[string]Event, listed bool) {
+	units := map[string]bool{}
+	for _, unit := range c.Units {
+		units[unit] = true
+	}
+	follows := func(unit string) bool {
+		return units[unit] || contains(c.Templates, Template(unit))
+	}
+	var changes <-chan map[string]*systemd.UnitStatus
+	var updates chan *systemd.PropertiesUpdate
+	var errs <-chan error
+	if s, ok := c.Conn.(Signaller); ok {
+		updates = make(chan *systemd.PropertiesUpdate, signalBuffer)
+		signalErrs := make(chan error, 1)
+		s.SetPropertiesSubscriber(updates, signalErrs)
+		errs = signalErrs
+	} else {
+		changes, errs = c.Conn.SubscribeUnitsCustom(time.Second, 0, changed, func(unit string) bool {
+			return !follows(c.named(unit))
+		})
+	}
+	ticker := time.NewTicker(c.PollInterval)
+	defer ticker.Stop()
+	details := time.NewTicker(refreshEvery(c))
+	defer details.Stop()
+	alive := time.NewTicker(AliveInterval)
+	defer alive.Stop()
+	checked := map[string]time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-alive.C:
+			c.alive()
+		case event := <-changes:
+			for unit, status := range event {
+				if unit = c.named(unit); follows(unit) {
+					route(c, r, shown, unit, status, false)
+				}
+			}
+		case update := <-updates:
+			unit := c.named(update.UnitName)
+			if !follows(unit) {
+				break
+			}
+			if status, ok := signalled(shown[unit], update); ok {
+				route(c, r, shown, unit, status, false)
+			}
+		case <-ticker.C:
+			if updates != nil || !listed {
+				listed = reconcile(c, r, shown)
+			}
+		case <-details.C:
+			refresh(c, r, shown, checked, time.Now())
+		case err := <-errs:
+			c.Logger.ErrorL("dbus", "Unknown error, changes to systemd?", zap.Error(err))
+			if updates != nil {
+				reconcile(c, r, shown)
+			}
+		}
+	}
+}
+
+// signalled is the status of a unit after a property change, from the state
+// last shown and the changed properties, or false if neither its active nor
+// its sub state changed.
+func signalled(last Event, update *systemd.PropertiesUpdate) (*systemd.UnitStatus, bool) {
+	status := &systemd.UnitStatus{Name: update.UnitName, LoadState: "loaded", ActiveState: last.State, SubState: last.SubState}
+	active, activeChanged := update.Changed["ActiveState"]
+	sub, subChanged := update.Changed["SubState"]
+	if !activeChanged && !subChanged {
+		return nil, false
+	}
+	if activeChanged {
+		status.ActiveState, _ = active.Value().(string)
+	}
+	if subChanged {
+		status.SubState, _ = sub.Value().(string)
+	}
+	if load, ok := update.Changed["LoadState"]; ok {
+		status.LoadState, _ = load.Value().(string)
+	}
+	return status, true
+}
+
+// poll renders the changes of every unit and instance, listing them every
+// c.Poll.
+func poll(ctx context.Context, c Config, r Renderer, shown map[string]Event) {
+	ticker := time.NewTicker(c.Poll)
+	defer ticker.Stop()
+	details := time.NewTicker(refreshEvery(c))
+	defer details.Stop()
+	alive := time.NewTicker(AliveInterval)
+	defer alive.Stop()
+	checked := map[string]time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-alive.C:
+			c.alive()
+		case <-ticker.C:
+			reconcile(c, r, shown)
+		case <-details.C:
+			refresh(c, r, shown, checked, time.Now())
+		}
+	}
+}
+
+// reconcile lists the units and instances, rendering those whose state
+// differs from the one shown and those no longer listed. It reports whether
+// they could be listed.
+func reconcile(c Config, r Renderer, shown map[string]Event) bool {
+	units, err := listUnits(c)
+	if err != nil {
+		c.failed("Failed to list unit states", "", err)
+		return false
+	}
+	listed := map[string]bool{}
+	for i, unit := range units {
+		listed[unit.Name] = true
+		route(c, r, shown, unit.Name, &units[i], false)
+	}
+	for unit := range shown {
+		if !listed[unit] {
+			route(c, r, shown, unit, nil, false)
+		}
+	}
+	return true
+}
+
+// listUnits lists the units by name, aliases by the name they are followed
+// by, and the instances of the templates by pattern.
+func listUnits(c Config) ([]systemd.UnitStatus, error) {
+	var units, instances []systemd.UnitStatus
+	err := c.call("ListUnitsByNames", "", func(ctx context.Context) (err error) {
+		units, err = c.Conn.ListUnitsByNamesContext(ctx, c.Units)
+		return err
+	})
+	for i := range units {
+		units[i].Name = c.named(units[i].Name)
+	}
+	if err != nil || len(c.Templates) == 0 {
+		return units, err
+	}
+	err = c.call("ListUnitsByPatterns", "", func(ctx context.Context) (err error) {
+		instances, err = c.Conn.ListUnitsByPatternsContext(ctx, nil, instancePatterns(c.Templates))
+		return err
+	})
+	return append(units, instances...), err
+}
+
+// route renders the state of unit if it differs from the one shown. Once
+// systemd no longer lists a unit it showed, status is nil, and an instance is
+// rendered as gone and any other unit, such as a scope whose processes exited,
+// as inactive. A unit whose file went away is rendered as not found.
+func route(c Config, r Renderer, shown map[string]Event, unit string, status *systemd.UnitStatus, initial bool) {
+	template := templateOf(c, unit)
+	if status != nil && status.LoadState == "not-found" && template == "" {
+		if last, ok := shown[unit]; ok && last.NotFound {
+			return
+		}
+		e := Event{Unit: unit, State: "inactive", SubState: "dead", NotFound: true, Initial: initial}
+		shown[unit] = e
+		r.Render(e)
+		return
+	}
+	if status == nil || status.LoadState == "not-found" {
+		if _, ok := shown[unit]; !ok {
+			return
+		}
+		delete(shown, unit)
+		if template != "" {
+			r.Render(Event{Unit: unit, Template: template, Gone: true})
+			return
+		}
+		e := Event{Unit: unit, State: "inactive", SubState: "dead"}
+		details(c, &e)
+		r.Render(e)
+		return
+	}
+	if last, ok := shown[unit]; ok && !last.NotFound && last.State == status.ActiveState && last.SubState == status.SubState {
+		return
+	}
+	e := Event{Unit: unit, Template: template, State: status.ActiveState, SubState: status.SubState, Initial: initial}
+	answered := details(c, &e)
+	if last, ok := shown[unit]; ok && !last.NotFound && !answered {
+		// Until systemd answers, the unit's details are as last known.
+		e.UnitFileState, e.ConditionFailed, e.Result = last.UnitFileState, last.ConditionFailed, last.Result
+		e.StatusText, e.WatchdogLate, e.Active = last.StatusText, last.WatchdogLate, last.Active
+	}
+	shown[unit] = e
+	r.Render(e)
+}
+
+// templateOf is the template in c.Templates that unit is an instance of, or "".
+func templateOf(c Config, unit string) string {
+	if template := Template(unit); contains(c.Templates, template) {
+		return template
+	}
+	return ""
+}
+
+// refreshEvery is how often refresh runs, often enough for the unit polled
+// most often.
+func refreshEvery(c Config) time.Duration {
+	every := c.PollInterval
+	for _, interval := range c.Intervals {
+		every = min(every, interval)
+	}
+	return every
+}
+
+// refresh renders the units whose polled details changed, polling each once
+// its interval passed since it last was, as of now.
+func refresh(c Config, r Renderer, shown map[string]Event, checked map[string]time.Time, now time.Time) {
+	for unit := range checked {
+		if _, ok := shown[unit]; !ok {
+			delete(checked, unit)
+		}
+	}
+	// A tick may come a little early, so allow for half of one.
+	early := refreshEvery(c) / 2
+	for unit, last := range shown {
+		if last.NotFound {
+			continue
+		}
+		interval, ok := c.Intervals[unit]
+		if !ok {
+			interval = c.PollInterval
+		}
+		if now.Sub(checked[unit]) < interval-early {
+			continue
+		}
+		checked[unit] = now
+		e := last
+		e.Initial = false
+		if polled(c, &e) && e != last {
+			shown[unit] = e
+			r.Render(e)
+		}
+	}
+}
+
+// details fetches what the unit's state alone doesn't tell. It reports
+// whether systemd answered in time.
+func details(c Config, e *Event) bool {
+	answered := inactiveDetails(c, e)
+	service := &serviceProperties{c: c, unit: e.Unit}
+	if e.State == "failed" || e.State == "inactive" || e.State == "activating" {
+		e.Result, _ = service.get("Result").(string)
+	}
+	return polledFrom(c, e, service) && answered
+}
+
+// polled fetches the details that change without a state change. It reports
+// whether systemd answered in time.
+func polled(c Config, e *Event) bool {
+	return polledFrom(c, e, &serviceProperties{c: c, unit: e.Unit})
+}
+
+func polledFrom(c Config, e *Event, service *serviceProperties) bool {
+	answered := true
+	if contains(c.StatusText, e.Unit) {
+		if text, ok := service.get("StatusText").(string); ok {
+			e.StatusText = text
+		}
+	}
+	if contains(c.Watchdog, e.Unit) {
+		e.WatchdogLate = e.State == "active" && watchdogLate(service, time.Now())
+	}
+	if contains(c.Slices, e.Unit) {
+		var err error
+		e.Active, err = activeUnits(c, e.Unit)
+		answered = !timedOut(err)
+	}
+	return answered && !timedOut(service.err)
+}
+
+func contains(units []string, unit string) bool {
+	for _, u := range units {
+		if u == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceProperties are the properties of a service, fetched all in one call
+// the first time one is needed, rather than a call for each. Units other than
+// services have none.
+type serviceProperties struct {
+	c       Config
+	unit    string
+	fetched bool
+	values  map[string]interface{}
+	err     error
+}
+
+func (s *serviceProperties) get(name string) interface{} {
+	if !strings.HasSuffix(s.unit, ".service") {
+		return nil
+	}
+	if !s.fetched {
+		s.fetched = true
+		s.err = s.c.call("GetUnitTypeProperties", s.unit, func(ctx context.Context) (err error) {
+			s.values, err = s.c.Conn.GetUnitTypePropertiesContext(ctx, s.unit, "Service")
+			return err
+		})
+		if s.err != nil {
+			s.c.failed("Failed to get properties:", s.unit, s.err)
+		}
+	}
+	return s.values[name]
+}
+
+// watchdogLate reports whether a service has gone more than three quarters
+// of its WatchdogSec= without pinging.
+func watchdogLate(service *serviceProperties, now time.Time) bool {
+	usec, _ := service.get("WatchdogUSec").(uint64)
+	pinged, _ := service.get("WatchdogTimestamp").(uint64)
+	if usec == 0 || pinged == 0 {
+		return false
+	}
+	since := now.Sub(time.UnixMicro(int64(pinged)))
+	return since > time.Duration(usec)*time.Microsecond*3/4
+}
+
+// activeUnits counts the active units directly in slice, which each require
+// it.
+func activeUnits(c Config, slice string) (int, error) {
+	var property *systemd.Property
+	err := c.call("GetUnitProperty", slice, func(ctx context.Context) (err error) {
+		property, err = c.Conn.GetUnitPropertyContext(ctx, slice, "RequiredBy")
+		return err
+	})
+	if err != nil {
+		c.failed("Failed to get property:", slice, err)
+		return 0, err
+	}
+	members, _ := property.Value.Value().([]string)
+	if len(members) == 0 {
+		return 0, nil
+	}
+	var units []systemd.UnitStatus
+	err = c.call("ListUnitsByNames", slice, func(ctx context.Context) (err error) {
+		units, err = c.Conn.ListUnitsByNamesContext(ctx, members)
+		return err
+	})
+	if err != nil {
+		c.failed("Failed to list units:", slice, err)
+		return 0, err
+	}
+	active := 0
+	for _, unit := range units {
+		if unit.ActiveState == "active" {
+			active++
+		}
+	}
+	return active, nil
+}
+
+// inactiveDetails fetches why an inactive unit isn't running: whether it is
+// enabled, disabled or masked, and whether its conditions failed. It reports
+// whether systemd answered in time.
+func inactiveDetails(c Config, e *Event) bool {
+	if e.State != "inactive" {
+		return true
+	}
+	var properties map[string]interface{}
+	err := c.call("GetUnitProperties", e.Unit, func(ctx context.Context) (err error) {
+		properties, err = c.Conn.GetUnitPropertiesContext(ctx, e.Unit)
+		return err
+	})
+	if err != nil {
+		c.failed("Failed to get properties:", e.Unit, err)
+		return !timedOut(err)
+	}
+	e.UnitFileState, _ = properties["UnitFileState"].(string)
+	checked, _ := properties["ConditionTimestamp"].(uint64)
+	result, _ := properties["ConditionResult"].(bool)
+	e.ConditionFailed = checked > 0 && !result
+	return true
+}
+
+// changed reports whether the unit's state differs between two polls.
+func changed(u1, u2 *systemd.UnitStatus) bool {
+	return u1.Name != u2.Name ||
+		u1.LoadState != u2.LoadState ||
+		u1.ActiveState != u2.ActiveState ||
+		u1.SubState != u2.SubState
+}
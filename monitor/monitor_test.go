@@ -0,0 +1,704 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"go.uber.org/zap"
+)
+
+// fakeConn serves unit states from a map, sending changes to every
+// subscriber.
+type fakeConn struct {
+	mu          sync.Mutex
+	units       map[string]systemd.UnitStatus
+	subscribers []func(map[string]*systemd.UnitStatus)
+	statusText  string
+	watchdog    [2]uint64 // WatchdogUSec, WatchdogTimestamp
+	requiredBy  map[string][]string
+	typeCalls   int
+	// aliases maps aliases to the canonical names of units.
+	aliases map[string]string
+	// hang, when set, blocks fetching the properties of hung until closed.
+	hang chan struct{}
+	hung string
+}
+
+func (f *fakeConn) send(event map[string]*systemd.UnitStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, subscriber := range f.subscribers {
+		subscriber(event)
+	}
+}
+
+func (f *fakeConn) GetUnitPropertyContext(ctx context.Context, unit string, name string) (*systemd.Property, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch name {
+	case "RequiredBy":
+		return &systemd.Property{Name: name, Value: dbus.MakeVariant(f.requiredBy[unit])}, nil
+	case "Id":
+		if canonical, ok := f.aliases[unit]; ok {
+			unit = canonical
+		}
+		return &systemd.Property{Name: name, Value: dbus.MakeVariant(unit)}, nil
+	}
+	state := "not-found"
+	if u, ok := f.units[unit]; ok {
+		state = u.LoadState
+	}
+	return &systemd.Property{Name: name, Value: dbus.MakeVariant(state)}, nil
+}
+
+func (f *fakeConn) GetUnitPropertiesContext(ctx context.Context, unit string) (map[string]interface{}, error) {
+	if f.hang != nil && unit == f.hung {
+		select {
+		case <-f.hang:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u := f.units[unit]
+	properties := map[string]interface{}{"UnitFileState": "enabled", "ConditionResult": true, "ConditionTimestamp": uint64(1)}
+	switch u.Description {
+	case "masked":
+		properties["UnitFileState"] = "masked"
+	case "skipped":
+		properties["ConditionResult"] = false
+	}
+	return properties, nil
+}
+
+func (f *fakeConn) GetUnitTypePropertiesContext(ctx context.Context, unit string, unitType string) (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.typeCalls++
+	return map[string]interface{}{
+		"StatusText":        f.statusText,
+		"Result":            "success",
+		"WatchdogUSec":      f.watchdog[0],
+		"WatchdogTimestamp": f.watchdog[1],
+	}, nil
+}
+
+func (f *fakeConn) ListUnitsByNamesContext(ctx context.Context, names []string) ([]systemd.UnitStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var units []systemd.UnitStatus
+	for _, name := range names {
+		if canonical, ok := f.aliases[name]; ok {
+			name = canonical
+		}
+		if u, ok := f.units[name]; ok {
+			units = append(units, u)
+		}
+	}
+	return units, nil
+}
+
+func (f *fakeConn) ListUnitsByPatternsContext(ctx context.Context, states []string, patterns []string) ([]systemd.UnitStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var units []systemd.UnitStatus
+	for name, u := range f.units {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				units = append(units, u)
+				break
+			}
+		}
+	}
+	return units, nil
+}
+
+func (f *fakeConn) SubscribeUnitsCustom(interval time.Duration, buffer int, isChanged func(*systemd.UnitStatus, *systemd.UnitStatus) bool, filterUnit func(string) bool) (<-chan map[string]*systemd.UnitStatus, <-chan error) {
+	out := make(chan map[string]*systemd.UnitStatus, 10)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribers = append(f.subscribers, func(event map[string]*systemd.UnitStatus) {
+		for name := range event {
+			if !filterUnit(name) {
+				out <- event
+				return
+			}
+		}
+	})
+	return out, make(chan error)
+}
+
+type recorder chan Event
+
+func (r recorder) Render(e Event) { r <- e }
+
+func TestRun(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"a.service": {Name: "a.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			"c.service": {Name: "c.service", Description: "masked", LoadState: "masked", ActiveState: "inactive", SubState: "dead"},
+			"d.service": {Name: "d.service", Description: "skipped", LoadState: "loaded", ActiveState: "inactive", SubState: "dead"},
+		},
+	}
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	events := make(recorder, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, Config{Conn: conn, Units: []string{"a.service", "b.service", "c.service", "d.service"}, Logger: logger}, events)
+
+	// Initial states come in the order their details were fetched.
+	got := map[string]Event{}
+	for i := 0; i < 3; i++ {
+		e := <-events
+		got[e.Unit] = e
+	}
+	for _, want := range []Event{
+		{Unit: "a.service", State: "active", SubState: "running", Initial: true},
+		{Unit: "c.service", State: "inactive", SubState: "dead", UnitFileState: "masked", Result: "success", Initial: true},
+		{Unit: "d.service", State: "inactive", SubState: "dead", UnitFileState: "enabled", ConditionFailed: true, Result: "success", Initial: true},
+	} {
+		if got[want.Unit] != want {
+			t.Errorf("initial event = %+v, want %+v", got[want.Unit], want)
+		}
+	}
+	// Like systemd's polling, keep reporting the change until a.service is
+	// watched.
+	change := map[string]*systemd.UnitStatus{
+		"a.service": {Name: "a.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+	}
+	var e Event
+	for e.Unit == "" {
+		conn.send(change)
+		select {
+		case e = <-events:
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	if e != (Event{Unit: "a.service", State: "failed", SubState: "failed", Result: "success"}) {
+		t.Errorf("change event = %+v", e)
+	}
+	timeout := time.After(10 * time.Millisecond)
+	for {
+		select {
+		case e := <-events:
+			if e.Unit == "b.service" {
+				t.Errorf("unexpected event %+v", e)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestRunAlias(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"ssh.service": {Name: "ssh.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		},
+		aliases: map[string]string{"sshd.service": "ssh.service"},
+	}
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	events := make(recorder, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, Config{Conn: conn, Units: []string{"sshd.service"}, Logger: logger}, events)
+
+	if e := <-events; e.Unit != "sshd.service" || e.State != "active" {
+		t.Errorf("initial event = %+v, want sshd.service active", e)
+	}
+	change := map[string]*systemd.UnitStatus{
+		"ssh.service": {Name: "ssh.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+	}
+	var e Event
+	for e.Unit == "" {
+		conn.send(change)
+		select {
+		case e = <-events:
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	if e.Unit != "sshd.service" || e.State != "failed" {
+		t.Errorf("change event = %+v, want sshd.service failed", e)
+	}
+}
+
+func TestRunNotFound(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"a.service": {Name: "a.service", LoadState: "not-found", ActiveState: "inactive", SubState: "dead"},
+		},
+	}
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	events := make(recorder, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, Config{Conn: conn, Units: []string{"a.service"}, Logger: logger}, events)
+
+	if e := <-events; e != (Event{Unit: "a.service", State: "inactive", SubState: "dead", NotFound: true, Initial: true}) {
+		t.Errorf("initial event = %+v, want not found", e)
+	}
+	// Once installed, the unit is shown even in the state it had while
+	// missing.
+	change := map[string]*systemd.UnitStatus{
+		"a.service": {Name: "a.service", LoadState: "loaded", ActiveState: "inactive", SubState: "dead"},
+	}
+	var e Event
+	for e.Unit == "" {
+		conn.send(change)
+		select {
+		case e = <-events:
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	if e.NotFound || e.State != "inactive" || e.UnitFileState != "enabled" {
+		t.Errorf("event once installed = %+v", e)
+	}
+}
+
+func TestRunAlive(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"a.service": {Name: "a.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		},
+	}
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	events := make(recorder, 10)
+	beats := make(chan time.Time, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, Config{Conn: conn, Units: []string{"a.service"}, Logger: logger, Alive: func() { beats <- time.Now() }}, events)
+
+	<-events
+	// The calls made at start up beat too; a quiet loop keeps beating.
+	start := time.Now()
+	for {
+		select {
+		case at := <-beats:
+			if at.Sub(start) >= AliveInterval/2 {
+				return
+			}
+		case <-time.After(3 * AliveInterval):
+			t.Fatal("no beat from a quiet loop")
+		}
+	}
+}
+
+func TestRunRoutesEveryUnit(t *testing.T) {
+	conn := &fakeConn{units: map[string]systemd.UnitStatus{}}
+	var units []string
+	change := map[string]*systemd.UnitStatus{}
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("u%d.service", i)
+		units = append(units, name)
+		conn.units[name] = systemd.UnitStatus{Name: name, LoadState: "loaded", ActiveState: "active", SubState: "running"}
+		change[name] = &systemd.UnitStatus{Name: name, LoadState: "loaded", ActiveState: "failed", SubState: "failed"}
+	}
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	events := make(recorder, 200)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, Config{Conn: conn, Units: units, Logger: logger}, events)
+	for range units {
+		<-events
+	}
+
+	// One change of every unit at once reaches every unit.
+	failed := map[string]bool{}
+	for len(failed) < len(units) {
+		conn.send(change)
+		select {
+		case e := <-events:
+			if e.State != "failed" || failed[e.Unit] {
+				t.Fatalf("unexpected event %+v", e)
+			}
+			failed[e.Unit] = true
+		case <-time.After(time.Second):
+			t.Fatalf("%d of %d changes reported", len(failed), len(units))
+		}
+	}
+}
+
+func TestStatusText(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"a.service": {Name: "a.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		},
+		statusText: "ready",
+	}
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	events := make(recorder, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, Config{
+		Conn:         conn,
+		Units:        []string{"a.service"},
+		Logger:       logger,
+		StatusText:   []string{"a.service"},
+		PollInterval: time.Millisecond,
+	}, events)
+
+	if e := <-events; e.StatusText != "ready" {
+		t.Errorf("initial status text = %q, want ready", e.StatusText)
+	}
+	conn.mu.Lock()
+	conn.statusText = "degraded"
+	conn.mu.Unlock()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.StatusText == "degraded" {
+				if e.State != "active" {
+					t.Errorf("state = %q, want active", e.State)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("status text change not reported")
+		}
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	for unit, want := range map[string]string{
+		"getty@tty1.service":   "getty@.service",
+		"getty@.service":       "",
+		"nginx.service":        "",
+		"user@1000.service":    "user@.service",
+		"run-u1@x.y.z.service": "run-u1@.service",
+	} {
+		if got := Template(unit); got != want {
+			t.Errorf("Template(%q) = %q, want %q", unit, got, want)
+		}
+	}
+}
+
+func TestRunTemplates(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"getty@tty1.service": {Name: "getty@tty1.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			"other.service":      {Name: "other.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		},
+	}
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	events := make(recorder, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, Config{Conn: conn, Templates: []string{"getty@.service"}, Logger: logger}, events)
+
+	if e := <-events; e != (Event{Unit: "getty@tty1.service", Template: "getty@.service", State: "active", SubState: "running", Initial: true}) {
+		t.Errorf("initial event = %+v", e)
+	}
+	// Keep reporting the change until an event for unit arrives.
+	next := func(unit string, event map[string]*systemd.UnitStatus) Event {
+		for {
+			conn.send(event)
+			select {
+			case e := <-events:
+				if e.Unit == unit && e.Gone == (event[unit] == nil) {
+					return e
+				}
+				if e.Unit == "other.service" {
+					t.Errorf("unexpected event %+v", e)
+				}
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}
+	if e := next("getty@tty2.service", map[string]*systemd.UnitStatus{"other.service": nil, "getty@tty2.service": {Name: "getty@tty2.service", ActiveState: "activating", SubState: "start"}}); e != (Event{Unit: "getty@tty2.service", Template: "getty@.service", State: "activating", SubState: "start", Result: "success"}) {
+		t.Errorf("new instance event = %+v", e)
+	}
+	if e := next("getty@tty1.service", map[string]*systemd.UnitStatus{"getty@tty1.service": nil}); e != (Event{Unit: "getty@tty1.service", Template: "getty@.service", Gone: true}) {
+		t.Errorf("gone instance event = %+v", e)
+	}
+}
+
+func TestRunPoll(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"a.service":          {Name: "a.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			"getty@tty1.service": {Name: "getty@tty1.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		},
+	}
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	events := make(recorder, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, Config{Conn: conn, Units: []string{"a.service", "b.service"}, Templates: []string{"getty@.service"}, Logger: logger, Poll: time.Millisecond}, events)
+
+	got := map[string]Event{}
+	for len(got) < 2 {
+		e := <-events
+		got[e.Unit] = e
+	}
+	if e := got["a.service"]; e != (Event{Unit: "a.service", State: "active", SubState: "running", Initial: true}) {
+		t.Errorf("initial event = %+v", e)
+	}
+	if e := got["getty@tty1.service"]; e != (Event{Unit: "getty@tty1.service", Template: "getty@.service", State: "active", SubState: "running", Initial: true}) {
+		t.Errorf("initial instance event = %+v", e)
+	}
+
+	conn.mu.Lock()
+	conn.units["a.service"] = systemd.UnitStatus{Name: "a.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"}
+	delete(conn.units, "getty@tty1.service")
+	conn.mu.Unlock()
+	got = map[string]Event{}
+	for len(got) < 2 {
+		e := <-events
+		got[e.Unit] = e
+	}
+	if e := got["a.service"]; e != (Event{Unit: "a.service", State: "failed", SubState: "failed", Result: "success"}) {
+		t.Errorf("change event = %+v", e)
+	}
+	if e := got["getty@tty1.service"]; e != (Event{Unit: "getty@tty1.service", Template: "getty@.service", Gone: true}) {
+		t.Errorf("gone instance event = %+v", e)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %+v", e)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestActiveUnits(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"machine-a.scope": {Name: "machine-a.scope", ActiveState: "active"},
+			"machine-b.scope": {Name: "machine-b.scope", ActiveState: "active"},
+			"machine-c.scope": {Name: "machine-c.scope", ActiveState: "deactivating"},
+		},
+		requiredBy: map[string][]string{"machine.slice": {"machine-a.scope", "machine-b.scope", "machine-c.scope", "machine-d.scope"}},
+	}
+	c := Config{Conn: conn, Logger: loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))}
+	if got, _ := activeUnits(c, "machine.slice"); got != 2 {
+		t.Errorf("activeUnits(machine.slice) = %d, want 2", got)
+	}
+	if got, _ := activeUnits(c, "user.slice"); got != 0 {
+		t.Errorf("activeUnits(user.slice) = %d, want 0", got)
+	}
+}
+
+func TestRunUnloaded(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"session-1.scope": {Name: "session-1.scope", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		},
+	}
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	events := make(recorder, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, Config{Conn: conn, Units: []string{"session-1.scope"}, Logger: logger}, events)
+	<-events
+
+	for {
+		conn.send(map[string]*systemd.UnitStatus{"session-1.scope": nil})
+		select {
+		case e := <-events:
+			if e != (Event{Unit: "session-1.scope", State: "inactive", SubState: "dead", UnitFileState: "enabled"}) {
+				t.Errorf("unloaded event = %+v", e)
+			}
+			return
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+// signallingConn signals property changes rather than being subscribed to.
+type signallingConn struct {
+	*fakeConn
+	updates chan<- *systemd.PropertiesUpdate
+	ready   chan struct{}
+}
+
+func (s *signallingConn) SubscribeUnitsCustom(time.Duration, int, func(*systemd.UnitStatus, *systemd.UnitStatus) bool, func(string) bool) (<-chan map[string]*systemd.UnitStatus, <-chan error) {
+	panic("subscribed despite signals")
+}
+
+func (s *signallingConn) SetPropertiesSubscriber(updates chan<- *systemd.PropertiesUpdate, errs chan<- error) {
+	s.updates = updates
+	close(s.ready)
+}
+
+func TestRunSignals(t *testing.T) {
+	conn := &signallingConn{
+		fakeConn: &fakeConn{
+			units: map[string]systemd.UnitStatus{
+				"a.service": {Name: "a.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			},
+		},
+		ready: make(chan struct{}),
+	}
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	events := make(recorder, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, Config{Conn: conn, Units: []string{"a.service"}, Logger: logger, PollInterval: 10 * time.Millisecond}, events)
+	<-events
+	<-conn.ready
+
+	conn.updates <- &systemd.PropertiesUpdate{UnitName: "b.service", Changed: map[string]dbus.Variant{"ActiveState": dbus.MakeVariant("failed")}}
+	conn.updates <- &systemd.PropertiesUpdate{UnitName: "a.service", Changed: map[string]dbus.Variant{"Description": dbus.MakeVariant("A")}}
+	conn.updates <- &systemd.PropertiesUpdate{UnitName: "a.service", Changed: map[string]dbus.Variant{"ActiveState": dbus.MakeVariant("failed"), "SubState": dbus.MakeVariant("failed")}}
+	if e := <-events; e.Unit != "a.service" || e.State != "failed" || e.SubState != "failed" {
+		t.Errorf("signalled event = %+v", e)
+	}
+
+	conn.mu.Lock()
+	delete(conn.units, "a.service")
+	conn.mu.Unlock()
+	if e := <-events; e.Unit != "a.service" || e.State != "inactive" {
+		t.Errorf("once unloaded = %+v", e)
+	}
+}
+
+func TestWatchdogLate(t *testing.T) {
+	now := time.Now()
+	c := Config{Logger: loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))}
+	for _, tc := range []struct {
+		usec   uint64
+		pinged time.Duration
+		want   bool
+	}{
+		{0, time.Minute, false},
+		{10e6, 5 * time.Second, false},
+		{10e6, 8 * time.Second, true},
+	} {
+		c.Conn = &fakeConn{watchdog: [2]uint64{tc.usec, uint64(now.Add(-tc.pinged).UnixMicro())}}
+		if got := watchdogLate(&serviceProperties{c: c, unit: "a.service"}, now); got != tc.want {
+			t.Errorf("WatchdogUSec %d pinged %s ago: late = %v, want %v", tc.usec, tc.pinged, got, tc.want)
+		}
+	}
+}
+
+func TestDetailsBatched(t *testing.T) {
+	conn := &fakeConn{statusText: "Serving", watchdog: [2]uint64{10e6, 1}}
+	c := Config{
+		Conn:       conn,
+		Logger:     loglimit.New(limlog.NewLimlogWithZap(zap.NewNop())),
+		StatusText: []string{"a.service", "a.slice"},
+		Watchdog:   []string{"a.service"},
+	}
+	e := Event{Unit: "a.service", State: "failed"}
+	details(c, &e)
+	if e.Result != "success" || e.StatusText != "Serving" {
+		t.Errorf("details = %+v", e)
+	}
+	if conn.typeCalls != 1 {
+		t.Errorf("fetched the service properties %d times, want once", conn.typeCalls)
+	}
+	details(c, &Event{Unit: "a.slice", State: "failed"})
+	if conn.typeCalls != 1 {
+		t.Errorf("fetched service properties of a slice")
+	}
+}
+
+func TestRefreshIntervals(t *testing.T) {
+	conn := &fakeConn{}
+	c := Config{
+		Conn:         conn,
+		Logger:       loglimit.New(limlog.NewLimlogWithZap(zap.NewNop())),
+		StatusText:   []string{"fast.service", "slow.service"},
+		PollInterval: 10 * time.Second,
+		Intervals:    map[string]time.Duration{"slow.service": time.Minute},
+	}
+	shown := map[string]Event{
+		"fast.service": {Unit: "fast.service", State: "active"},
+		"slow.service": {Unit: "slow.service", State: "active"},
+	}
+	checked := map[string]time.Time{}
+	events := make(recorder, 10)
+	start := time.Now()
+	refresh(c, events, shown, checked, start)
+	if len(events) != 0 {
+		t.Fatalf("rendered %d unchanged units", len(events))
+	}
+
+	conn.statusText = "Busy"
+	refresh(c, events, shown, checked, start.Add(10*time.Second))
+	if e := <-events; e.Unit != "fast.service" || len(events) != 0 {
+		t.Errorf("after 10s rendered %+v and %d more", e, len(events))
+	}
+	refresh(c, events, shown, checked, start.Add(time.Minute))
+	if e := <-events; e.Unit != "slow.service" || e.StatusText != "Busy" {
+		t.Errorf("after a minute rendered %+v", e)
+	}
+	if got := refreshEvery(c); got != 10*time.Second {
+		t.Errorf("refreshEvery() = %s", got)
+	}
+}
+
+func TestInitialTimeout(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"hung.service": {Name: "hung.service", LoadState: "loaded", ActiveState: "inactive", SubState: "dead"},
+		},
+		hang: make(chan struct{}),
+		hung: "hung.service",
+	}
+	defer close(conn.hang)
+	for i := 0; i < 20; i++ {
+		unit := fmt.Sprintf("u%d.service", i)
+		conn.units[unit] = systemd.UnitStatus{Name: unit, LoadState: "loaded", ActiveState: "inactive", SubState: "dead"}
+	}
+	c := Config{Conn: conn, Logger: loglimit.New(limlog.NewLimlogWithZap(zap.NewNop())), Timeout: 10 * time.Millisecond}
+	for unit := range conn.units {
+		c.Units = append(c.Units, unit)
+	}
+	events := make(recorder, 21)
+	initial(c, events, map[string]Event{})
+	if len(events) != 21 {
+		t.Fatalf("rendered %d units, want 21", len(events))
+	}
+	for i := 0; i < 21; i++ {
+		e := <-events
+		if e.Unit == "hung.service" && e.UnitFileState != "" {
+			t.Errorf("hung unit rendered with details %+v", e)
+		}
+		if e.Unit != "hung.service" && e.UnitFileState != "enabled" {
+			t.Errorf("%s rendered without details", e.Unit)
+		}
+	}
+}
+
+func TestRouteTimeout(t *testing.T) {
+	conn := &fakeConn{hang: make(chan struct{}), hung: "a.service"}
+	defer close(conn.hang)
+	c := Config{Conn: conn, Logger: loglimit.New(limlog.NewLimlogWithZap(zap.NewNop())), Timeout: 10 * time.Millisecond}
+	shown := map[string]Event{"a.service": {Unit: "a.service", State: "inactive", SubState: "dead", UnitFileState: "masked"}}
+	events := make(recorder, 1)
+	route(c, events, shown, "a.service", &systemd.UnitStatus{Name: "a.service", LoadState: "loaded", ActiveState: "inactive", SubState: "exited"}, false)
+	if e := <-events; e.SubState != "exited" || e.UnitFileState != "masked" {
+		t.Errorf("without an answer = %+v, want the last known details", e)
+	}
+}
+
+func TestOnce(t *testing.T) {
+	conn := &fakeConn{
+		units: map[string]systemd.UnitStatus{
+			"a.service": {Name: "a.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		},
+	}
+	events := make(recorder, 10)
+	if err := Once(Config{Conn: conn, Units: []string{"a.service", "b.service"}, Logger: loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))}, events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("rendered %d events, want 1", len(events))
+	}
+	if e := <-events; e != (Event{Unit: "a.service", State: "active", SubState: "running", Initial: true}) {
+		t.Errorf("event = %+v", e)
+	}
+	if len(conn.subscribers) != 0 {
+		t.Error("subscribed to changes")
+	}
+}
@@ -0,0 +1,21 @@
+package monitor
+
+import "github.com/shift/systemd-status-leds/telemetry"
+
+var (
+	dbusCalls  = telemetry.NewCounter("statusleds_dbus_calls_total", "D-Bus calls made to systemd.")
+	dbusErrors = telemetry.NewCounter("statusleds_dbus_errors_total", "D-Bus calls to systemd that failed.")
+)
+
+// Traced runs a D-Bus call inside a span and counts it.
+func Traced(method string, unit string, call func() error) error {
+	attrs := []telemetry.Attr{{Key: "method", Value: method}}
+	span := telemetry.StartSpan("dbus."+method, append(attrs, telemetry.Attr{Key: "unit", Value: unit})...)
+	err := call()
+	span.End(err)
+	dbusCalls.Inc(attrs...)
+	if err != nil {
+		dbusErrors.Inc(attrs...)
+	}
+	return err
+}
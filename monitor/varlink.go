@@ -0,0 +1,301 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"time"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+)
+
+// DefaultVarlink is the socket systemd serves io.systemd.Unit on.
+const DefaultVarlink = "/run/systemd/io.systemd.Manager"
+
+// errNoSuchUnit is the varlink error for a unit systemd doesn't know.
+const errNoSuchUnit = "io.systemd.Unit.NoSuchUnit"
+
+// Varlink is a Conn talking to systemd's io.systemd.Unit varlink interface at
+// Path, for systems without a D-Bus broker. Varlink has no change
+// notifications, so subscriptions poll. Properties the interface doesn't
+// report read as empty.
+type Varlink struct {
+	Path string
+	// Timeout bounds each call, DefaultVarlinkTimeout unless set or the
+	// call's context is done sooner.
+	Timeout time.Duration
+}
+
+const DefaultVarlinkTimeout = 5 * time.Second
+
+// varlinkError is an error reply.
+type varlinkError struct {
+	Name string
+}
+
+func (e *varlinkError) Error() string { return "varlink: " + e.Name }
+
+// varlinkUnit is a unit as io.systemd.Unit.List describes it.
+type varlinkUnit struct {
+	Context map[string]interface{} `json:"context"`
+	Runtime map[string]interface{} `json:"runtime"`
+}
+
+func (u varlinkUnit) property(name string) interface{} {
+	if v, ok := u.Runtime[name]; ok {
+		return v
+	}
+	return u.Context[name]
+}
+
+func (u varlinkUnit) status() systemd.UnitStatus {
+	s := func(name string) string {
+		v, _ := u.property(name).(string)
+		return v
+	}
+	return systemd.UnitStatus{
+		Name:        s("ID"),
+		Description: s("Description"),
+		LoadState:   s("LoadState"),
+		ActiveState: s("ActiveState"),
+		SubState:    s("SubState"),
+	}
+}
+
+// call sends method with parameters, passing every reply to each. With more
+// set the server may reply more than once.
+func (v *Varlink) call(ctx context.Context, method string, parameters interface{}, more bool, each func(varlinkUnit)) error {
+	timeout := v.Timeout
+	if timeout <= 0 {
+		timeout = DefaultVarlinkTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", v.Path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	request, err := json.Marshal(struct {
+		Method     string      `json:"method"`
+		Parameters interface{} `json:"parameters,omitempty"`
+		More       bool        `json:"more,omitempty"`
+	}{method, parameters, more})
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(append(request, 0)); err != nil {
+		return err
+	}
+	r := bufio.NewReader(conn)
+	for {
+		message, err := r.ReadBytes(0)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return context.DeadlineExceeded
+		}
+		if err != nil {
+			return err
+		}
+		var reply struct {
+			Parameters varlinkUnit `json:"parameters"`
+			Continues  bool        `json:"continues"`
+			Error      string      `json:"error"`
+		}
+		d := json.NewDecoder(bytes.NewReader(message[:len(message)-1]))
+		d.UseNumber()
+		if err := d.Decode(&reply); err != nil {
+			return fmt.Errorf("varlink: %v", err)
+		}
+		if reply.Error != "" {
+			return &varlinkError{reply.Error}
+		}
+		each(reply.Parameters)
+		if !reply.Continues {
+			return nil
+		}
+	}
+}
+
+// unit describes one unit, nil if systemd doesn't know it.
+func (v *Varlink) unit(ctx context.Context, name string) (*varlinkUnit, error) {
+	var found *varlinkUnit
+	err := v.call(ctx, "io.systemd.Unit.List", map[string]string{"name": name}, false, func(u varlinkUnit) { found = &u })
+	var e *varlinkError
+	if errors.As(err, &e) && e.Name == errNoSuchUnit {
+		return nil, nil
+	}
+	return found, err
+}
+
+// ListUnitsContext lists every unit systemd has loaded.
+func (v *Varlink) ListUnitsContext(ctx context.Context) ([]systemd.UnitStatus, error) {
+	var units []systemd.UnitStatus
+	err := v.call(ctx, "io.systemd.Unit.List", nil, true, func(u varlinkUnit) { units = append(units, u.status()) })
+	return units, err
+}
+
+func (v *Varlink) ListUnitsByNamesContext(ctx context.Context, names []string) ([]systemd.UnitStatus, error) {
+	var units []systemd.UnitStatus
+	for _, name := range names {
+		u, err := v.unit(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			units = append(units, systemd.UnitStatus{Name: name, LoadState: "not-found", ActiveState: "inactive", SubState: "dead"})
+			continue
+		}
+		status := u.status()
+		if status.Name == "" {
+			status.Name = name
+		}
+		units = append(units, status)
+	}
+	return units, nil
+}
+
+func (v *Varlink) ListUnitsByPatternsContext(ctx context.Context, states []string, patterns []string) ([]systemd.UnitStatus, error) {
+	all, err := v.ListUnitsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var units []systemd.UnitStatus
+	for _, u := range all {
+		if len(states) > 0 && !contains(states, u.ActiveState) && !contains(states, u.LoadState) && !contains(states, u.SubState) {
+			continue
+		}
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, u.Name); ok {
+				units = append(units, u)
+				break
+			}
+		}
+	}
+	return units, nil
+}
+
+func (v *Varlink) GetUnitPropertiesContext(ctx context.Context, unit string) (map[string]interface{}, error) {
+	u, err := v.unit(ctx, unit)
+	if err != nil || u == nil {
+		return map[string]interface{}{}, err
+	}
+	properties := map[string]interface{}{}
+	for _, m := range []map[string]interface{}{u.Context, u.Runtime} {
+		for name, value := range m {
+			properties[name] = fromJSON(value)
+		}
+	}
+	return properties, nil
+}
+
+func (v *Varlink) GetUnitPropertyContext(ctx context.Context, unit string, name string) (*systemd.Property, error) {
+	u, err := v.unit(ctx, unit)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	switch {
+	case u == nil && name == "LoadState":
+		value = "not-found"
+	case u != nil:
+		value = fromJSON(u.property(name))
+	}
+	if value == nil {
+		value = ""
+	}
+	return &systemd.Property{Name: name, Value: dbus.MakeVariant(value)}, nil
+}
+
+// GetUnitTypePropertiesContext returns every property of unit, as varlink
+// doesn't tell them apart by unit type.
+func (v *Varlink) GetUnitTypePropertiesContext(ctx context.Context, unit string, unitType string) (map[string]interface{}, error) {
+	return v.GetUnitPropertiesContext(ctx, unit)
+}
+
+// SubscribeUnitsCustom lists the units every interval, sending those that
+// changed, and nil for those gone, as go-systemd does over D-Bus.
+func (v *Varlink) SubscribeUnitsCustom(interval time.Duration, buffer int, isChanged func(*systemd.UnitStatus, *systemd.UnitStatus) bool, filterUnit func(string) bool) (<-chan map[string]*systemd.UnitStatus, <-chan error) {
+	statuses := make(chan map[string]*systemd.UnitStatus, buffer)
+	errs := make(chan error, buffer)
+	go func() {
+		old := map[string]*systemd.UnitStatus{}
+		for {
+			next := time.After(interval)
+			units, err := v.ListUnitsContext(context.Background())
+			if err != nil {
+				errs <- err
+				<-next
+				continue
+			}
+			cur := map[string]*systemd.UnitStatus{}
+			changed := map[string]*systemd.UnitStatus{}
+			for i := range units {
+				if filterUnit != nil && filterUnit(units[i].Name) {
+					continue
+				}
+				u := &units[i]
+				cur[u.Name] = u
+				if o, ok := old[u.Name]; !ok || isChanged(o, u) {
+					changed[u.Name] = u
+				}
+				delete(old, u.Name)
+			}
+			for name := range old {
+				changed[name] = nil
+			}
+			old = cur
+			if len(changed) > 0 {
+				statuses <- changed
+			}
+			<-next
+		}
+	}()
+	return statuses, errs
+}
+
+// Connected reports whether the varlink socket accepts connections.
+func (v *Varlink) Connected() bool {
+	conn, err := net.DialTimeout("unix", v.Path, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// fromJSON turns decoded JSON into the types D-Bus properties have: whole
+// numbers as uint64 or int64, and lists of strings as []string.
+func fromJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			if n >= 0 {
+				return uint64(n)
+			}
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return v
+			}
+			list = append(list, s)
+		}
+		return list
+	}
+	return v
+}
@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// serveVarlink answers io.systemd.Unit.List from units, keyed by name, until
+// the test ends.
+func serveVarlink(t *testing.T, units map[string]string) string {
+	socket := filepath.Join(t.TempDir(), "io.systemd.Manager")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			message, err := r.ReadBytes(0)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			var request struct {
+				Parameters struct{ Name string }
+				More       bool
+			}
+			_ = json.Unmarshal(message[:len(message)-1], &request)
+			var replies []string
+			for name, unit := range units {
+				if request.Parameters.Name == "" || request.Parameters.Name == name {
+					replies = append(replies, `{"parameters":`+unit+`,"continues":true}`)
+				}
+			}
+			if len(replies) == 0 {
+				replies = []string{`{"error":"io.systemd.Unit.NoSuchUnit","parameters":{}}`}
+			} else {
+				last := replies[len(replies)-1]
+				replies[len(replies)-1] = last[:len(last)-len(`,"continues":true}`)] + "}"
+			}
+			for _, reply := range replies {
+				_, _ = conn.Write(append([]byte(reply), 0))
+			}
+			conn.Close()
+		}
+	}()
+	return socket
+}
+
+func TestVarlink(t *testing.T) {
+	v := &Varlink{Path: serveVarlink(t, map[string]string{
+		"a.service":     `{"context":{"ID":"a.service","Type":"service"},"runtime":{"LoadState":"loaded","ActiveState":"active","SubState":"running","WatchdogUSec":5000000}}`,
+		"machine.slice": `{"context":{"ID":"machine.slice","RequiredBy":["vm.scope"]},"runtime":{"LoadState":"loaded","ActiveState":"active","SubState":"active"}}`,
+	})}
+	ctx := context.Background()
+	units, err := v.ListUnitsByNamesContext(ctx, []string{"a.service", "b.service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(units) != 2 || units[0].ActiveState != "active" || units[0].SubState != "running" || units[1].Name != "b.service" || units[1].LoadState != "not-found" {
+		t.Errorf("ListUnitsByNames() = %+v", units)
+	}
+	if p, err := v.GetUnitPropertyContext(ctx, "b.service", "LoadState"); err != nil || p.Value.Value() != "not-found" {
+		t.Errorf("LoadState of an unknown unit = %v, %v", p, err)
+	}
+	if p, err := v.GetUnitTypePropertiesContext(ctx, "a.service", "Service"); err != nil || p["WatchdogUSec"] != uint64(5000000) {
+		t.Errorf("WatchdogUSec = %v, %v", p["WatchdogUSec"], err)
+	}
+	if p, err := v.GetUnitPropertyContext(ctx, "machine.slice", "RequiredBy"); err != nil || len(p.Value.Value().([]string)) != 1 {
+		t.Errorf("RequiredBy = %v, %v", p, err)
+	}
+	if units, err := v.ListUnitsByPatternsContext(ctx, nil, []string{"*.slice"}); err != nil || len(units) != 1 || units[0].Name != "machine.slice" {
+		t.Errorf("ListUnitsByPatterns() = %+v, %v", units, err)
+	}
+
+	changes, _ := v.SubscribeUnitsCustom(time.Millisecond, 0, changed, func(unit string) bool { return unit != "a.service" })
+	select {
+	case event := <-changes:
+		if len(event) != 1 || event["a.service"] == nil {
+			t.Errorf("first change = %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no change sent")
+	}
+	if !v.Connected() {
+		t.Error("not connected")
+	}
+}
+
+func TestVarlinkTimeout(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "io.systemd.Manager")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		// Accept, and never answer.
+		if conn, err := l.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	v := &Varlink{Path: socket, Timeout: 10 * time.Millisecond}
+	if _, err := v.ListUnitsContext(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ListUnitsContext() = %v, want a timeout", err)
+	}
+	(<-accepted).Close()
+}
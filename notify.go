@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/notify"
+	"go.uber.org/zap"
+)
+
+// NotifierConfig sends the changes of units into States, failed and watchdog
+// unless set, to a chat service or by mail: Type slack posts to the webhook
+// URL, telegram has the bot with Token message ChatID, ntfy publishes to the
+// topic URL, with Token if it needs one, and smtp mails From To through
+// Server. Transitions, such as "failed->*" and "*->failed", select changes by
+// the state left as well, instead of States. After a message about a unit,
+// its further changes are held back for Cooldown, DefaultNotifyCooldown
+// unless set, and only the last sent once it's over, counting the others, so
+// a flapping unit doesn't flood the chat. Credential names the systemd
+// credential holding the notifier's secret, which replaces the one in the
+// configuration if systemd passed it: slack's URL, telegram's and ntfy's
+// Token, or smtp's Password.
+type NotifierConfig struct {
+	Type        string
+	URL         string
+	Token       string
+	ChatID      string `mapstructure:"chat_id"`
+	States      []string
+	Transitions []string
+	Cooldown    time.Duration
+	Credential  string
+
+	// Server is the mail server's host:port, and TLS how to secure the
+	// connection: starttls, the default, tls or none. Subject and Body
+	// are templates of the mail, given the notify.Message.
+	Server   string
+	TLS      string
+	Username string
+	Password string
+	From     string
+	To       []string
+	Subject  string
+	Body     string
+}
+
+const DefaultNotifyCooldown = 5 * time.Minute
+
+// notifyQueue is how many messages may wait for a notifier before more are
+// dropped.
+const notifyQueue = 32
+
+func (n NotifierConfig) Validate() error {
+	switch n.Type {
+	case "slack", "ntfy":
+		if n.URL == "" {
+			return fmt.Errorf("notifiers: %s needs a url", n.Type)
+		}
+	case "telegram":
+		if n.Token == "" || n.ChatID == "" {
+			return fmt.Errorf("notifiers: telegram needs a token and a chat_id")
+		}
+	case "smtp":
+		if n.Server == "" || n.From == "" || len(n.To) == 0 {
+			return fmt.Errorf("notifiers: smtp needs a server, from and to")
+		}
+		if _, _, err := net.SplitHostPort(n.Server); err != nil {
+			return fmt.Errorf("notifiers: smtp: server: %v", err)
+		}
+		switch n.TLS {
+		case "", "starttls", "tls", "none":
+		default:
+			return fmt.Errorf("notifiers: smtp: tls must be starttls, tls or none, got %q", n.TLS)
+		}
+		if _, _, err := notify.ParseTemplates(n.Subject, n.Body); err != nil {
+			return fmt.Errorf("notifiers: smtp: %v", err)
+		}
+	default:
+		return fmt.Errorf("notifiers: unknown type %q", n.Type)
+	}
+	for _, state := range n.States {
+		if !knownState(state) {
+			return fmt.Errorf("notifiers: %s: unknown state %q", n.Type, state)
+		}
+	}
+	for _, transition := range n.Transitions {
+		t, ok := splitTransition(transition)
+		if !ok {
+			return fmt.Errorf("notifiers: %s: transition %q isn't from->to", n.Type, transition)
+		}
+		for _, state := range t {
+			if state != "*" && !knownState(state) {
+				return fmt.Errorf("notifiers: %s: transition %q: unknown state %q", n.Type, transition, state)
+			}
+		}
+	}
+	if n.Cooldown < 0 {
+		return fmt.Errorf("notifiers: %s: cooldown must not be negative", n.Type)
+	}
+	return nil
+}
+
+// secret is the field holding the notifier's secret.
+func (n *NotifierConfig) secret() *string {
+	switch n.Type {
+	case "slack":
+		return &n.URL
+	case "smtp":
+		return &n.Password
+	default:
+		return &n.Token
+	}
+}
+
+func (n NotifierConfig) notifier() notify.Notifier {
+	switch n.Type {
+	case "slack":
+		return &notify.Slack{Webhook: n.URL}
+	case "telegram":
+		return &notify.Telegram{Token: n.Token, ChatID: n.ChatID, API: n.URL}
+	case "smtp":
+		subject, body, _ := notify.ParseTemplates(n.Subject, n.Body)
+		tls := n.TLS
+		if tls == "" {
+			tls = "starttls"
+		}
+		return &notify.SMTP{Addr: n.Server, TLS: tls, Username: n.Username, Password: n.Password, From: n.From, To: n.To, Subject: subject, Body: body}
+	default:
+		return &notify.Ntfy{URL: n.URL, Token: n.Token}
+	}
+}
+
+// splitTransition splits "from->to", or "from→to", into its states.
+func splitTransition(transition string) ([2]string, bool) {
+	from, to, ok := strings.Cut(strings.Replace(transition, "→", "->", 1), "->")
+	return [2]string{strings.TrimSpace(from), strings.TrimSpace(to)}, ok
+}
+
+// sender passes a notifier the changes it is configured for, holding back
+// those within the cooldown of a unit's last message.
+type sender struct {
+	mu          sync.Mutex
+	kind        string
+	states      []string
+	transitions [][2]string
+	cooldown    time.Duration
+	out         chan notify.Message
+	sent        map[string]notify.Message
+	held        map[string]*heldMessage
+}
+
+type heldMessage struct {
+	m     notify.Message
+	count int
+}
+
+func newSender(c NotifierConfig) *sender {
+	s := &sender{kind: c.Type, states: c.States, cooldown: c.Cooldown, out: make(chan notify.Message, notifyQueue), sent: map[string]notify.Message{}, held: map[string]*heldMessage{}}
+	if len(s.states) == 0 {
+		s.states = []string{"failed", "watchdog"}
+	}
+	for _, transition := range c.Transitions {
+		t, _ := splitTransition(transition)
+		s.transitions = append(s.transitions, t)
+	}
+	if s.cooldown == 0 {
+		s.cooldown = DefaultNotifyCooldown
+	}
+	return s
+}
+
+// wants reports whether the notifier is configured for a change from
+// previous to state.
+func (s *sender) wants(previous, state string) bool {
+	if len(s.transitions) > 0 {
+		for _, t := range s.transitions {
+			if (t[0] == "*" || t[0] == previous) && (t[1] == "*" || t[1] == state) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, want := range s.states {
+		if want == state {
+			return true
+		}
+	}
+	return false
+}
+
+// offer sends m unless the unit's last message was within the cooldown, in
+// which case it is held back until the cooldown is over.
+func (s *sender) offer(m notify.Message) {
+	if !s.wants(m.Previous, m.State) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.sent[m.Unit]
+	if !ok || m.At.Sub(last.At) >= s.cooldown {
+		s.send(m)
+		return
+	}
+	h, ok := s.held[m.Unit]
+	if !ok {
+		h = &heldMessage{}
+		s.held[m.Unit] = h
+		time.AfterFunc(last.At.Add(s.cooldown).Sub(m.At), func() { s.release(m.Unit) })
+	}
+	h.m = m
+	h.count++
+}
+
+// release sends the last change held back for unit, unless it is to the
+// state its last message was about.
+func (s *sender) release(unit string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.held[unit]
+	delete(s.held, unit)
+	if h == nil || h.m.State == s.sent[unit].State {
+		return
+	}
+	h.m.Suppressed = h.count - 1
+	h.m.At = time.Now()
+	s.send(h.m)
+}
+
+func (s *sender) send(m notify.Message) {
+	s.sent[m.Unit] = m
+	select {
+	case s.out <- m:
+	default:
+		logr.WarnL("notify", "Notifications queued up, dropping one", zap.String("notifier", s.kind), zap.String("unit", m.Unit))
+	}
+}
+
+// run delivers the messages through n.
+func (s *sender) run(n notify.Notifier) {
+	for m := range s.out {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := n.Notify(ctx, m); err != nil {
+			logr.ErrorL("notify", "Unable to send a notification", zap.String("notifier", s.kind), zap.String("unit", m.Unit), zap.Error(err))
+		}
+		cancel()
+	}
+}
+
+// notifying offers each unit's changes of state to the notifiers before
+// rendering them. A unit's state at start up is no change.
+type notifying struct {
+	mu      sync.Mutex
+	last    map[string]string
+	senders []*sender
+	next    monitor.Renderer
+}
+
+func newNotifying(configs []NotifierConfig, next monitor.Renderer) *notifying {
+	n := &notifying{last: map[string]string{}, next: next}
+	for _, c := range configs {
+		s := newSender(c)
+		go s.run(c.notifier())
+		n.senders = append(n.senders, s)
+	}
+	return n
+}
+
+func (n *notifying) Render(e monitor.Event) {
+	if !e.Gone && knownState(e.State) {
+		state := displayState(e)
+		n.mu.Lock()
+		previous, seen := n.last[e.Unit]
+		n.last[e.Unit] = state
+		n.mu.Unlock()
+		if seen && !e.Initial && state != previous {
+			m := notify.Message{Unit: e.Unit, State: state, Previous: previous, At: time.Now()}
+			for _, s := range n.senders {
+				s.offer(m)
+			}
+		}
+	}
+	n.next.Render(e)
+}
@@ -0,0 +1,136 @@
+// Package notify sends the state changes of units to chat services.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Message is a unit's change of state.
+type Message struct {
+	Unit     string
+	State    string
+	Previous string
+	At       time.Time
+	// Suppressed counts the changes of the unit left out since the last
+	// message about it.
+	Suppressed int
+}
+
+// Text is the message as a line of text.
+func (m Message) Text() string {
+	text := m.Unit + " is " + m.State
+	if m.Previous != "" {
+		text += ", was " + m.Previous
+	}
+	if m.Suppressed > 0 {
+		text += fmt.Sprintf(" (%d more changes since the last message)", m.Suppressed)
+	}
+	return text
+}
+
+// Notifier sends messages somewhere.
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// defaultClient is used by notifiers without a Client of their own.
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+func client(c *http.Client) *http.Client {
+	if c == nil {
+		return defaultClient
+	}
+	return c
+}
+
+// send makes req, failing on any status but 2xx.
+func send(c *http.Client, req *http.Request) error {
+	resp, err := client(c).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+func postJSON(ctx context.Context, c *http.Client, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return send(c, req)
+}
+
+// Slack posts to a Slack incoming webhook.
+type Slack struct {
+	Webhook string
+	Client  *http.Client
+}
+
+func (s *Slack) Notify(ctx context.Context, m Message) error {
+	return postJSON(ctx, s.Client, s.Webhook, map[string]string{"text": m.Text()})
+}
+
+// DefaultTelegramAPI is the Telegram Bot API, unless Telegram.API says
+// otherwise.
+const DefaultTelegramAPI = "https://api.telegram.org"
+
+// Telegram sends a message from a Telegram bot to a chat.
+type Telegram struct {
+	Token  string
+	ChatID string
+	API    string
+	Client *http.Client
+}
+
+func (t *Telegram) Notify(ctx context.Context, m Message) error {
+	api := t.API
+	if api == "" {
+		api = DefaultTelegramAPI
+	}
+	return postJSON(ctx, t.Client, strings.TrimSuffix(api, "/")+"/bot"+t.Token+"/sendMessage", map[string]string{
+		"chat_id": t.ChatID,
+		"text":    m.Text(),
+	})
+}
+
+// Ntfy publishes to an ntfy topic, given by its URL such as
+// https://ntfy.sh/mytopic, with an access token if Token is set.
+type Ntfy struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// ntfyPriorities are the ntfy priorities of states, the others default.
+var ntfyPriorities = map[string]string{"failed": "high", "watchdog": "high", "active": "low"}
+
+func (n *Ntfy) Notify(ctx context.Context, m Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, strings.NewReader(m.Text()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", m.Unit+" "+m.State)
+	req.Header.Set("Tags", m.State)
+	if priority, ok := ntfyPriorities[m.State]; ok {
+		req.Header.Set("Priority", priority)
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return send(n.Client, req)
+}
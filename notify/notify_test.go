@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNotifiers(t *testing.T) {
+	type request struct {
+		path, auth, title, body string
+	}
+	var got request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = request{r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("Title"), string(body)}
+	}))
+	defer srv.Close()
+
+	m := Message{Unit: "nginx.service", State: "failed", Previous: "active", Suppressed: 2}
+	const text = "nginx.service is failed, was active (2 more changes since the last message)"
+	if m.Text() != text {
+		t.Errorf("Text() = %q, want %q", m.Text(), text)
+	}
+	for _, tc := range []struct {
+		n    Notifier
+		want request
+	}{
+		{&Slack{Webhook: srv.URL + "/hook"}, request{path: "/hook", body: `{"text":"` + text + `"}`}},
+		{&Telegram{Token: "123:abc", ChatID: "42", API: srv.URL}, request{path: "/bot123:abc/sendMessage", body: `{"chat_id":"42","text":"` + text + `"}`}},
+		{&Ntfy{URL: srv.URL + "/alerts", Token: "tk"}, request{path: "/alerts", auth: "Bearer tk", title: "nginx.service failed", body: text}},
+	} {
+		got = request{}
+		if err := tc.n.Notify(context.Background(), m); err != nil {
+			t.Errorf("%T: %v", tc.n, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%T sent %+v, want %+v", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestNotifyStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	if err := (&Slack{Webhook: srv.URL}).Notify(context.Background(), Message{Unit: "a.service", State: "failed"}); err == nil {
+		t.Error("403 taken for success")
+	}
+}
@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Default templates of a mail's subject and body, given the Message.
+const (
+	DefaultSubject = "{{.Unit}} is {{.State}}"
+	DefaultBody    = "{{.Text}}\n\nAt {{.At.Format \"2006-01-02 15:04:05 MST\"}}.\n"
+)
+
+// SMTP mails messages From one address To others through the mail server
+// at Addr, host:port. TLS is "starttls", upgrading the connection and
+// failing if the server can't, "tls" for a connection that starts out
+// encrypted, or "none". Username and Password, if set, log in.
+type SMTP struct {
+	Addr     string
+	TLS      string
+	Username string
+	Password string
+	From     string
+	To       []string
+	Subject  *template.Template
+	Body     *template.Template
+}
+
+// ParseTemplates parses the subject and body templates, falling back to
+// DefaultSubject and DefaultBody.
+func ParseTemplates(subject, body string) (*template.Template, *template.Template, error) {
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	if body == "" {
+		body = DefaultBody
+	}
+	s, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := template.New("body").Parse(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, b, nil
+}
+
+// mail is the message m as a mail.
+func (s *SMTP) mail(m Message) ([]byte, error) {
+	var subject, body bytes.Buffer
+	if err := s.Subject.Execute(&subject, m); err != nil {
+		return nil, err
+	}
+	if err := s.Body.Execute(&body, m); err != nil {
+		return nil, err
+	}
+	var mail bytes.Buffer
+	fmt.Fprintf(&mail, "From: %s\r\n", s.From)
+	fmt.Fprintf(&mail, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&mail, "Subject: %s\r\n", strings.Join(strings.Fields(subject.String()), " "))
+	fmt.Fprintf(&mail, "Date: %s\r\n", m.At.Format(time.RFC1123Z))
+	mail.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	mail.WriteString(strings.ReplaceAll(strings.ReplaceAll(body.String(), "\r\n", "\n"), "\n", "\r\n"))
+	return mail.Bytes(), nil
+}
+
+func (s *SMTP) Notify(ctx context.Context, m Message) error {
+	mail, err := s.mail(m)
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{ServerName: host}
+	var conn net.Conn
+	if s.TLS == "tls" {
+		conn, err = (&tls.Dialer{Config: config}).DialContext(ctx, "tcp", s.Addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", s.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if s.TLS == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s doesn't offer STARTTLS", s.Addr)
+		}
+		if err := c.StartTLS(config); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, to := range s.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(mail); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
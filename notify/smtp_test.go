@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one mail, sending the commands and data it got on
+// received.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		var got strings.Builder
+		_ = tp.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				received <- got.String()
+				return
+			}
+			got.WriteString(line + "\n")
+			cmd, _, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(cmd) {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250 fake")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				data, _ := bufio.NewReader(tp.DotReader()).ReadString(0)
+				got.WriteString(data)
+				_ = tp.PrintfLine("250 queued")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				received <- got.String()
+				return
+			default:
+				_ = tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), received
+}
+
+func TestSMTP(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	subject, body, err := ParseTemplates("[{{.State}}] {{.Unit}}", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &SMTP{Addr: addr, TLS: "none", From: "leds@example.com", To: []string{"ops@example.com"}, Subject: subject, Body: body}
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Notify(ctx, Message{Unit: "nginx.service", State: "failed", Previous: "active", At: at}); err != nil {
+		t.Fatal(err)
+	}
+	got := <-received
+	for _, want := range []string{
+		"MAIL FROM:<leds@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"Subject: [failed] nginx.service\n",
+		"nginx.service is failed, was active\n\nAt 2026-10-16 12:00:00 UTC.\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("session lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestSMTPStartTLSRequired(t *testing.T) {
+	addr, _ := fakeSMTP(t)
+	subject, body, _ := ParseTemplates("", "")
+	s := &SMTP{Addr: addr, TLS: "starttls", From: "a@example.com", To: []string{"b@example.com"}, Subject: subject, Body: body}
+	if err := s.Notify(context.Background(), Message{Unit: "a.service", State: "failed"}); err == nil {
+		t.Error("sent without STARTTLS")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/notify"
+	"go.uber.org/zap"
+)
+
+func TestNotifyCooldown(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	s := newSender(NotifierConfig{Type: "ntfy", States: []string{"failed", "active"}, Cooldown: 20 * time.Millisecond})
+	n := &notifying{last: map[string]string{}, senders: []*sender{s}, next: &collect{}}
+	for _, e := range []monitor.Event{
+		{Unit: "a.service", State: "active", Initial: true},
+		{Unit: "a.service", State: "failed"},
+		{Unit: "a.service", State: "activating"},
+		{Unit: "a.service", State: "active"},
+		{Unit: "a.service", State: "failed"},
+		{Unit: "a.service", State: "active"},
+	} {
+		n.Render(e)
+	}
+
+	first := <-s.out
+	if first.State != "failed" || first.Previous != "active" || first.Suppressed != 0 {
+		t.Errorf("first message = %+v, want failed, was active", first)
+	}
+	select {
+	case m := <-s.out:
+		if m.State != "active" || m.Suppressed != 2 {
+			t.Errorf("after the cooldown: %+v, want active with 2 suppressed", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nothing sent after the cooldown")
+	}
+
+	// Flapping back to the state last sent sends nothing more.
+	n.Render(monitor.Event{Unit: "a.service", State: "failed"})
+	n.Render(monitor.Event{Unit: "a.service", State: "active"})
+	select {
+	case m := <-s.out:
+		t.Errorf("sent %+v after flapping back", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNotifyStates(t *testing.T) {
+	s := newSender(NotifierConfig{Type: "slack", URL: "http://example.com"})
+	for _, state := range []string{"active", "inactive", "watchdog"} {
+		s.offer(notify.Message{Unit: "a.service", State: state, At: time.Now()})
+	}
+	if m := <-s.out; m.State != "watchdog" || len(s.out) != 0 {
+		t.Errorf("sent %+v and %d more, want only watchdog", m, len(s.out))
+	}
+	for _, c := range []NotifierConfig{
+		{Type: "slack"},
+		{Type: "telegram", Token: "t"},
+		{Type: "ntfy", URL: "https://ntfy.sh/x", States: []string{"broken"}},
+		{Type: "pager"},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v accepted", c)
+		}
+	}
+}
+
+func TestNotifyTransitions(t *testing.T) {
+	c := NotifierConfig{Type: "smtp", Server: "mail:25", From: "a@example.com", To: []string{"b@example.com"}, Transitions: []string{"failed->*", "* → failed"}}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := newSender(c)
+	for _, tc := range []struct {
+		previous, state string
+		want            bool
+	}{
+		{"active", "failed", true},
+		{"failed", "activating", true},
+		{"active", "watchdog", false},
+		{"inactive", "active", false},
+	} {
+		if got := s.wants(tc.previous, tc.state); got != tc.want {
+			t.Errorf("%s->%s wanted: %v, want %v", tc.previous, tc.state, got, tc.want)
+		}
+	}
+	for _, c := range []NotifierConfig{
+		{Type: "smtp", Server: "mail", From: "a@example.com", To: []string{"b@example.com"}},
+		{Type: "smtp", Server: "mail:25", From: "a@example.com", To: []string{"b@example.com"}, Subject: "{{.Unit"},
+		{Type: "smtp", Server: "mail:25", From: "a@example.com", To: []string{"b@example.com"}, TLS: "ssl"},
+		{Type: "slack", URL: "http://example.com", Transitions: []string{"failed"}},
+		{Type: "slack", URL: "http://example.com", Transitions: []string{"*->broken"}},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v accepted", c)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+)
+
+// oneshot shows the current state of every unit on the strips in a single
+// frame, leaving them lit, for cron, kiosks without the daemon and scripts.
+func oneshot(conn monitor.Conn, render monitor.Renderer, strips []*strip.Strip) error {
+	// One frame has no time to hold any state back in, or to flash.
+	C.MinDisplay, C.Coalesce, C.Flash.Duration = 0, 0, 0
+	for i := range C.Services {
+		C.Services[i].MinDisplay = 0
+	}
+	if err := monitor.Once(monitorConfig(conn, 0), render); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, service := range C.Services {
+		if service.Source == "file" {
+			render.Render(monitor.Event{Unit: service.Unit, State: service.File.state(now), Initial: true})
+		}
+	}
+	for _, s := range strips {
+		// Nor can it blink, so blinking pixels are shown lit.
+		for _, pixel := range s.Pixels {
+			pixel.Lock()
+			pixel.Pattern = led.Pattern{}
+			pixel.FlashUntil = time.Time{}
+			pixel.Unlock()
+		}
+		if err := s.Draw(now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
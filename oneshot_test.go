@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+// listConn lists units in the states given, and knows nothing more of them.
+type listConn struct {
+	monitor.Conn
+	states map[string]string
+}
+
+func (c listConn) ListUnitsByNamesContext(ctx context.Context, units []string) ([]systemd.UnitStatus, error) {
+	var out []systemd.UnitStatus
+	for _, unit := range units {
+		out = append(out, systemd.UnitStatus{Name: unit, LoadState: "loaded", ActiveState: c.states[unit]})
+	}
+	return out, nil
+}
+
+func (listConn) GetUnitPropertyContext(context.Context, string, string) (*systemd.Property, error) {
+	return nil, errors.New("no properties")
+}
+
+func (listConn) GetUnitPropertiesContext(context.Context, string) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func (listConn) GetUnitTypePropertiesContext(context.Context, string, string) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func TestOneshotShowsStatesNotFlashes(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	C = Config{Theme: "classic", Services: []Service{{Unit: "a.service"}}, Flash: FlashConfig{Colour: "ffffffff", Duration: time.Minute}}
+	defer func() { C, tracked = Config{}, nil }()
+	length, channels := 1, 3
+	s, err := strip.New(logr, &strip.Terminal{Out: io.Discard, Channels: channels}, &length, &channels, strip.Opts{Power: strip.Power{MilliampsPerChannel: strip.DefaultMilliampsPerChannel}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pixel, err := s.Add("a.service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracked = []*led.Led{pixel}
+
+	conn := listConn{states: map[string]string{"a.service": "active"}}
+	if err := oneshot(conn, renderer{}, []*strip.Strip{s}); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := hex.DecodeString(themes["classic"]["active"])
+	if frame, _ := s.Snapshot(); !bytes.Equal(frame, want[:channels]) {
+		t.Errorf("frame %x, want the active colour %x", frame, want[:channels])
+	}
+}
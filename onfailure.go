@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/monitor"
+	"go.uber.org/zap"
+)
+
+// OnFailureConfig animates Colour from a failed unit's pixel to the pixel of
+// its OnFailure= handler when the handler starts within Window of the
+// failure.
+type OnFailureConfig struct {
+	Colour string
+	Window time.Duration
+}
+
+// onFailureStep is how long each pixel of the chain is lit.
+const onFailureStep = 150 * time.Millisecond
+
+var (
+	handlersMu sync.Mutex
+	// handlers maps OnFailure= units to the unit whose failure they handle
+	// and when it failed.
+	handlers = map[string]pendingHandler{}
+)
+
+type pendingHandler struct {
+	source string
+	failed time.Time
+}
+
+// watchHandlers records the OnFailure= handlers of a unit that just failed.
+func watchHandlers(unit string) {
+	if sysd == nil || C.OnFailure.Window <= 0 {
+		return
+	}
+	var units []string
+	ctx, cancel := C.Systemd.context()
+	defer cancel()
+	err := monitor.Traced("GetUnitProperty", unit, func() error {
+		property, err := sysd.GetUnitPropertyContext(ctx, unit, "OnFailure")
+		if err == nil {
+			units, _ = property.Value.Value().([]string)
+		}
+		return err
+	})
+	if err != nil {
+		logr.ErrorL("dbus", "Failed to get property:", zap.String("unit", unit), zap.Error(err))
+		return
+	}
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	for _, handler := range units {
+		handlers[handler] = pendingHandler{source: unit, failed: time.Now()}
+	}
+}
+
+// handlerStarted animates the chain if unit is the handler of a recent
+// failure.
+func handlerStarted(unit string) {
+	handlersMu.Lock()
+	pending, ok := handlers[unit]
+	delete(handlers, unit)
+	handlersMu.Unlock()
+	if !ok || time.Since(pending.failed) > C.OnFailure.Window {
+		return
+	}
+	source, handler := pixelOf(pending.source), pixelOf(unit)
+	if source == nil || handler == nil {
+		return
+	}
+	logr.Info("OnFailure handler started", zap.String("unit", pending.source), zap.String("handler", unit))
+	colour := C.OnFailure.Colour
+	go func() {
+		for i := 0; i < 3; i++ {
+			source.Flash(colour, onFailureStep)
+			time.Sleep(onFailureStep)
+			handler.Flash(colour, onFailureStep)
+			time.Sleep(onFailureStep)
+		}
+	}()
+}
+
+// pixelOf returns the pixel showing unit, that of its group for members.
+func pixelOf(unit string) *led.Led {
+	for _, pixel := range tracked {
+		if pixel.Unit != unit {
+			continue
+		}
+		if g := groupOf(pixel); g != nil {
+			return g.pixel
+		}
+		return pixel
+	}
+	return nil
+}
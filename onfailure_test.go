@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"go.uber.org/zap"
+)
+
+func TestHandlerStarted(t *testing.T) {
+	source, handler := &led.Led{Unit: "a.service"}, &led.Led{Unit: "a-failed.service"}
+	tracked = []*led.Led{source, handler}
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	C = Config{OnFailure: OnFailureConfig{Colour: "ff00ff00", Window: time.Minute}}
+	defer func() { tracked, C = nil, Config{} }()
+
+	flashed := func(pixel *led.Led) bool {
+		pixel.RLock()
+		defer pixel.RUnlock()
+		return pixel.FlashColour == "ff00ff00"
+	}
+	handlerStarted("a-failed.service")
+	time.Sleep(10 * time.Millisecond)
+	if flashed(source) {
+		t.Error("animated without a failure")
+	}
+
+	handlers["a-failed.service"] = pendingHandler{source: "a.service", failed: time.Now()}
+	handlerStarted("a-failed.service")
+	time.Sleep(onFailureStep + 10*time.Millisecond)
+	if !flashed(source) || !flashed(handler) {
+		t.Error("chain not animated")
+	}
+	if _, ok := handlers["a-failed.service"]; ok {
+		t.Error("handler still pending")
+	}
+}
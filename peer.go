@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+// PeerConfig is the instance that mirror follows: the URL of its HTTP
+// listener, and the Token it asks for, or the systemd Credential holding it.
+type PeerConfig struct {
+	URL        string
+	Token      string
+	Credential string
+}
+
+const (
+	// peerBuffer is how many events may wait for a peer before it is
+	// dropped, to reconnect and catch up.
+	peerBuffer = 64
+	// peerKeepalive is how often an idle stream sends an empty line, and
+	// peerSilence how long mirror waits for one before reconnecting.
+	peerKeepalive = 15 * time.Second
+	peerSilence   = 3 * peerKeepalive
+	// peerBackoff is the longest mirror waits between connections.
+	peerBackoff = 30 * time.Second
+	// peerLostDim darkens the frame last shown while the peer is lost.
+	peerLostDim = 0.75
+)
+
+// eventStream keeps the last state of every unit and passes each event on to
+// the peers following /events before rendering it.
+type eventStream struct {
+	mu    sync.Mutex
+	last  map[string]monitor.Event
+	peers map[chan monitor.Event]bool
+	next  monitor.Renderer
+}
+
+// stream is the event stream served at /events, nil unless the daemon runs
+// with an HTTP listener.
+var stream *eventStream
+
+func newEventStream(next monitor.Renderer) *eventStream {
+	return &eventStream{last: map[string]monitor.Event{}, peers: map[chan monitor.Event]bool{}, next: next}
+}
+
+func (s *eventStream) Render(e monitor.Event) {
+	s.mu.Lock()
+	if e.Gone {
+		delete(s.last, e.Unit)
+	} else {
+		s.last[e.Unit] = e
+	}
+	for peer := range s.peers {
+		select {
+		case peer <- e:
+		default:
+			logr.WarnL("peer", "Peer fell behind, dropping it", zap.String("unit", e.Unit))
+			delete(s.peers, peer)
+			close(peer)
+		}
+	}
+	s.mu.Unlock()
+	s.next.Render(e)
+}
+
+// follow returns the state of every unit, as initial events, and a channel
+// of the events after, closed if the peer falls behind.
+func (s *eventStream) follow() ([]monitor.Event, chan monitor.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var current []monitor.Event
+	for _, e := range s.last {
+		e.Initial = true
+		current = append(current, e)
+	}
+	sort.Slice(current, func(i, j int) bool { return current[i].Unit < current[j].Unit })
+	peer := make(chan monitor.Event, peerBuffer)
+	s.peers[peer] = true
+	return current, peer
+}
+
+func (s *eventStream) unfollow(peer chan monitor.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.peers[peer] {
+		delete(s.peers, peer)
+		close(peer)
+	}
+}
+
+// ServeHTTP streams the state of every unit, then their changes, one JSON
+// event per line as in a replay file.
+func (s *eventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	current, peer := s.follow()
+	defer s.unfollow(peer)
+	start := time.Now()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	out := json.NewEncoder(w)
+	for _, e := range current {
+		if out.Encode(replayed(e, 0)) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+	keepalive := time.NewTicker(peerKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := w.Write([]byte("\n")); err != nil {
+				return
+			}
+		case e, ok := <-peer:
+			if !ok || out.Encode(replayed(e, time.Since(start))) != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// framesHandler streams the frames of s, one per line in the snapshot query's
+// format, as they change.
+func framesHandler(s *strip.Strip) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		tick := time.NewTicker(s.Interval)
+		defer tick.Stop()
+		var last []byte
+		var sent time.Time
+		for {
+			line := ""
+			if frame, channels := s.Snapshot(); frame != nil && !bytes.Equal(frame, last) {
+				line = fmt.Sprintf("%d %x\n", channels, frame)
+				last = frame
+			} else if time.Since(sent) >= peerKeepalive {
+				line = "\n"
+			}
+			if line != "" {
+				if _, err := io.WriteString(w, line); err != nil {
+					return
+				}
+				flusher.Flush()
+				sent = time.Now()
+			}
+			select {
+			case <-r.Context().Done():
+				return
+			case <-tick.C:
+			}
+		}
+	}
+}
+
+// peer follows another instance's frames.
+type peer struct {
+	c         PeerConfig
+	client    *http.Client
+	connected atomic.Bool
+}
+
+func (p *peer) Connected() bool { return p.connected.Load() }
+
+// follow shows the peer's frames on pixels until the stream ends or falls
+// silent.
+func (p *peer) follow(pixels []*led.Led) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.c.URL, "/")+"/frames", nil)
+	if err != nil {
+		return err
+	}
+	if p.c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.c.Token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	p.connected.Store(true)
+	defer p.connected.Store(false)
+	silence := time.AfterFunc(peerSilence, cancel)
+	defer silence.Stop()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		silence.Reset(peerSilence)
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		frame, channels, err := parseSnapshot(scanner.Text())
+		if err != nil {
+			logr.ErrorL("peer", "Unable to parse the peer's frame", zap.Error(err))
+			continue
+		}
+		showFrame(pixels, frame, channels)
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return fmt.Errorf("stream ended")
+}
+
+// showFrame lights pixels as the peer's frame, pixel for pixel in strip
+// order, leaving those past its end dark.
+func showFrame(pixels []*led.Led, frame []byte, channels int) {
+	for i, pixel := range pixels {
+		var c [4]byte
+		if i < len(frame)/channels {
+			px := frame[i*channels : (i+1)*channels]
+			if channels == 1 {
+				c = [4]byte{px[0], px[0], px[0], 0}
+			} else {
+				copy(c[:], px)
+			}
+		}
+		pixel.Lock()
+		setColour(pixel, hex.EncodeToString(c[:]))
+		pixel.SetDim(0)
+		pixel.Unlock()
+	}
+}
+
+// run follows the peer, connecting again, less often the longer it fails,
+// whenever the stream ends. Until it is back the frame last shown is dimmed.
+func (p *peer) run(pixels []*led.Led) {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := p.follow(pixels)
+		for _, pixel := range pixels {
+			pixel.Lock()
+			pixel.SetDim(peerLostDim)
+			pixel.Unlock()
+		}
+		if time.Since(start) > peerSilence {
+			backoff = time.Second
+		}
+		logr.WarnL("peer", "Lost the peer, connecting again", zap.String("url", p.c.URL), zap.Duration("retry", backoff), zap.Error(err))
+		time.Sleep(backoff)
+		backoff = min(2*backoff, peerBackoff)
+	}
+}
+
+// mirror shows another instance's rendered frames on the main strip, as a
+// remote status panel on a machine without systemd access. The local
+// configuration only describes the strips. It runs until stopped and returns
+// the process exit status.
+func mirror(args []string) int {
+	flags := flag.NewFlagSet("mirror", flag.ExitOnError)
+	path := flags.String("config", "", "configuration file")
+	url := flags.String("peer", "", "URL of the instance's HTTP listener, peer.url unless set")
+	_ = flags.Parse(args)
+
+	Configuration(*path)
+	configureLimits(C.Log)
+	if *url != "" {
+		C.Peer.URL = *url
+	}
+	if C.Peer.URL == "" {
+		fmt.Fprintln(os.Stderr, "no peer: set peer.url or --peer")
+		return 2
+	}
+	s, extras, err := initStrips()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var pixels []*led.Led
+	for n := 1; n <= C.Strip.Length; n++ {
+		pixel, err := s.Reserve("mirror", n)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		pixels = append(pixels, pixel)
+	}
+	p := &peer{c: C.Peer, client: &http.Client{}}
+	logr.Info("Mirroring", zap.String("peer", C.Peer.URL))
+	go p.run(pixels)
+	go closeOnStop(s, extras)
+	runStrips(s, extras)
+	return 0
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+func TestEventStream(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	stream = newEventStream(&collect{})
+	defer func() { stream = nil }()
+	stream.Render(monitor.Event{Unit: "b.service", State: "failed", Result: "exit-code"})
+	stream.Render(monitor.Event{Unit: "a.service", State: "active", SubState: "running"})
+	srv := httptest.NewServer(httpHandler(HTTPConfig{}, nil))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	next := func() monitor.Event {
+		for lines.Scan() {
+			if len(lines.Bytes()) == 0 {
+				continue
+			}
+			e, err := parseReplayEvent(lines.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return e.event()
+		}
+		t.Fatal("stream ended")
+		return monitor.Event{}
+	}
+	for _, want := range []monitor.Event{
+		{Unit: "a.service", State: "active", SubState: "running", Initial: true},
+		{Unit: "b.service", State: "failed", Result: "exit-code", Initial: true},
+	} {
+		if e := next(); e != want {
+			t.Errorf("initial event %+v, want %+v", e, want)
+		}
+	}
+	for _, want := range []monitor.Event{
+		{Unit: "a.service", State: "failed", SubState: "failed"},
+		{Unit: "c@1.service", Template: "c@.service", Gone: true},
+	} {
+		stream.Render(want)
+		if e := next(); e != want {
+			t.Errorf("event %+v, want %+v", e, want)
+		}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	length, channels := 3, 3
+	s, err := strip.New(logr, &strip.Terminal{Out: io.Discard, Channels: channels}, &length, &channels, strip.Opts{Interval: 10 * time.Millisecond, Power: strip.Power{MilliampsPerChannel: strip.DefaultMilliampsPerChannel}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shown []*led.Led
+	for n := 1; n <= length; n++ {
+		pixel, err := s.Reserve("mirror", n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shown = append(shown, pixel)
+	}
+	setColour(shown[0], "ff000000")
+	setColour(shown[2], "0000ff00")
+	if err := s.Draw(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(httpHandler(HTTPConfig{Token: "s3cret"}, s))
+	defer srv.Close()
+
+	pixels := []*led.Led{{}, {}, {}, {}}
+	if err := (&peer{c: PeerConfig{URL: srv.URL}, client: srv.Client()}).follow(pixels); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("without the token: %v, want 401", err)
+	}
+
+	p := &peer{c: PeerConfig{URL: srv.URL + "/", Token: "s3cret"}, client: srv.Client()}
+	done := make(chan error)
+	go func() { done <- p.follow(pixels) }()
+	await := func(want []string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			var got []string
+			for _, pixel := range pixels {
+				pixel.RLock()
+				got = append(got, pixel.Colour)
+				pixel.RUnlock()
+			}
+			if strings.Join(got, " ") == strings.Join(want, " ") {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("mirrored %v, want %v", got, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	await([]string{"ff000000", "00000000", "0000ff00", "00000000"})
+	if !p.Connected() {
+		t.Error("not connected while following")
+	}
+	setColour(shown[0], "00ff0000")
+	if err := s.Draw(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	await([]string{"00ff0000", "00000000", "0000ff00", "00000000"})
+
+	srv.CloseClientConnections()
+	if err := <-done; err == nil {
+		t.Error("follow returned no error once disconnected")
+	}
+	if p.Connected() {
+		t.Error("connected after the stream ended")
+	}
+}
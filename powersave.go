@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shift/systemd-status-leds/strip"
+)
+
+// PowerSaveConfig shows active units as Glow, dark unless set, so only
+// transitions and problems draw much power. Since a strip that is mostly dark
+// doesn't show where each unit is, every unit's pixel is lit in its active
+// colour in turn at start up, the whole strip taking Sweep.
+type PowerSaveConfig struct {
+	Enabled bool
+	Glow    string
+	Sweep   time.Duration
+}
+
+func (p PowerSaveConfig) Validate() error {
+	if !p.Enabled {
+		return nil
+	}
+	if p.Glow != "" {
+		if err := checkColour("power_save.glow", p.Glow); err != nil {
+			return err
+		}
+	}
+	if p.Sweep < 0 {
+		return fmt.Errorf("power_save.sweep must not be negative")
+	}
+	return nil
+}
+
+// glow is the colour of an active unit in power save mode.
+func (p PowerSaveConfig) glow() string {
+	if p.Glow == "" {
+		return "00000000"
+	}
+	return p.Glow
+}
+
+// sweep lights the pixel of every unit on the strips in turn, in its active
+// colour, the whole sweep taking over.
+func sweep(strips []*strip.Strip, over time.Duration) {
+	pixels := pixelsOf(strips)
+	if len(pixels) == 0 {
+		return
+	}
+	step := over / time.Duration(len(pixels))
+	for _, pixel := range pixels {
+		pixel.Flash(colourFor(pixel.Unit, "active"), step)
+		markDirty()
+		time.Sleep(step)
+	}
+}
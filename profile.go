@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/strip"
+	"github.com/shift/systemd-status-leds/telemetry"
+	"go.uber.org/zap"
+)
+
+// Profile is a named set of colours and brightness that can be switched to at
+// runtime, e.g. an "ops" view and a dimmer "demo" view. ProblemsOnly turns
+// the problems only mode on while the profile is active, e.g. at night.
+type Profile struct {
+	Name         string
+	Colours      map[string]string
+	Brightness   float64                      // percent, 100 unless set
+	Services     map[string]map[string]string // unit -> state -> colour
+	ProblemsOnly bool                         `mapstructure:"problems_only"`
+}
+
+// nominal are the states left dark in problems only mode.
+var nominal = []string{"active", "armed", "absent"}
+
+// problemsOnly reports whether pixels in a nominal state are left dark, so
+// the strip only lights up for problems.
+func problemsOnly() bool {
+	profileMu.RLock()
+	defer profileMu.RUnlock()
+	return C.ProblemsOnly || profile != nil && profile.ProblemsOnly
+}
+
+func nominalState(state string) bool {
+	for _, s := range nominal {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+type ProfileSchedule struct {
+	At      string // HH:MM local time
+	Profile string
+}
+
+var (
+	profileMu sync.RWMutex
+	profile   *Profile
+)
+
+func findProfile(name string) *Profile {
+	for i := range C.Profiles {
+		if C.Profiles[i].Name == name {
+			return &C.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// colourFor resolves the colour of unit in state, preferring a matching
+// status text, unless failed, over the active profile over the service's
+// colours over the strip's colours over the accessibility palette over the
+// theme.
+func colourFor(unit string, state string) string {
+	if state != "failed" {
+		if c, ok := statusColour(unit); ok {
+			return c
+		}
+	}
+	profileMu.RLock()
+	p := profile
+	profileMu.RUnlock()
+	if p != nil {
+		if c, ok := p.Services[unit][state]; ok {
+			return c
+		}
+	}
+	for _, service := range C.Services {
+		if !service.serves(unit) {
+			continue
+		}
+		if style, ok := service.Colours[state]; ok && style.Colour != "" {
+			return style.Colour
+		}
+	}
+	if p != nil {
+		if c, ok := p.Colours[state]; ok {
+			return c
+		}
+	}
+	if c, ok := C.Strip.Colours[state]; ok {
+		return c
+	}
+	if c, ok := palettes[C.Accessibility.Palette][state]; ok {
+		return c
+	}
+	return themes[C.Theme][state]
+}
+
+// setState records the state of a pixel and shows its colour.
+func setState(pixel *led.Led, state string) {
+	if pixel.Status != state {
+		transitions.Inc(telemetry.Attr{Key: "unit", Value: pixel.Unit}, telemetry.Attr{Key: "state", Value: state})
+		touch()
+		if C.Flash.Duration > 0 {
+			pixel.Flash(C.Flash.Colour, C.Flash.Duration)
+		}
+	}
+	if state != "failed" {
+		pixel.SetAcknowledged(false)
+	}
+	pixel.SetStatus(state)
+	colour := sliceColour(pixel.Unit, state, colourFor(pixel.Unit, state))
+	if state == "active" && C.PowerSave.Enabled {
+		colour = C.PowerSave.glow()
+	}
+	if nominalState(state) && problemsOnly() {
+		colour = "00000000"
+	}
+	if pixel.Acknowledged {
+		colour = C.Acknowledge.overlay(colour)
+	}
+	setColour(pixel, colour)
+	pixel.SetPattern(patternFor(pixel.Unit, state))
+	markDirty()
+	if g := groupOf(pixel); g != nil {
+		g.update()
+	}
+	applyRules()
+}
+
+// setColour sets the colour of pixel, logging a colour it rejects.
+func setColour(pixel *led.Led, colour string) {
+	if err := pixel.SetColour(colour); err != nil {
+		logr.ErrorL("colour", "Invalid colour", zap.String("unit", pixel.Unit), zap.Error(err))
+	}
+}
+
+// dimColour divides every channel of an eight digit hex colour by n.
+func dimColour(colour string, n uint64) string {
+	return scaleColour(colour, 1/float64(n))
+}
+
+// scaleColour multiplies every channel of an eight digit hex colour by f,
+// saturating at full brightness.
+func scaleColour(colour string, f float64) string {
+	v, err := strconv.ParseUint(colour, 16, 32)
+	if err != nil {
+		return colour
+	}
+	var out uint64
+	for shift := 0; shift < 32; shift += 8 {
+		out |= uint64(math.Min(float64(v>>shift&0xff)*f, 255)) << shift
+	}
+	return fmt.Sprintf("%08x", out)
+}
+
+// switchProfile activates the named profile and recolours every pixel of the
+// strips.
+func switchProfile(strips []*strip.Strip, name string) error {
+	p := findProfile(name)
+	if p == nil {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	profileMu.Lock()
+	profile = p
+	profileMu.Unlock()
+	brightness := p.Brightness
+	if brightness == 0 {
+		brightness = 100
+	}
+	for _, s := range strips {
+		s.SetBrightness(brightness / 100)
+		for _, pixel := range s.Pixels {
+			if pixel.Status != "" {
+				setState(pixel, pixel.Status)
+			}
+		}
+	}
+	logr.Info("Profile switched", zap.String("profile", name))
+	markDirty()
+	return nil
+}
+
+// cycleProfile moves on to the profile after the active one.
+func cycleProfile(strips []*strip.Strip) error {
+	if len(C.Profiles) == 0 {
+		return errors.New("no profiles configured")
+	}
+	profileMu.RLock()
+	next := 0
+	for i := range C.Profiles {
+		if &C.Profiles[i] == profile {
+			next = (i + 1) % len(C.Profiles)
+		}
+	}
+	profileMu.RUnlock()
+	return switchProfile(strips, C.Profiles[next].Name)
+}
+
+// profileLoop cycles profiles on cycleSignals and applies the schedule.
+func profileLoop(strips []*strip.Strip) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, cycleSignals...)
+	ticker := time.NewTicker(time.Minute)
+	for {
+		select {
+		case <-signals:
+			if err := cycleProfile(strips); err != nil {
+				logr.Info("Cycle profile", zap.Error(err))
+			}
+		case now := <-ticker.C:
+			for _, entry := range C.ProfileSchedule {
+				if now.Format("15:04") == entry.At {
+					if err := switchProfile(strips, entry.Profile); err != nil {
+						logr.Error("Scheduled profile", zap.Error(err))
+					}
+				}
+			}
+		}
+	}
+}
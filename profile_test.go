@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+func TestProblemsOnly(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	C = Config{Theme: "classic", Profiles: []Profile{{Name: "night", ProblemsOnly: true}}}
+	defer func() { C, profile = Config{}, nil }()
+	pixel := &led.Led{}
+	pixel.Unit = "a.service"
+
+	setState(pixel, "active")
+	if pixel.Colour != themes["classic"]["active"] {
+		t.Errorf("active by day: %s, want the theme's colour", pixel.Colour)
+	}
+	profile = &C.Profiles[0]
+	for state, want := range map[string]string{
+		"active":   "00000000",
+		"armed":    "00000000",
+		"failed":   themes["classic"]["failed"],
+		"inactive": themes["classic"]["inactive"],
+	} {
+		setState(pixel, state)
+		if pixel.Colour != want {
+			t.Errorf("%s at night: %s, want %s", state, pixel.Colour, want)
+		}
+	}
+	profile = nil
+	C.ProblemsOnly = true
+	setState(pixel, "active")
+	if pixel.Colour != "00000000" {
+		t.Errorf("active with problems_only: %s, want dark", pixel.Colour)
+	}
+}
+
+func TestPowerSave(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	C = Config{Theme: "classic", PowerSave: PowerSaveConfig{Enabled: true}}
+	defer func() { C = Config{} }()
+	pixel := &led.Led{}
+	pixel.Unit = "a.service"
+
+	for state, want := range map[string]string{
+		"active":     "00000000",
+		"activating": themes["classic"]["activating"],
+		"failed":     themes["classic"]["failed"],
+	} {
+		setState(pixel, state)
+		if pixel.Colour != want {
+			t.Errorf("%s in power save: %s, want %s", state, pixel.Colour, want)
+		}
+	}
+	C.PowerSave.Glow = "00010000"
+	setState(pixel, "active")
+	if pixel.Colour != "00010000" {
+		t.Errorf("active with a glow: %s, want 00010000", pixel.Colour)
+	}
+	C.PowerSave.Glow = "green"
+	if C.PowerSave.Validate() == nil {
+		t.Error("an invalid glow passed validation")
+	}
+}
+
+func TestSwitchProfileRecoloursEveryStrip(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	C = Config{Theme: "classic", Profiles: []Profile{{Name: "night", Brightness: 20, ProblemsOnly: true}}}
+	defer func() { C, profile = Config{}, nil }()
+	var strips []*strip.Strip
+	for i := 0; i < 2; i++ {
+		length, channels := 1, 3
+		s, err := strip.New(logr, &strip.Terminal{Out: io.Discard, Channels: channels}, &length, &channels, strip.Opts{Power: strip.Power{MilliampsPerChannel: strip.DefaultMilliampsPerChannel}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pixel, err := s.Add(fmt.Sprintf("unit%d.service", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		setState(pixel, "active")
+		strips = append(strips, s)
+	}
+	if err := switchProfile(strips, "night"); err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range strips {
+		if s.Brightness() != 0.2 || s.Pixels[0].Colour != "00000000" {
+			t.Errorf("strip %d: brightness %v, colour %s, want the night profile's", i, s.Brightness(), s.Pixels[0].Colour)
+		}
+	}
+}
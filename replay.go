@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/shift/systemd-status-leds/monitor"
+	"go.uber.org/zap"
+)
+
+// replayEvent is one line of a replay file: a unit's state At a time since
+// the start of the replay.
+type replayEvent struct {
+	At              string `json:"at"`
+	Unit            string `json:"unit"`
+	State           string `json:"state"`
+	SubState        string `json:"sub_state,omitempty"`
+	UnitFileState   string `json:"unit_file_state,omitempty"`
+	ConditionFailed bool   `json:"condition_failed,omitempty"`
+	Initial         bool   `json:"initial,omitempty"`
+	StatusText      string `json:"status_text,omitempty"`
+	Result          string `json:"result,omitempty"`
+	WatchdogLate    bool   `json:"watchdog_late,omitempty"`
+	Template        string `json:"template,omitempty"`
+	Gone            bool   `json:"gone,omitempty"`
+}
+
+func replayed(e monitor.Event, at time.Duration) replayEvent {
+	return replayEvent{
+		At:              at.Round(time.Millisecond).String(),
+		Unit:            e.Unit,
+		State:           e.State,
+		SubState:        e.SubState,
+		UnitFileState:   e.UnitFileState,
+		ConditionFailed: e.ConditionFailed,
+		Initial:         e.Initial,
+		StatusText:      e.StatusText,
+		Result:          e.Result,
+		WatchdogLate:    e.WatchdogLate,
+		Template:        e.Template,
+		Gone:            e.Gone,
+	}
+}
+
+func (r replayEvent) event() monitor.Event {
+	return monitor.Event{
+		Unit:            r.Unit,
+		State:           r.State,
+		SubState:        r.SubState,
+		UnitFileState:   r.UnitFileState,
+		ConditionFailed: r.ConditionFailed,
+		Initial:         r.Initial,
+		StatusText:      r.StatusText,
+		Result:          r.Result,
+		WatchdogLate:    r.WatchdogLate,
+		Template:        r.Template,
+		Gone:            r.Gone,
+	}
+}
+
+// parseReplayEvent parses one line of a replay file.
+func parseReplayEvent(line []byte) (replayEvent, error) {
+	var event replayEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return event, err
+	}
+	if _, err := time.ParseDuration(event.At); err != nil {
+		return event, err
+	}
+	if !event.Gone && !knownState(event.State) {
+		return event, fmt.Errorf("unknown state %q", event.State)
+	}
+	return event, nil
+}
+
+// readReplay parses a replay file, one JSON event per line.
+func readReplay(r io.Reader) ([]replayEvent, error) {
+	var events []replayEvent
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		event, err := parseReplayEvent(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// replay renders the events as they fall due, speed times faster than they
+// were recorded.
+func replay(events []replayEvent, speed float64, r monitor.Renderer) {
+	start := time.Now()
+	for _, event := range events {
+		at, _ := time.ParseDuration(event.At)
+		time.Sleep(time.Until(start.Add(time.Duration(float64(at) / speed))))
+		r.Render(event.event())
+	}
+}
+
+// recorder writes every event to a replay file before rendering it.
+type recorder struct {
+	mu    sync.Mutex
+	out   *json.Encoder
+	start time.Time
+	next  monitor.Renderer
+}
+
+func newRecorder(w io.Writer, next monitor.Renderer) *recorder {
+	return &recorder{out: json.NewEncoder(w), start: time.Now(), next: next}
+}
+
+func (r *recorder) Render(e monitor.Event) {
+	r.mu.Lock()
+	err := r.out.Encode(replayed(e, time.Since(r.start)))
+	r.mu.Unlock()
+	if err != nil {
+		logr.Error("Unable to record event", zap.String("unit", e.Unit), zap.Error(err))
+	}
+	r.next.Render(e)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/shift/systemd-status-leds/monitor"
+)
+
+type collect []monitor.Event
+
+func (c *collect) Render(e monitor.Event) { *c = append(*c, e) }
+
+func TestRecordReplay(t *testing.T) {
+	want := []monitor.Event{
+		{Unit: "a.service", State: "active", SubState: "running", Initial: true},
+		{Unit: "a.service", State: "failed", SubState: "failed"},
+		{Unit: "b.service", State: "activating", SubState: "start"},
+	}
+	var buf bytes.Buffer
+	var recorded collect
+	r := newRecorder(&buf, &recorded)
+	for _, e := range want {
+		r.Render(e)
+	}
+
+	events, err := readReplay(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replayed collect
+	replay(events, 1000, &replayed)
+	for i, e := range want {
+		if recorded[i] != e {
+			t.Errorf("recorded %d = %+v, want %+v", i, recorded[i], e)
+		}
+		if replayed[i] != e {
+			t.Errorf("replayed %d = %+v, want %+v", i, replayed[i], e)
+		}
+	}
+}
+
+func TestReadReplayRejectsUnknownState(t *testing.T) {
+	if _, err := readReplay(bytes.NewBufferString(`{"at":"0s","unit":"a.service","state":"bogus"}`)); err == nil {
+		t.Error("unknown state accepted")
+	}
+}
+
+func TestDisplayState(t *testing.T) {
+	C = Config{Services: []Service{{Unit: "smartd.service", Optional: true}, {Unit: "nginx.service"}}}
+	defer func() { C = Config{} }()
+	for _, tc := range []struct {
+		state, fileState string
+		conditionFailed  bool
+		want             string
+	}{
+		{"inactive", "masked", true, "masked"},
+		{"inactive", "disabled", false, "disabled"},
+		{"inactive", "disabled", true, "skipped"},
+		{"inactive", "enabled", false, "inactive"},
+		{"active", "", false, "active"},
+	} {
+		if got := displayState(monitor.Event{State: tc.state, UnitFileState: tc.fileState, ConditionFailed: tc.conditionFailed}); got != tc.want {
+			t.Errorf("displayState(%s, %s) = %s, want %s", tc.state, tc.fileState, got, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		e    monitor.Event
+		want string
+	}{
+		{monitor.Event{State: "activating", Result: "watchdog"}, "watchdog"},
+		{monitor.Event{State: "failed", Result: "watchdog"}, "failed"},
+		{monitor.Event{State: "active", WatchdogLate: true}, "watchdog"},
+		{monitor.Event{State: "activating", Result: "exit-code"}, "activating"},
+		{monitor.Event{Unit: "cups.path", State: "active", SubState: "waiting"}, "armed"},
+		{monitor.Event{Unit: "proc-sys-fs-binfmt_misc.automount", State: "active", SubState: "running"}, "active"},
+		{monitor.Event{Unit: "backup.timer", State: "active", SubState: "waiting"}, "active"},
+		{monitor.Event{Unit: "smartd.service", State: "inactive", NotFound: true}, "absent"},
+		{monitor.Event{Unit: "nginx.service", State: "inactive", NotFound: true}, "missing"},
+	} {
+		if got := displayState(tc.e); got != tc.want {
+			t.Errorf("displayState(%+v) = %s, want %s", tc.e, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// Package rule parses and evaluates the boolean expressions used to light a
+// pixel from the states of several units, such as
+//
+//	unit("db.service").failed and not unit("app.service").active
+//
+// A unit term is true when the unit is in the named state. Terms combine
+// with and, or, not and parentheses; and binds tighter than or.
+package rule
@@ -0,0 +1,234 @@
+package rule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a parsed expression.
+type Expr interface {
+	// Eval evaluates the expression, looking up the state of each unit it
+	// names with state.
+	Eval(state func(unit string) string) bool
+	// Units lists the units the expression depends on.
+	Units() []string
+	// States lists the states the expression tests units for.
+	States() []string
+}
+
+type (
+	is struct {
+		unit, state string
+	}
+	not struct {
+		x Expr
+	}
+	and struct {
+		x, y Expr
+	}
+	or struct {
+		x, y Expr
+	}
+	constant bool
+)
+
+func (e is) Eval(state func(string) string) bool  { return state(e.unit) == e.state }
+func (e not) Eval(state func(string) string) bool { return !e.x.Eval(state) }
+func (e and) Eval(state func(string) string) bool { return e.x.Eval(state) && e.y.Eval(state) }
+func (e or) Eval(state func(string) string) bool  { return e.x.Eval(state) || e.y.Eval(state) }
+func (e constant) Eval(func(string) string) bool  { return bool(e) }
+
+func (e is) Units() []string       { return []string{e.unit} }
+func (e not) Units() []string      { return e.x.Units() }
+func (e and) Units() []string      { return append(e.x.Units(), e.y.Units()...) }
+func (e or) Units() []string       { return append(e.x.Units(), e.y.Units()...) }
+func (e constant) Units() []string { return nil }
+
+func (e is) States() []string       { return []string{e.state} }
+func (e not) States() []string      { return e.x.States() }
+func (e and) States() []string      { return append(e.x.States(), e.y.States()...) }
+func (e or) States() []string       { return append(e.x.States(), e.y.States()...) }
+func (e constant) States() []string { return nil }
+
+// Parse parses src into an expression.
+func Parse(src string) (Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "" {
+		return nil, fmt.Errorf("unexpected %s", p.peek())
+	}
+	return e, nil
+}
+
+// lex splits src into words, quoted strings and punctuation.
+func lex(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("().", c):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			s, err := strconv.QuotedPrefix(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, s)
+			i += len(s)
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '-' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos == len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() string {
+	t := p.peek()
+	if t != "" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(want string) error {
+	if got := p.next(); got != want {
+		if got == "" {
+			got = "end of expression"
+		}
+		return fmt.Errorf("expected %s, got %s", want, got)
+	}
+	return nil
+}
+
+func (p *parser) or() (Expr, error) {
+	x, err := p.and()
+	for err == nil && p.peek() == "or" {
+		p.next()
+		var y Expr
+		if y, err = p.and(); err == nil {
+			x = or{x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) and() (Expr, error) {
+	x, err := p.not()
+	for err == nil && p.peek() == "and" {
+		p.next()
+		var y Expr
+		if y, err = p.not(); err == nil {
+			x = and{x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) not() (Expr, error) {
+	if p.peek() != "not" {
+		return p.term()
+	}
+	p.next()
+	x, err := p.not()
+	return not{x}, err
+}
+
+// term is a parenthesised expression, true, false or unit("name").state.
+func (p *parser) term() (Expr, error) {
+	switch t := p.next(); t {
+	case "(":
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case "true", "false":
+		return constant(t == "true"), nil
+	case "unit":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		unit, err := strconv.Unquote(p.next())
+		if err != nil {
+			return nil, fmt.Errorf("unit needs a quoted name")
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if err := p.expect("."); err != nil {
+			return nil, err
+		}
+		state := p.next()
+		if state == "" || !unicode.IsLetter(rune(state[0])) {
+			return nil, fmt.Errorf("unit(%q) needs a state", unit)
+		}
+		return is{unit, state}, nil
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	default:
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+}
+
+// Is is true while unit is in state.
+func Is(unit, state string) Expr { return is{unit, state} }
+
+// Not negates x.
+func Not(x Expr) Expr { return not{x} }
+
+// All is true when every x is; with none it is true.
+func All(xs ...Expr) Expr {
+	var e Expr = constant(true)
+	for i, x := range xs {
+		if i == 0 {
+			e = x
+		} else {
+			e = and{e, x}
+		}
+	}
+	return e
+}
+
+// Any is true when at least one x is; with none it is false.
+func Any(xs ...Expr) Expr {
+	var e Expr = constant(false)
+	for i, x := range xs {
+		if i == 0 {
+			e = x
+		} else {
+			e = or{e, x}
+		}
+	}
+	return e
+}
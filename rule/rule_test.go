@@ -0,0 +1,82 @@
+package rule
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	states := map[string]string{"db": "failed", "app": "active"}
+	state := func(unit string) string { return states[unit] }
+	for src, want := range map[string]bool{
+		`unit("db").failed`:                                  true,
+		`unit("db").active`:                                  false,
+		`unit("db").failed and unit("app").active`:           true,
+		`unit("db").failed and not unit("app").active`:       false,
+		`unit("db").active or unit("app").active`:            true,
+		`not unit("db").active and unit("app").active`:       true,
+		`unit("db").active and unit("app").active or true`:   true,
+		`unit("db").active and (unit("app").active or true)`: false,
+		`unit("missing").inactive`:                           false,
+		`false or not false`:                                 true,
+	} {
+		e, err := Parse(src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", src, err)
+			continue
+		}
+		if got := e.Eval(state); got != want {
+			t.Errorf("%s = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestUnits(t *testing.T) {
+	e, err := Parse(`unit("a").active and not (unit("b").failed or unit("c").active)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := e.Units(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Units() = %v, want %v", got, want)
+	}
+	if got, want := e.States(), []string{"active", "failed", "active"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("States() = %v, want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`unit(db).failed`,
+		`unit("db")`,
+		`unit("db").failed and`,
+		`(unit("db").failed`,
+		`unit("db").failed unit("app").active`,
+		`unit("db").failed && true`,
+		`unit("db`,
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) succeeded", src)
+		}
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	states := map[string]string{"a": "active", "b": "active", "c": "failed"}
+	state := func(unit string) string { return states[unit] }
+	for name, c := range map[string]struct {
+		e    Expr
+		want bool
+	}{
+		"all":       {All(Is("a", "active"), Is("b", "active")), true},
+		"all fails": {All(Is("a", "active"), Is("c", "active")), false},
+		"any":       {Any(Is("c", "active"), Is("b", "active")), true},
+		"not":       {Not(Is("c", "failed")), false},
+		"empty all": {All(), true},
+		"empty any": {Any(), false},
+	} {
+		if got := c.e.Eval(state); got != c.want {
+			t.Errorf("%s = %v, want %v", name, got, c.want)
+		}
+	}
+}
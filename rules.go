@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/rule"
+)
+
+// Rule lights Pixel in its style while When, an expression over unit states,
+// holds. The first rule that holds for a pixel wins; with none it is dark.
+type Rule struct {
+	When       string
+	Pixel      int
+	StateStyle `mapstructure:",squash"`
+}
+
+func (r Rule) Validate() error {
+	where := fmt.Sprintf("rules: pixel %d", r.Pixel)
+	e, err := rule.Parse(r.When)
+	if err != nil {
+		return fmt.Errorf("%s: %v", where, err)
+	}
+	for _, state := range e.States() {
+		if !knownState(state) {
+			return fmt.Errorf("%s: unknown state %q", where, state)
+		}
+	}
+	if err := checkColour(where, r.Colour); err != nil {
+		return err
+	}
+	if r.Period < 0 || r.Duty < 0 || r.Duty > 1 {
+		return fmt.Errorf("%s: needs a positive period and a duty between 0 and 1", where)
+	}
+	return nil
+}
+
+type compiledRule struct {
+	when  rule.Expr
+	pixel *led.Led
+	style StateStyle
+	// composite rules show the pixel active or failed instead of in style.
+	composite bool
+}
+
+var (
+	rulesMu sync.Mutex
+	rules   []compiledRule
+)
+
+// ruleUnits lists the units rules depend on.
+func ruleUnits(rs []Rule) []string {
+	var units []string
+	for _, r := range rs {
+		e, _ := rule.Parse(r.When)
+		units = append(units, e.Units()...)
+	}
+	return units
+}
+
+// addRules compiles the rules onto their reserved pixels, keyed by number,
+// and tracks any unit they use that no pixel shows.
+func addRules(rs []Rule, pixels map[int]*led.Led) {
+	for _, r := range rs {
+		e, _ := rule.Parse(r.When)
+		rules = append(rules, compiledRule{when: e, pixel: pixels[r.Pixel], style: r.StateStyle})
+	}
+	trackUnits(ruleUnits(rs))
+	applyRules()
+}
+
+// trackUnits follows any of units that no pixel shows.
+func trackUnits(units []string) {
+	for _, unit := range units {
+		if unitState(unit) == nil {
+			member := &led.Led{}
+			member.Unit = unit
+			tracked = append(tracked, member)
+		}
+	}
+}
+
+// unitState returns a tracked pixel following unit.
+func unitState(unit string) *led.Led {
+	for _, pixel := range tracked {
+		if pixel.Unit == unit {
+			return pixel
+		}
+	}
+	return nil
+}
+
+// applyRules re-evaluates every rule against the current unit states.
+func applyRules() {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	if len(rules) == 0 {
+		return
+	}
+	state := func(unit string) string {
+		if pixel := unitState(unit); pixel != nil {
+			return pixel.Status
+		}
+		return ""
+	}
+	lit := map[*led.Led]bool{}
+	for _, r := range rules {
+		if r.composite {
+			shown := "failed"
+			if r.when.Eval(state) {
+				shown = "active"
+			}
+			r.pixel.SetStatus(shown)
+			setColour(r.pixel, colourFor(r.pixel.Unit, shown))
+			r.pixel.SetPattern(patternFor(r.pixel.Unit, shown))
+			lit[r.pixel] = true
+			continue
+		}
+		if lit[r.pixel] || !r.when.Eval(state) {
+			continue
+		}
+		lit[r.pixel] = true
+		setColour(r.pixel, r.style.Colour)
+		r.pixel.SetPattern(r.style.pattern())
+	}
+	for _, r := range rules {
+		if !lit[r.pixel] {
+			setColour(r.pixel, "00000000")
+			r.pixel.SetPattern(led.Pattern{})
+		}
+	}
+}
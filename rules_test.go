@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+func TestApplyRules(t *testing.T) {
+	db, pixel := &led.Led{Unit: "db.service"}, &led.Led{Number: 3}
+	tracked = []*led.Led{db}
+	defer func() { tracked, rules = nil, nil }()
+	addRules([]Rule{
+		{When: `unit("db.service").failed and unit("app.service").active`, Pixel: 3, StateStyle: StateStyle{Colour: "ff000000"}},
+		{When: `unit("db.service").failed`, Pixel: 3, StateStyle: StateStyle{Colour: "00ff0000"}},
+	}, map[int]*led.Led{3: pixel})
+
+	if len(tracked) != 2 || tracked[1].Unit != "app.service" {
+		t.Fatalf("app.service not tracked: %v", tracked)
+	}
+	for _, step := range []struct{ db, app, want string }{
+		{"active", "active", "00000000"},
+		{"failed", "inactive", "00ff0000"},
+		{"failed", "active", "ff000000"},
+	} {
+		db.SetStatus(step.db)
+		tracked[1].SetStatus(step.app)
+		applyRules()
+		if pixel.Colour != step.want {
+			t.Errorf("db %s, app %s: colour %s, want %s", step.db, step.app, pixel.Colour, step.want)
+		}
+	}
+}
+
+func TestComposite(t *testing.T) {
+	nginx, pixel := &led.Led{Unit: "nginx.service"}, &led.Led{Unit: "stack", Number: 5}
+	tracked = []*led.Led{nginx}
+	defer func() { tracked, rules = nil, nil }()
+	addComposites([]Composite{{Name: "stack", Pixel: 5, When: Condition{All: []Condition{
+		{Unit: "nginx.service"},
+		{Not: &Condition{Unit: "postgresql.service", State: "failed"}},
+	}}}}, map[string]*led.Led{"stack": pixel})
+
+	postgres := unitState("postgresql.service")
+	for _, step := range []struct{ nginx, postgres, want string }{
+		{"active", "active", "active"},
+		{"active", "failed", "failed"},
+		{"inactive", "active", "failed"},
+	} {
+		nginx.SetStatus(step.nginx)
+		postgres.SetStatus(step.postgres)
+		applyRules()
+		if pixel.Status != step.want {
+			t.Errorf("nginx %s, postgresql %s: %s, want %s", step.nginx, step.postgres, pixel.Status, step.want)
+		}
+	}
+}
+
+func TestConditionValidate(t *testing.T) {
+	for _, c := range []Condition{
+		{},
+		{Unit: "a.service", All: []Condition{{Unit: "b.service"}}},
+		{Unit: "a.service", State: "broken"},
+		{Any: []Condition{{}}},
+	} {
+		if _, err := c.expr(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	for _, r := range []Rule{
+		{When: `unit("db.service").faild`, Pixel: 1, StateStyle: StateStyle{Colour: "ff000000"}},
+		{When: `unit("db.service").failed and`, Pixel: 1, StateStyle: StateStyle{Colour: "ff000000"}},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Errorf("%q: expected an error", r.When)
+		}
+	}
+	r := Rule{When: `unit("db.service").failed and not unit("app.service").active`, Pixel: 1, StateStyle: StateStyle{Colour: "ff000000"}}
+	if err := r.Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+// SelfTestConfig runs a daily self-test sweep of the strip At HH:MM, each
+// position lit for Step. An empty At disables it.
+type SelfTestConfig struct {
+	At   string
+	Step time.Duration
+}
+
+func (c SelfTestConfig) Validate() error {
+	if c.At == "" {
+		return nil
+	}
+	if _, err := time.Parse("15:04", c.At); err != nil {
+		return fmt.Errorf("self_test.at: %q is not HH:MM", c.At)
+	}
+	if c.Step <= 0 {
+		return fmt.Errorf("self_test.step must be positive")
+	}
+	return nil
+}
+
+func selfTestLoop(s *strip.Strip, c SelfTestConfig) {
+	for now := range time.Tick(time.Minute) {
+		if now.Format("15:04") != c.At {
+			continue
+		}
+		logr.Info("Self-test starting")
+		failed, err := s.SelfTest(c.Step)
+		if err != nil {
+			logr.Error("Self-test failed", zap.Int("failed_writes", failed), zap.Error(err))
+			continue
+		}
+		logr.Info("Self-test passed")
+	}
+}
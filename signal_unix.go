@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// cycleSignals cycle through the profiles.
+var cycleSignals = []os.Signal{syscall.SIGUSR1}
+
+// stopSignals stop the daemon.
+var stopSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
@@ -0,0 +1,9 @@
+package main
+
+import "os"
+
+// cycleSignals is empty, Windows has no SIGUSR1.
+var cycleSignals []os.Signal
+
+// stopSignals stop the daemon.
+var stopSignals = []os.Signal{os.Interrupt}
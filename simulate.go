@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/shift/systemd-status-leds/strip"
+)
+
+// connected stands in for systemd while simulating.
+type connected struct{}
+
+func (connected) Connected() bool { return true }
+
+// simulate runs the configuration without hardware or systemd, drawing the
+// strip on the terminal and taking unit states from a replay file. It returns
+// the process exit status.
+func simulate(args []string) int {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	path := flags.String("config", "", "configuration file")
+	replayFile := flags.String("replay", "", "replay file of unit states")
+	speed := flags.Float64("speed", 1, "replay this many times faster than recorded")
+	_ = flags.Parse(args)
+
+	if *speed <= 0 {
+		fmt.Fprintln(os.Stderr, "--speed must be positive")
+		return 2
+	}
+	Configuration(*path)
+	configureLimits(C.Log)
+	var events []replayEvent
+	if *replayFile != "" {
+		f, err := os.Open(*replayFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		events, err = readReplay(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *replayFile, err)
+			return 1
+		}
+	}
+
+	display := &strip.Terminal{Out: os.Stdout, Channels: C.Strip.Channels}
+	s, err := strip.New(logr, display, &C.Strip.Length, &C.Strip.Channels, C.Strip.Opts())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// Only the main strip is drawn, services on the others are laid out on
+	// strips that show nothing.
+	extras := map[string]*strip.Strip{}
+	for i := range C.Strips {
+		c := &C.Strips[i]
+		extra, err := strip.New(logr, &strip.Terminal{Out: io.Discard, Channels: c.Channels}, &c.Length, &c.Channels, c.Opts())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		extras[c.Name] = extra
+	}
+	if pixel := layout(s, extras); pixel != nil {
+		go heartbeatLoop(connected{}, nil, allStrips(s, extras), pixel, C.Heartbeat)
+	}
+	if C.Profile != "" {
+		_ = switchProfile(allStrips(s, extras), C.Profile)
+	}
+	go runStrips(s, extras)
+
+	replay(events, *speed, renderer{})
+	time.Sleep(s.Interval)
+	_ = display.Halt()
+	return 0
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shift/systemd-status-leds/ease"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+// SleepConfig fades the strip off once nothing has changed for Idle and no
+// unit is failed. A zero Idle disables sleeping.
+type SleepConfig struct {
+	Idle time.Duration
+	Fade time.Duration
+	// Easing shapes the fade.
+	Easing string
+}
+
+var (
+	activityMu sync.Mutex
+	lastChange = time.Now()
+	activity   = make(chan struct{}, 1)
+)
+
+// touch records a state transition or button press and wakes the strip.
+func touch() {
+	activityMu.Lock()
+	lastChange = time.Now()
+	activityMu.Unlock()
+	select {
+	case activity <- struct{}{}:
+	default:
+	}
+}
+
+func idleFor() time.Duration {
+	activityMu.Lock()
+	defer activityMu.Unlock()
+	return time.Since(lastChange)
+}
+
+func anyFailed(strips []*strip.Strip) bool {
+	for _, pixel := range pixelsOf(strips) {
+		if pixel.Status == "failed" {
+			return true
+		}
+	}
+	return false
+}
+
+// setFade fades every strip to f.
+func setFade(strips []*strip.Strip, f float64) {
+	for _, s := range strips {
+		s.SetFade(f)
+	}
+}
+
+const fadeSteps = 50
+
+func sleepLoop(strips []*strip.Strip, c SleepConfig) {
+	asleep := false
+	ticker := time.NewTicker(time.Second)
+	for {
+		select {
+		case <-activity:
+			if asleep {
+				logr.Info("Waking strip")
+				setFade(strips, 1)
+				asleep = false
+			}
+		case <-ticker.C:
+			if asleep || idleFor() < c.Idle || anyFailed(strips) {
+				continue
+			}
+			logr.Info("Strip idle, going to sleep", zap.Duration("idle", c.Idle))
+			asleep = fadeOut(strips, c.Fade, c.Easing)
+		}
+	}
+}
+
+// fadeOut dims the strips to off over d, shaped by easing. It gives up and
+// restores them if there is activity while fading.
+func fadeOut(strips []*strip.Strip, d time.Duration, easing string) bool {
+	shape, err := ease.ByName(easing)
+	if err != nil {
+		shape = ease.Linear
+	}
+	for i := 1; i <= fadeSteps; i++ {
+		select {
+		case <-activity:
+			setFade(strips, 1)
+			return false
+		case <-time.After(d / fadeSteps):
+		}
+		setFade(strips, 1-shape(float64(i)/fadeSteps))
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// sliceFloor is the fraction of full brightness an empty slice is shown at.
+const sliceFloor = 0.1
+
+var (
+	sliceMu     sync.Mutex
+	sliceActive = map[string]int{}
+)
+
+func (s Service) validateSlice() error {
+	if s.FullAt < 0 || (s.FullAt > 0 && !strings.HasSuffix(s.Unit, ".slice")) {
+		return fmt.Errorf("%s: full_at needs a slice and a positive count", s.Unit)
+	}
+	return nil
+}
+
+// sliceUnits are the slices whose active units are counted.
+func sliceUnits() []string {
+	var units []string
+	for _, service := range C.Services {
+		if service.FullAt > 0 {
+			units = append(units, service.Unit)
+		}
+	}
+	return units
+}
+
+func setSliceActive(unit string, active int) {
+	sliceMu.Lock()
+	defer sliceMu.Unlock()
+	sliceActive[unit] = active
+}
+
+// sliceColour scales the colour of an active slice by how many of its units
+// are active, from sliceFloor when none are to full brightness at its
+// service's FullAt.
+func sliceColour(unit string, state string, colour string) string {
+	if state != "active" {
+		return colour
+	}
+	for _, service := range C.Services {
+		if service.Unit != unit || service.FullAt <= 0 {
+			continue
+		}
+		sliceMu.Lock()
+		active := sliceActive[unit]
+		sliceMu.Unlock()
+		f := float64(min(active, service.FullAt)) / float64(service.FullAt)
+		return scaleColour(colour, sliceFloor+(1-sliceFloor)*f)
+	}
+	return colour
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestSliceColour(t *testing.T) {
+	C = Config{Services: []Service{{Unit: "machine.slice", FullAt: 4}}}
+	defer func() { C, sliceActive = Config{}, map[string]int{} }()
+	for _, tc := range []struct {
+		unit   string
+		state  string
+		active int
+		want   string
+	}{
+		{"machine.slice", "active", 0, "19000000"},
+		{"machine.slice", "active", 2, "8c000000"},
+		{"machine.slice", "active", 9, "ff000000"},
+		{"machine.slice", "failed", 0, "ff000000"},
+		{"user.slice", "active", 0, "ff000000"},
+	} {
+		setSliceActive(tc.unit, tc.active)
+		if got := sliceColour(tc.unit, tc.state, "ff000000"); got != tc.want {
+			t.Errorf("%s %s with %d active = %s, want %s", tc.unit, tc.state, tc.active, got, tc.want)
+		}
+	}
+}
+
+func TestValidateSlice(t *testing.T) {
+	for _, tc := range []struct {
+		service Service
+		ok      bool
+	}{
+		{Service{Unit: "machine.slice", FullAt: 8}, true},
+		{Service{Unit: "machine.slice"}, true},
+		{Service{Unit: "nginx.service", FullAt: 8}, false},
+		{Service{Unit: "machine.slice", FullAt: -1}, false},
+	} {
+		if err := tc.service.validateSlice(); (err == nil) != tc.ok {
+			t.Errorf("%+v: %v", tc.service, err)
+		}
+	}
+}
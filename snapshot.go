@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shift/systemd-status-leds/strip"
+)
+
+// Sizes of a snapshot image in pixels: each LED is a square cell with a gap
+// around it.
+const (
+	snapshotCell = 16
+	snapshotGap  = 4
+)
+
+var snapshotBackground = color.RGBA{0x20, 0x20, 0x20, 0xff}
+
+// snapshotColours are the colours of frame's pixels, white added to red,
+// green and blue.
+func snapshotColours(frame []byte, channels int) []color.RGBA {
+	var colours []color.RGBA
+	for i := 0; i+channels <= len(frame); i += channels {
+		px := frame[i : i+channels]
+		if channels == 1 {
+			colours = append(colours, color.RGBA{px[0], px[0], px[0], 0xff})
+			continue
+		}
+		var white int
+		if channels == 4 {
+			white = int(px[3])
+		}
+		c := func(v byte) uint8 { return uint8(min(int(v)+white, 255)) }
+		colours = append(colours, color.RGBA{c(px[0]), c(px[1]), c(px[2]), 0xff})
+	}
+	return colours
+}
+
+// writePNG draws frame as a row of cells in PNG.
+func writePNG(w io.Writer, frame []byte, channels int) error {
+	colours := snapshotColours(frame, channels)
+	step := snapshotCell + snapshotGap
+	img := image.NewRGBA(image.Rect(0, 0, len(colours)*step+snapshotGap, step+snapshotGap))
+	draw.Draw(img, img.Bounds(), image.NewUniform(snapshotBackground), image.Point{}, draw.Src)
+	for i, c := range colours {
+		cell := image.Rect(0, 0, snapshotCell, snapshotCell).Add(image.Pt(snapshotGap+i*step, snapshotGap))
+		draw.Draw(img, cell, image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	return png.Encode(w, img)
+}
+
+// writeSVG draws frame as a row of cells in SVG.
+func writeSVG(w io.Writer, frame []byte, channels int) error {
+	colours := snapshotColours(frame, channels)
+	step := snapshotCell + snapshotGap
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`+"\n", len(colours)*step+snapshotGap, step+snapshotGap)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#%02x%02x%02x"/>`+"\n", snapshotBackground.R, snapshotBackground.G, snapshotBackground.B)
+	for i, c := range colours {
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="#%02x%02x%02x"/>`+"\n",
+			snapshotGap+i*step, snapshotGap, snapshotCell, snapshotCell, c.R, c.G, c.B)
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeSnapshot answers the snapshot query with the channels and the frame
+// in hex.
+func writeSnapshot(w io.Writer, s *strip.Strip) {
+	frame, channels := s.Snapshot()
+	fmt.Fprintf(w, "%d %x\n", channels, frame)
+}
+
+// parseSnapshot reads the answer to the snapshot query.
+func parseSnapshot(answer string) (frame []byte, channels int, err error) {
+	n, data, ok := strings.Cut(strings.TrimSpace(answer), " ")
+	if channels, err = strconv.Atoi(n); err != nil || channels < 1 {
+		return nil, 0, fmt.Errorf("bad snapshot %q", answer)
+	}
+	if !ok || data == "" {
+		return nil, 0, errors.New("no frame rendered yet")
+	}
+	if frame, err = hex.DecodeString(data); err != nil {
+		return nil, 0, fmt.Errorf("bad snapshot: %v", err)
+	}
+	return frame, channels, nil
+}
+
+// snapshotHandler serves the current frame as PNG or, for .svg, SVG.
+func snapshotHandler(s *strip.Strip) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		frame, channels := s.Snapshot()
+		if frame == nil {
+			http.Error(w, "no frame rendered yet", http.StatusServiceUnavailable)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".svg") {
+			w.Header().Set("Content-Type", "image/svg+xml")
+			_ = writeSVG(w, frame, channels)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_ = writePNG(w, frame, channels)
+	}
+}
+
+// snapshot asks the running daemon for its current frame and writes it as
+// an image to the -out file, SVG if it ends in .svg and PNG otherwise. It
+// returns the process exit status.
+func snapshot(args []string) int {
+	flags := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	path := flags.String("config", "", "configuration file")
+	out := flags.String("out", "status.png", "image file to write, .png or .svg")
+	_ = flags.Parse(args)
+	Configuration(*path)
+
+	var answer bytes.Buffer
+	if err := request(C.Control.Socket, "snapshot", &answer); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	frame, channels, err := parseSnapshot(answer.String())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	write := writePNG
+	if strings.HasSuffix(*out, ".svg") {
+		write = writeSVG
+	}
+	if err := write(f, frame, channels); err != nil {
+		f.Close()
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("wrote", *out)
+	return 0
+}
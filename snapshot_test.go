@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shift/systemd-status-leds/strip"
+)
+
+func TestWritePNG(t *testing.T) {
+	var b bytes.Buffer
+	if err := writePNG(&b, []byte{0xff, 0, 0, 0, 0, 0, 0x10, 0x40}, 4); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Dx(); got != 2*(snapshotCell+snapshotGap)+snapshotGap {
+		t.Errorf("width %d, want two cells", got)
+	}
+	centre := func(i int) color.Color {
+		return img.At(snapshotGap+i*(snapshotCell+snapshotGap)+snapshotCell/2, snapshotGap+snapshotCell/2)
+	}
+	if got := color.RGBAModel.Convert(centre(0)); got != (color.RGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("first cell %v, want red", got)
+	}
+	if got := color.RGBAModel.Convert(centre(1)); got != (color.RGBA{0x40, 0x40, 0x50, 0xff}) {
+		t.Errorf("second cell %v, want white added to blue", got)
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	var b strings.Builder
+	if err := writeSVG(&b, []byte{0, 0xff, 0}, 3); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "<svg") || !strings.Contains(b.String(), `fill="#00ff00"`) {
+		t.Errorf("svg = %s", b.String())
+	}
+}
+
+func TestParseSnapshot(t *testing.T) {
+	frame, channels, err := parseSnapshot("3 ff0010\n")
+	if err != nil || channels != 3 || !bytes.Equal(frame, []byte{0xff, 0, 0x10}) {
+		t.Errorf("parseSnapshot() = %x, %d, %v", frame, channels, err)
+	}
+	if _, _, err := parseSnapshot("3 \n"); err == nil {
+		t.Error("parseSnapshot() accepted a daemon with no frame")
+	}
+}
+
+func TestSnapshotHandler(t *testing.T) {
+	length, channels := 2, 3
+	s, err := strip.New(logr, &strip.Terminal{Out: io.Discard, Channels: channels}, &length, &channels, strip.Opts{Power: strip.Power{MilliampsPerChannel: strip.DefaultMilliampsPerChannel}, Interval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := httpMux(HTTPConfig{}, s)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/snapshot.png", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first frame: %d", rec.Code)
+	}
+	go strip.Run(s)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if frame, _ := s.Snapshot(); frame != nil {
+			break
+		}
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/snapshot.svg", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" || strings.Count(rec.Body.String(), "<rect") != 3 {
+		t.Errorf("svg snapshot: %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+// savedUnit is the last known state of a unit.
+type savedUnit struct {
+	State        string `json:"state"`
+	Acknowledged bool   `json:"acknowledged,omitempty"`
+}
+
+// savedState is what survives a restart of the daemon.
+type savedState struct {
+	Profile    string               `json:"profile,omitempty"`
+	Brightness float64              `json:"brightness"`
+	Units      map[string]savedUnit `json:"units"`
+}
+
+var watchers []chan struct{}
+
+// watchChanges returns a channel signalled whenever the display state
+// changes. Watchers must be registered before the units are started.
+func watchChanges() <-chan struct{} {
+	c := make(chan struct{}, 1)
+	watchers = append(watchers, c)
+	return c
+}
+
+// markDirty tells every watcher that the display state changed.
+func markDirty() {
+	for _, c := range watchers {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// currentState is the state of the strips, with the brightness of the first.
+func currentState(strips []*strip.Strip) savedState {
+	st := savedState{
+		Brightness: strips[0].Brightness(),
+		Units:      map[string]savedUnit{},
+	}
+	profileMu.RLock()
+	if profile != nil {
+		st.Profile = profile.Name
+	}
+	profileMu.RUnlock()
+	for _, pixel := range pixelsOf(strips) {
+		if pixel.Status != "" {
+			st.Units[pixel.Unit] = savedUnit{State: pixel.Status, Acknowledged: pixel.Acknowledged}
+		}
+	}
+	return st
+}
+
+// writeFileAtomic replaces path with data so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// persistLoop writes the state file at most once a second while it changes.
+func persistLoop(strips []*strip.Strip, path string, changes <-chan struct{}) {
+	for range changes {
+		data, err := json.Marshal(currentState(strips))
+		if err == nil {
+			err = writeFileAtomic(path, data)
+		}
+		if err != nil {
+			logr.ErrorL("state", "Failed to save state", zap.String("path", path), zap.Error(err))
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// restoreState shows the state saved by a previous run so a restart doesn't
+// flash the strip through loading colours or forget acknowledgements.
+func restoreState(strips []*strip.Strip, path string) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	var st savedState
+	if err == nil {
+		err = json.Unmarshal(data, &st)
+	}
+	if err != nil {
+		logr.Error("Failed to restore state", zap.String("path", path), zap.Error(err))
+		return
+	}
+	if st.Profile != "" {
+		if err := switchProfile(strips, st.Profile); err != nil {
+			logr.Info("Saved profile", zap.Error(err))
+		}
+	}
+	for _, s := range strips {
+		if st.Brightness > 0 {
+			s.SetBrightness(st.Brightness)
+		}
+		for _, pixel := range s.Pixels {
+			if unit, ok := st.Units[pixel.Unit]; ok && knownState(unit.State) {
+				pixel.SetAcknowledged(unit.Acknowledged)
+				setState(pixel, unit.State)
+			}
+		}
+	}
+	logr.Info("Restored state", zap.String("path", path), zap.Int("units", len(st.Units)))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// StatusMatch overrides the colour of a service whose sd_notify STATUS= text
+// matches Pattern, e.g. "degraded" showing orange while active.
+type StatusMatch struct {
+	Pattern string
+	Colour  string
+}
+
+var (
+	statusMu    sync.Mutex
+	statusTexts = map[string]string{}
+	statusRes   = map[string]*regexp.Regexp{}
+)
+
+func setStatusText(unit string, text string) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	statusTexts[unit] = text
+}
+
+// statusColour returns the colour of the first of the service's status_text
+// patterns matching its last status text.
+func statusColour(unit string) (string, bool) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	text, ok := statusTexts[unit]
+	if !ok {
+		return "", false
+	}
+	for _, service := range C.Services {
+		if !service.serves(unit) {
+			continue
+		}
+		for _, m := range service.StatusText {
+			re, ok := statusRes[m.Pattern]
+			if !ok {
+				re = regexp.MustCompile(m.Pattern)
+				statusRes[m.Pattern] = re
+			}
+			if re.MatchString(text) {
+				return m.Colour, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (s Service) validateStatusText() []error {
+	var errs []error
+	for _, m := range s.StatusText {
+		if _, err := regexp.Compile(m.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("%s.status_text: %v", s.Unit, err))
+		}
+		if err := checkColour(s.Unit+".status_text", m.Colour); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// statusTextUnits are the services with status_text patterns.
+func statusTextUnits() []string {
+	var units []string
+	for _, service := range C.Services {
+		if len(service.StatusText) > 0 {
+			units = append(units, service.Unit)
+		}
+	}
+	return units
+}
+
+// checkIntervals are the intervals the services with one are checked at, by
+// unit.
+func checkIntervals() map[string]time.Duration {
+	intervals := map[string]time.Duration{}
+	for _, service := range C.Services {
+		if service.Interval > 0 {
+			intervals[service.Unit] = service.Interval
+		}
+	}
+	return intervals
+}
+
+// watchdogUnits are the services whose watchdog is checked.
+func watchdogUnits() []string {
+	var units []string
+	for _, service := range C.Services {
+		if service.Watchdog {
+			units = append(units, service.Unit)
+		}
+	}
+	return units
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestStatusColour(t *testing.T) {
+	C = Config{Services: []Service{{
+		Unit:       "a.service",
+		StatusText: []StatusMatch{{Pattern: "degrad", Colour: "ff880000"}},
+	}}}
+	defer func() { C = Config{} }()
+	setStatusText("a.service", "running degraded")
+	if c := colourFor("a.service", "active"); c != "ff880000" {
+		t.Errorf("active colour = %q, want the status text colour", c)
+	}
+	if c := colourFor("a.service", "failed"); c == "ff880000" {
+		t.Error("status text overrode failed")
+	}
+	setStatusText("a.service", "ok")
+	if _, ok := statusColour("a.service"); ok {
+		t.Error("status text colour kept after the text changed")
+	}
+}
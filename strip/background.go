@@ -0,0 +1,65 @@
+package strip
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/shift/systemd-status-leds/ease"
+)
+
+// Background fills the pixels no unit or segment uses, so it is obvious which
+// are intentionally unused. The zero Background leaves them dark.
+type Background struct {
+	// Mode is solid, gradient (from Colour at the first pixel to To at the
+	// last) or breathe (Colour slowly pulsing over Period).
+	Mode   string
+	Colour string
+	To     string
+	Period time.Duration
+	// Easing shapes breathing, ease-in-out unless set.
+	Easing string
+}
+
+func (b Background) Validate() error {
+	switch b.Mode {
+	case "", "solid", "gradient":
+	case "breathe":
+		if b.Period <= 0 {
+			return fmt.Errorf("background: breathe needs a positive period")
+		}
+	default:
+		return fmt.Errorf("background: unknown mode %q", b.Mode)
+	}
+	if b.Easing != "" {
+		if _, err := ease.ByName(b.Easing); err != nil {
+			return fmt.Errorf("background: %v", err)
+		}
+	}
+	return nil
+}
+
+// colourAt is the background of pixel number of count at t, unpacking
+// colours through c.
+func (b Background) colourAt(c *backgroundCache, number, count int, t time.Time) [4]byte {
+	px := c.from.rgba(b.Colour)
+	switch b.Mode {
+	case "gradient":
+		to := c.to.rgba(b.To)
+		f := 0.0
+		if count > 1 {
+			f = float64(number-1) / float64(count-1)
+		}
+		for i := range px {
+			px[i] = byte(math.Round(float64(px[i]) + f*(float64(to[i])-float64(px[i]))))
+		}
+	case "breathe":
+		f := 0.1 + 0.9*ease.Pulse(c.ease(b.Easing), float64(t.UnixNano()%int64(b.Period))/float64(b.Period))
+		for i := range px {
+			px[i] = byte(float64(px[i]) * f)
+		}
+	case "":
+		return [4]byte{}
+	}
+	return px
+}
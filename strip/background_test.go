@@ -0,0 +1,33 @@
+package strip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackgroundColourAt(t *testing.T) {
+	now := time.Now()
+	if got := (Background{}).colourAt(&backgroundCache{}, 1, 5, now); got != [4]byte{} {
+		t.Errorf("zero background = %v, want dark", got)
+	}
+	solid := Background{Mode: "solid", Colour: "01020304"}
+	if got := solid.colourAt(&backgroundCache{}, 3, 5, now); got != [4]byte{1, 2, 3, 4} {
+		t.Errorf("solid = %v", got)
+	}
+	gradient := Background{Mode: "gradient", Colour: "00000000", To: "40000080"}
+	for number, want := range map[int][4]byte{1: {0, 0, 0, 0}, 3: {32, 0, 0, 64}, 5: {64, 0, 0, 128}} {
+		if got := gradient.colourAt(&backgroundCache{}, number, 5, now); got != want {
+			t.Errorf("gradient at %d = %v, want %v", number, got, want)
+		}
+	}
+	breathe := Background{Mode: "breathe", Colour: "64646464", Period: time.Second}
+	for i := 0; i < 10; i++ {
+		got := breathe.colourAt(&backgroundCache{}, 1, 5, now.Add(time.Duration(i)*100*time.Millisecond))
+		if got[0] < 10 || got[0] > 100 {
+			t.Errorf("breathe = %v, outside 10%%..100%%", got)
+		}
+	}
+	if err := (Background{Mode: "breathe"}).Validate(); err == nil {
+		t.Error("breathe without a period accepted")
+	}
+}
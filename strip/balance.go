@@ -0,0 +1,42 @@
+package strip
+
+import "fmt"
+
+// Balance scales each channel of every frame so white looks white on strips
+// whose colours differ in strength, such as cheap ones with weak blue, by
+// turning the stronger ones down. Each factor runs from 0 to 1; zero, or
+// unset, leaves a channel at full.
+type Balance struct {
+	Red   float64
+	Green float64
+	Blue  float64
+	White float64
+}
+
+// Validate checks every factor is between 0 and 1.
+func (b Balance) Validate() error {
+	for _, f := range []struct {
+		name  string
+		value float64
+	}{{"red", b.Red}, {"green", b.Green}, {"blue", b.Blue}, {"white", b.White}} {
+		if f.value < 0 || f.value > 1 {
+			return fmt.Errorf("balance.%s must be between 0 and 1, got %g", f.name, f.value)
+		}
+	}
+	return nil
+}
+
+// factors is the scale of each channel of a pixel with channels, in frame
+// order. Single channel pixels are not balanced.
+func (b Balance) factors(channels int) [4]float64 {
+	f := [4]float64{1, 1, 1, 1}
+	if channels == 1 {
+		return f
+	}
+	for i, v := range [4]float64{b.Red, b.Green, b.Blue, b.White} {
+		if v > 0 {
+			f[i] = v
+		}
+	}
+	return f
+}
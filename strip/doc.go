@@ -0,0 +1,8 @@
+// Package strip renders led.Leds onto a strip of addressable pixels.
+//
+// Init drives an NRZ strip (WS2812, SK6812) on an SPI port or a UART, New
+// any other Display, such as a Terminal. Pixels are assigned with Add, AddTo
+// and Reserve, grouped into Segments, and shown by UpdateLoop every
+// Interval, scaled by the brightness, thermal throttle, fade and power
+// budget.
+package strip
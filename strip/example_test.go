@@ -0,0 +1,23 @@
+package strip_test
+
+import (
+	"os"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+func ExampleNew() {
+	length, channels := 8, 4
+	logger := loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	display := &strip.Terminal{Out: os.Stderr, Channels: channels}
+	s, err := strip.New(logger, display, &length, &channels, strip.Opts{})
+	if err != nil {
+		panic(err)
+	}
+	pixel, _ := s.Add("backup.service")
+	pixel.SetColour("00ff0000")
+	go s.UpdateLoop()
+}
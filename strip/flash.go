@@ -0,0 +1,87 @@
+package strip
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+// FlashLimit caps how often any pixel may flash, keeping blinking, pulses
+// and every other effect out of the range that risks photosensitive
+// seizures. A flash is a swing in luminance of at least Delta and back.
+type FlashLimit struct {
+	// Hertz is the most flashes a second, zero disables the limit.
+	Hertz float64
+	// Delta is the change in luminance, from 0 to 1, counted as half a
+	// flash.
+	Delta float64
+}
+
+// DefaultFlashLimit follows the general flash threshold of WCAG 2: no more
+// than three flashes a second.
+var DefaultFlashLimit = FlashLimit{Hertz: 3, Delta: 0.1}
+
+// Validate checks the limit is off or no looser than DefaultFlashLimit.
+func (l FlashLimit) Validate() error {
+	if l.Hertz < 0 || l.Hertz > DefaultFlashLimit.Hertz {
+		return fmt.Errorf("flash_limit.hertz must be between 0 (off) and %g, got %g", DefaultFlashLimit.Hertz, l.Hertz)
+	}
+	if l.Hertz > 0 && (l.Delta <= 0 || l.Delta > 1) {
+		return fmt.Errorf("flash_limit.delta must be above 0 and at most 1, got %g", l.Delta)
+	}
+	return nil
+}
+
+// pattern slows p to flash no faster than the limit.
+func (l FlashLimit) pattern(p led.Pattern) led.Pattern {
+	if l.Hertz <= 0 || p.Period <= 0 {
+		return p
+	}
+	if slowest := time.Duration(float64(time.Second) / l.Hertz); p.Period < slowest {
+		p.Period = slowest
+	}
+	return p
+}
+
+// luminance is the relative luminance of a pixel, from 0 to 1, white adding
+// to all three colours.
+func luminance(px []byte) float64 {
+	var white float64
+	if len(px) == 4 {
+		white = float64(px[3])
+	}
+	if len(px) == 1 {
+		return float64(px[0]) / 255
+	}
+	return min((0.2126*float64(px[0])+0.7152*float64(px[1])+0.0722*float64(px[2])+white)/255, 1)
+}
+
+// limitFlashes holds back any pixel of buf whose luminance swings by Delta
+// or more sooner than half a flash after its last swing, so it changes once
+// the limit allows. buf is the frame due at now.
+func (s *Strip) limitFlashes(buf []byte, now time.Time) {
+	l := s.FlashLimit
+	if l.Hertz <= 0 {
+		return
+	}
+	channels := *s.Channels
+	if len(s.shown) != len(buf) {
+		s.shown = append(s.shown[:0], buf...)
+		s.swungAt = make([]time.Time, len(buf)/channels)
+		return
+	}
+	gap := time.Duration(float64(time.Second) / (2 * l.Hertz))
+	for i := range s.swungAt {
+		px, shown := buf[i*channels:(i+1)*channels], s.shown[i*channels:(i+1)*channels]
+		delta := luminance(px) - luminance(shown)
+		if delta >= l.Delta || delta <= -l.Delta {
+			if now.Sub(s.swungAt[i]) < gap {
+				copy(px, shown)
+				continue
+			}
+			s.swungAt[i] = now
+		}
+		copy(shown, px)
+	}
+}
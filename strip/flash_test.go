@@ -0,0 +1,53 @@
+package strip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+func TestLimitFlashes(t *testing.T) {
+	channels := 3
+	s := testStrip(2, Power{})
+	s.Channels = &channels
+	s.FlashLimit = DefaultFlashLimit
+	start := time.Now()
+	swings, last := 0, byte(0)
+	// The first pixel toggles between black and white at 25 frames a
+	// second, the second fades slowly.
+	for i := 0; i < 50; i++ {
+		buf := []byte{0, 0, 0, byte(i), byte(i), byte(i)}
+		if i%2 == 1 {
+			buf[0], buf[1], buf[2] = 255, 255, 255
+		}
+		s.limitFlashes(buf, start.Add(time.Duration(i)*40*time.Millisecond))
+		if buf[0] != last {
+			swings++
+			last = buf[0]
+		}
+		if buf[3] != byte(i) {
+			t.Fatalf("frame %d: the fade was held back at %d", i, buf[3])
+		}
+	}
+	// Two seconds allow six flashes, twelve swings.
+	if swings > 12 || swings < 10 {
+		t.Errorf("%d swings in two seconds, want at most 12", swings)
+	}
+}
+
+func TestFlashLimitPattern(t *testing.T) {
+	fast := led.Pattern{Period: 100 * time.Millisecond, Duty: 0.5}
+	if got := DefaultFlashLimit.pattern(fast); got.Period < time.Second/3 || got.Duty != 0.5 {
+		t.Errorf("pattern() = %+v, want slowed to 3Hz", got)
+	}
+	if got := (FlashLimit{}).pattern(fast); got != fast {
+		t.Errorf("pattern() with the limit off = %+v", got)
+	}
+	if err := (FlashLimit{Hertz: 10, Delta: 0.1}).Validate(); err == nil {
+		t.Error("Validate() accepted 10Hz")
+	}
+	if err := (FlashLimit{}).Validate(); err != nil {
+		t.Errorf("Validate() refused the limit off: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package strip
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// hidProduct is a USB HID LED gadget the hid backend drives.
+type hidProduct struct {
+	name    string
+	vendor  uint16
+	product uint16
+	// pixels is the most LEDs the gadget has.
+	pixels int
+	// reports encodes the feature reports setting the LEDs to rgb, three
+	// bytes for each.
+	reports func(rgb []byte) [][]byte
+}
+
+// hidProducts are the gadgets the hid backend finds.
+var hidProducts = []hidProduct{
+	{name: "BlinkStick", vendor: 0x20a0, product: 0x41e5, pixels: 64, reports: blinkStickReports},
+	{name: "blink(1)", vendor: 0x27b8, product: 0x01ed, pixels: 2, reports: blink1Reports},
+}
+
+// blinkStickReports sets a single LED with report 1 and more with the
+// smallest of reports 6 to 9, for 8 to 64 LEDs, sent green first.
+func blinkStickReports(rgb []byte) [][]byte {
+	if len(rgb) == 3 {
+		return [][]byte{{1, rgb[0], rgb[1], rgb[2]}}
+	}
+	id, leds := byte(6), 8
+	for leds < len(rgb)/3 {
+		id, leds = id+1, leds*2
+	}
+	report := make([]byte, 2+3*leds)
+	report[0] = id
+	for i := 0; i < len(rgb)/3; i++ {
+		report[2+3*i], report[3+3*i], report[4+3*i] = rgb[3*i+1], rgb[3*i], rgb[3*i+2]
+	}
+	return [][]byte{report}
+}
+
+// blink1Reports fades each LED to its colour at once, both LEDs together if
+// only one is used.
+func blink1Reports(rgb []byte) [][]byte {
+	var reports [][]byte
+	for i := 0; i < len(rgb)/3; i++ {
+		led := byte(i + 1)
+		if len(rgb) == 3 {
+			led = 0
+		}
+		reports = append(reports, []byte{1, 'c', rgb[3*i], rgb[3*i+1], rgb[3*i+2], 0, 0, led, 0})
+	}
+	return reports
+}
+
+// hidLED is a Display for a USB HID LED gadget, each frame sent as the
+// product's feature reports. White, on RGBW strips, is added to the other
+// channels.
+type hidLED struct {
+	tx       func(report []byte) error
+	product  hidProduct
+	channels int
+	rgb      []byte
+	zero     []byte
+}
+
+// newHIDLED encodes frames of length pixels of channels for product and
+// hands each report to tx.
+func newHIDLED(tx func([]byte) error, product hidProduct, length, channels int) (*hidLED, error) {
+	if length > product.pixels {
+		return nil, fmt.Errorf("the %s has %d LEDs, not %d", product.name, product.pixels, length)
+	}
+	return &hidLED{
+		tx:       tx,
+		product:  product,
+		channels: channels,
+		rgb:      make([]byte, 3*length),
+		zero:     make([]byte, length*channels),
+	}, nil
+}
+
+func (d *hidLED) Write(frame []byte) (int, error) {
+	if len(frame)%d.channels != 0 || len(frame) > len(d.zero) {
+		return 0, errors.New("hid: frame does not fit the gadget")
+	}
+	for i := 0; i < len(frame)/d.channels; i++ {
+		px := frame[i*d.channels : (i+1)*d.channels]
+		var white int
+		if d.channels == 4 {
+			white = int(px[3])
+		}
+		for c := 0; c < 3; c++ {
+			d.rgb[3*i+c] = byte(clamp(int(px[c]) + white))
+		}
+	}
+	for _, report := range d.product.reports(d.rgb) {
+		if err := d.tx(report); err != nil {
+			return 0, err
+		}
+	}
+	return len(frame), nil
+}
+
+// Halt turns every LED off.
+func (d *hidLED) Halt() error {
+	_, err := d.Write(d.zero)
+	return err
+}
+
+// hidUevent reads the product and serial from a hidraw device's uevent,
+// whose HID_ID is bus:vendor:product in hex and HID_UNIQ the serial.
+func hidUevent(uevent string) (vendor, product uint16, serial string) {
+	s := bufio.NewScanner(strings.NewReader(uevent))
+	for s.Scan() {
+		key, value, _ := strings.Cut(s.Text(), "=")
+		switch key {
+		case "HID_ID":
+			if f := strings.Split(value, ":"); len(f) == 3 {
+				v, _ := strconv.ParseUint(f[1], 16, 32)
+				p, _ := strconv.ParseUint(f[2], 16, 32)
+				vendor, product = uint16(v), uint16(p)
+			}
+		case "HID_UNIQ":
+			serial = value
+		}
+	}
+	return vendor, product, serial
+}
+
+// hidMatch finds the product of a hidraw device's uevent, if the backend
+// drives it and it has serial, or any serial if that is empty.
+func hidMatch(uevent, serial string) (hidProduct, bool) {
+	vendor, product, uniq := hidUevent(uevent)
+	if serial != "" && uniq != serial {
+		return hidProduct{}, false
+	}
+	for _, p := range hidProducts {
+		if p.vendor == vendor && p.product == product {
+			return p, true
+		}
+	}
+	return hidProduct{}, false
+}
@@ -0,0 +1,55 @@
+//go:build linux
+
+package strip
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// hidiocsfeature is HIDIOCSFEATURE(n) from linux/hidraw.h.
+func hidiocsfeature(n int) uintptr {
+	return 3<<30 | uintptr(n)<<16 | 'H'<<8 | 0x06
+}
+
+// openHID opens the USB HID LED gadget whose serial is p.Device, or the
+// first found if that is empty. Hertz and Order do not apply.
+func openHID(p Port) (io.Closer, Display, error) {
+	uevents, _ := filepath.Glob("/sys/class/hidraw/*/device/uevent")
+	for _, uevent := range uevents {
+		b, err := os.ReadFile(uevent)
+		if err != nil {
+			continue
+		}
+		product, ok := hidMatch(string(b), p.Device)
+		if !ok {
+			continue
+		}
+		dev := "/dev/" + filepath.Base(filepath.Dir(filepath.Dir(uevent)))
+		f, err := os.OpenFile(dev, os.O_RDWR, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		display, err := newHIDLED(func(report []byte) error {
+			_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), hidiocsfeature(len(report)), uintptr(unsafe.Pointer(&report[0])))
+			if errno != 0 {
+				return fmt.Errorf("%s: %w", dev, errno)
+			}
+			return nil
+		}, product, p.Length, p.Channels)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return f, display, nil
+	}
+	if p.Device != "" {
+		return nil, nil, fmt.Errorf("no BlinkStick or blink(1) with serial %q", p.Device)
+	}
+	return nil, nil, fmt.Errorf("no BlinkStick or blink(1) found")
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package strip
+
+import (
+	"errors"
+	"io"
+)
+
+// openHID fails, USB HID gadgets are only found through hidraw on Linux.
+func openHID(p Port) (io.Closer, Display, error) {
+	return nil, nil, errors.New("USB HID gadgets are only supported on Linux, use simulate")
+}
@@ -0,0 +1,54 @@
+package strip
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBlinkStickReports(t *testing.T) {
+	if got := blinkStickReports([]byte{1, 2, 3}); len(got) != 1 || !bytes.Equal(got[0], []byte{1, 1, 2, 3}) {
+		t.Errorf("one LED: % x", got)
+	}
+	got := blinkStickReports(bytes.Repeat([]byte{1, 2, 3}, 9))
+	if len(got) != 1 || got[0][0] != 7 || len(got[0]) != 50 {
+		t.Fatalf("nine LEDs want report 7 for 16: % x", got)
+	}
+	if !bytes.Equal(got[0][2:5], []byte{2, 1, 3}) || got[0][29] != 0 {
+		t.Errorf("LEDs not sent green first: % x", got[0])
+	}
+}
+
+func TestHIDLEDWrite(t *testing.T) {
+	var sent [][]byte
+	tx := func(r []byte) error { sent = append(sent, append([]byte(nil), r...)); return nil }
+	d, err := newHIDLED(tx, hidProducts[1], 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Write([]byte{0xff, 0, 0, 0, 0, 0, 0, 0x10}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{{1, 'c', 0xff, 0, 0, 0, 0, 1, 0}, {1, 'c', 0x10, 0x10, 0x10, 0, 0, 2, 0}}
+	if len(sent) != 2 || !bytes.Equal(sent[0], want[0]) || !bytes.Equal(sent[1], want[1]) {
+		t.Errorf("sent % x, want % x", sent, want)
+	}
+	if _, err := newHIDLED(tx, hidProducts[1], 3, 3); err == nil {
+		t.Error("newHIDLED() accepted three LEDs on a blink(1)")
+	}
+}
+
+func TestHIDMatch(t *testing.T) {
+	uevent := "DRIVER=hid-generic\nHID_ID=0003:000020A0:000041E5\nHID_NAME=Agile Innovations BlinkStick\nHID_UNIQ=BS012345-3.0\n"
+	if p, ok := hidMatch(uevent, ""); !ok || p.name != "BlinkStick" {
+		t.Errorf("hidMatch() = %v, %v, want any BlinkStick", p.name, ok)
+	}
+	if _, ok := hidMatch(uevent, "BS012345-3.0"); !ok {
+		t.Error("hidMatch() did not match the serial")
+	}
+	if _, ok := hidMatch(uevent, "BS999999-3.0"); ok {
+		t.Error("hidMatch() matched another serial")
+	}
+	if _, ok := hidMatch("HID_ID=0003:0000046D:0000C52B\n", ""); ok {
+		t.Error("hidMatch() matched a keyboard")
+	}
+}
@@ -0,0 +1,133 @@
+package strip
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// Panel is the size of a matrix panel in its own pixels.
+type Panel struct {
+	Width  int
+	Height int
+}
+
+// DefaultPanel is the common cheap HUB75 panel.
+var DefaultPanel = Panel{Width: 64, Height: 32}
+
+// flaschenTaschenPort is where Flaschen Taschen servers listen for frames.
+const flaschenTaschenPort = "1337"
+
+// Grid lays length pixels out on the panel as square cells, as large as
+// still fit, returning the cells per row and their size. It fails if the
+// panel has fewer pixels than that.
+func (p Panel) Grid(length int) (cols, size int, err error) {
+	for size = min(p.Width, p.Height); size > 0; size-- {
+		if cols = p.Width / size; cols*(p.Height/size) >= length {
+			return cols, size, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("a %dx%d panel cannot show %d pixels", p.Width, p.Height, length)
+}
+
+// hub75 is a Display for a HUB75 panel driven by a Flaschen Taschen server,
+// such as the ft-server of rpi-rgb-led-matrix, which takes each frame as a
+// PPM image. Pixels are drawn as a grid of cells along each row from the
+// top left, with a dark line between cells big enough to spare one, and
+// white, on RGBW strips, is added to the other channels.
+type hub75 struct {
+	tx       func(w []byte) error
+	channels int
+	panel    Panel
+	cols     int
+	size     int
+	buf      []byte
+	image    []byte
+	zero     []byte
+}
+
+// newHUB75 encodes frames of length pixels of channels as images of panel
+// and hands each to tx.
+func newHUB75(tx func([]byte) error, length, channels int, panel Panel) (*hub75, error) {
+	cols, size, err := panel.Grid(length)
+	if err != nil {
+		return nil, err
+	}
+	header := "P6\n" + strconv.Itoa(panel.Width) + " " + strconv.Itoa(panel.Height) + "\n255\n"
+	buf := make([]byte, len(header)+3*panel.Width*panel.Height)
+	copy(buf, header)
+	return &hub75{
+		tx:       tx,
+		channels: channels,
+		panel:    panel,
+		cols:     cols,
+		size:     size,
+		buf:      buf,
+		image:    buf[len(header):],
+		zero:     make([]byte, length*channels),
+	}, nil
+}
+
+func (d *hub75) Write(frame []byte) (int, error) {
+	if len(frame)%d.channels != 0 || len(frame) > len(d.zero) {
+		return 0, errors.New("hub75: frame does not fit the panel")
+	}
+	clear(d.image)
+	fill := d.size
+	if fill >= 3 {
+		fill--
+	}
+	for i := 0; i < len(frame)/d.channels; i++ {
+		px := frame[i*d.channels : (i+1)*d.channels]
+		var white int
+		if d.channels == 4 {
+			white = int(px[3])
+		}
+		rgb := [3]byte{byte(clamp(int(px[0]) + white)), byte(clamp(int(px[1]) + white)), byte(clamp(int(px[2]) + white))}
+		x0, y0 := i%d.cols*d.size, i/d.cols*d.size
+		for y := y0; y < y0+fill; y++ {
+			row := d.image[3*(y*d.panel.Width+x0):]
+			for x := 0; x < fill; x++ {
+				copy(row[3*x:], rgb[:])
+			}
+		}
+	}
+	return len(frame), d.tx(d.buf)
+}
+
+// Halt turns every pixel off.
+func (d *hub75) Halt() error {
+	_, err := d.Write(d.zero)
+	return err
+}
+
+// openHUB75 sends frames to the Flaschen Taschen server at p.Device, a host
+// and optional port, localhost if empty. Hertz and Order do not apply.
+func openHUB75(p Port) (io.Closer, Display, error) {
+	panel := p.Panel
+	if panel.Width == 0 || panel.Height == 0 {
+		panel = DefaultPanel
+	}
+	addr := p.Device
+	if addr == "" {
+		addr = "localhost"
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, flaschenTaschenPort)
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	display, err := newHUB75(func(w []byte) error {
+		_, err := conn.Write(w)
+		return err
+	}, p.Length, p.Channels, panel)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, display, nil
+}
@@ -0,0 +1,49 @@
+package strip
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPanelGrid(t *testing.T) {
+	for _, c := range []struct {
+		length, cols, size int
+	}{
+		{2, 2, 32},
+		{8, 4, 16},
+		{30, 8, 8},
+		{33, 9, 7},
+		{2048, 64, 1},
+	} {
+		cols, size, err := DefaultPanel.Grid(c.length)
+		if err != nil || cols != c.cols || size != c.size {
+			t.Errorf("Grid(%d) = %d, %d, %v, want %d cells of %d", c.length, cols, size, err, c.cols, c.size)
+		}
+	}
+	if _, _, err := DefaultPanel.Grid(2049); err == nil {
+		t.Error("Grid() fitted more pixels than the panel has")
+	}
+}
+
+func TestHUB75Write(t *testing.T) {
+	var sent []byte
+	d, err := newHUB75(func(w []byte) error { sent = append([]byte(nil), w...); return nil }, 32, 3, DefaultPanel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]byte, 96)
+	frame[3] = 0xff // the second cell is red
+	if _, err := d.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	header := []byte("P6\n64 32\n255\n")
+	if !bytes.HasPrefix(sent, header) || len(sent) != len(header)+64*32*3 {
+		t.Fatalf("sent %d bytes starting %q", len(sent), sent[:min(len(sent), 16)])
+	}
+	image := sent[len(header):]
+	at := func(x, y int) byte { return image[3*(y*64+x)] }
+	// Cells are 8 pixels with a dark line right and below.
+	if at(8, 0) != 0xff || at(14, 6) != 0xff || at(15, 0) != 0 || at(8, 7) != 0 || at(7, 0) != 0 {
+		t.Error("the second cell is not drawn at 8-14 by 0-6")
+	}
+}
@@ -0,0 +1,68 @@
+package strip
+
+import (
+	"time"
+
+	"github.com/shift/systemd-status-leds/telemetry"
+	"go.uber.org/zap"
+)
+
+// DefaultLatencyOutlier is how much later than expected a change or frame
+// may reach the LEDs before it is logged.
+const DefaultLatencyOutlier = 250 * time.Millisecond
+
+// latencyBounds are the buckets of the latency histograms in seconds, from a
+// millisecond to past the default interval.
+var latencyBounds = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	changeLatency = telemetry.NewHistogram("statusleds_change_latency_seconds", "How long state changes took to reach the LEDs, from their receipt to the end of the write showing them.", latencyBounds)
+	frameLag      = telemetry.NewHistogram("statusleds_frame_lag_seconds", "How long after its tick each frame finished writing.", latencyBounds)
+)
+
+// changes returns when each unit's pixel changed state since the last frame
+// written, reusing the strip's slice.
+func (s *Strip) changes() []time.Time {
+	s.changed = s.changed[:0]
+	for _, p := range s.Pixels {
+		if p.Changed.After(s.seen) {
+			s.changed = append(s.changed, p.Changed)
+		}
+	}
+	return s.changed
+}
+
+// measure records, just after the frame due at tick was written, how late it
+// was and how long each of the changes it shows took, logging those later
+// than expected by more than LatencyOutlier. The states found at start up
+// are not counted as changes. The caller holds writeMu.
+func (s *Strip) measure(tick time.Time, changes []time.Time) {
+	m := s.metrics()
+	done := time.Now()
+	lag := done.Sub(tick)
+	m.lag.Observe(lag.Seconds())
+	outlier := s.LatencyOutlier
+	if outlier <= 0 {
+		outlier = DefaultLatencyOutlier
+	}
+	if lag > outlier {
+		s.Logger.WarnL("latency", "Frame written late", zap.String("strip", s.Name), zap.Duration("lag", lag))
+	}
+	first := s.seen.IsZero()
+	var slowest time.Duration
+	for _, changed := range changes {
+		if changed.After(s.seen) {
+			s.seen = changed
+		}
+		if first {
+			continue
+		}
+		latency := done.Sub(changed)
+		m.changes.Observe(latency.Seconds())
+		slowest = max(slowest, latency)
+	}
+	// A change waits up to an interval for its frame.
+	if slowest > s.Interval+outlier {
+		s.Logger.WarnL("latency", "State change reached the LEDs late", zap.String("strip", s.Name), zap.Duration("latency", slowest), zap.Duration("interval", s.Interval))
+	}
+}
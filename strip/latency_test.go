@@ -0,0 +1,37 @@
+package strip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shift/systemd-status-leds/telemetry"
+)
+
+func TestMeasureLatency(t *testing.T) {
+	s := benchStrip(t)
+	s.Name = "latency"
+	name := telemetry.Attr{Key: "strip", Value: s.Name}
+	buf := make([]byte, *s.Count**s.Channels)
+	out := make([]byte, len(buf))
+
+	s.Pixels[3].SetStatus("active")
+	s.frame(buf, out, time.Now())
+	if got := changeLatency.Value(name); got != 0 {
+		t.Errorf("the states found at start up counted %vs", got)
+	}
+	if frameLag.Value(name) <= 0 {
+		t.Error("no frame lag recorded")
+	}
+
+	s.Pixels[3].SetStatus("failed")
+	time.Sleep(10 * time.Millisecond)
+	s.frame(buf, out, time.Now())
+	first := changeLatency.Value(name)
+	if first < 0.01 || first > 1 {
+		t.Errorf("change latency %vs, want about 10ms", first)
+	}
+	s.frame(buf, out, time.Now())
+	if got := changeLatency.Value(name); got != first {
+		t.Errorf("a change counted twice, %vs then %vs", first, got)
+	}
+}
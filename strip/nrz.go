@@ -0,0 +1,132 @@
+package strip
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Frequencies NRZ LEDs are driven at: 400kHz for WS2811 and other slow parts,
+// 800kHz for WS2812 and most others.
+const (
+	SlowHertz    = 400_000
+	DefaultHertz = 800_000
+)
+
+// ValidateHertz checks that hertz is close enough to 400kHz or 800kHz for
+// the LEDs to keep up.
+func ValidateHertz(hertz int) error {
+	if (hertz >= 380_000 && hertz <= 420_000) || (hertz >= 760_000 && hertz <= 840_000) {
+		return nil
+	}
+	return fmt.Errorf("hertz %d is not within 5%% of 400000 (WS2811) or 800000 (WS2812)", hertz)
+}
+
+const (
+	// nrzLead is the zero bytes sent ahead of each frame, holding the line
+	// low while the SPI port starts up.
+	nrzLead = 3
+	// nrzResetSeconds is how long the line is held low after a frame to
+	// latch it, long enough for newer WS2812B.
+	nrzResetSeconds = 300e-6
+)
+
+// Part is the protocol of an LED part: its data rate, channels and the
+// order it expects them in.
+type Part struct {
+	Hertz    int
+	Channels int
+	Order    string
+}
+
+// Parts are the LED parts strips can be configured by.
+var Parts = map[string]Part{
+	"ws2812b":     {Hertz: DefaultHertz, Channels: 3, Order: "grb"},
+	"ws2811":      {Hertz: SlowHertz, Channels: 3, Order: "rgb"},
+	"sk6812":      {Hertz: DefaultHertz, Channels: 3, Order: "grb"},
+	"sk6812-rgbw": {Hertz: DefaultHertz, Channels: 4, Order: "grbw"},
+}
+
+// DefaultOrder is the channel order of most parts.
+const DefaultOrder = "grb"
+
+// ValidateOrder checks that order names each of the channels once, using r,
+// g, b and, for RGBW strips, w.
+func ValidateOrder(order string, channels int) error {
+	if len(order) < 3 || len(order) > channels || (len(order) == 3 && channels == 4) {
+		return fmt.Errorf("order %q does not fit %d channels", order, channels)
+	}
+	for _, c := range "rgbw"[:len(order)] {
+		if strings.Count(order, string(c)) != 1 {
+			return fmt.Errorf("order %q must name each of %s once", order, "rgbw"[:len(order)])
+		}
+	}
+	return nil
+}
+
+// nrz is a Display for NRZ LEDs on an SPI port clocked at three times their
+// bit rate, so each bit is sent as three SPI bits: 100 for a zero, 110 for a
+// one. Channels are sent in the part's order.
+type nrz struct {
+	tx       func(w []byte) error
+	channels int
+	order    [4]int // the frame channel sent in each position
+	buf      []byte
+	zero     []byte
+}
+
+// newNRZ encodes frames of length pixels of channels for LEDs at hertz
+// taking them in order, green, red, blue then white if empty, and hands each
+// to tx.
+func newNRZ(tx func([]byte) error, length, channels, hertz int, order string) *nrz {
+	if order == "" {
+		order = DefaultOrder + "w"
+	}
+	reset := int(nrzResetSeconds*float64(3*hertz)/8) + 1
+	d := &nrz{
+		tx:       tx,
+		channels: channels,
+		buf:      make([]byte, nrzLead+3*length*channels+reset),
+		zero:     make([]byte, length*channels),
+	}
+	for i := 0; i < channels && i < len(order); i++ {
+		d.order[i] = strings.IndexByte("rgbw", order[i])
+	}
+	return d
+}
+
+// nrzSize is the size of the SPI transfers for a strip of length pixels of
+// channels at hertz.
+func nrzSize(length, channels, hertz int) int {
+	return len(newNRZ(nil, length, channels, hertz, "").buf)
+}
+
+func (d *nrz) Write(frame []byte) (int, error) {
+	if len(frame)%d.channels != 0 || len(frame) > len(d.zero) {
+		return 0, errors.New("nrz: frame does not fit the strip")
+	}
+	out := d.buf[nrzLead:]
+	for i := 0; i < len(frame); i += d.channels {
+		px := frame[i : i+d.channels]
+		for c := 0; c < d.channels; c++ {
+			putNRZ(out[3*(i+c):], px[d.order[c]])
+		}
+	}
+	return len(frame), d.tx(d.buf)
+}
+
+// Halt turns every pixel off.
+func (d *nrz) Halt() error {
+	_, err := d.Write(d.zero)
+	return err
+}
+
+// putNRZ writes the 24 SPI bits encoding b, most significant bit first, to
+// out.
+func putNRZ(out []byte, b byte) {
+	var bits uint32
+	for i := 7; i >= 0; i-- {
+		bits = bits<<3 | 0b100 | uint32(b>>i&1)<<1
+	}
+	out[0], out[1], out[2] = byte(bits>>16), byte(bits>>8), byte(bits)
+}
@@ -0,0 +1,93 @@
+package strip
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPutNRZ(t *testing.T) {
+	for _, c := range []struct {
+		b    byte
+		want [3]byte
+	}{
+		{0x00, [3]byte{0x92, 0x49, 0x24}},
+		{0xff, [3]byte{0xdb, 0x6d, 0xb6}},
+		{0x80, [3]byte{0xd2, 0x49, 0x24}},
+		{0x01, [3]byte{0x92, 0x49, 0x26}},
+	} {
+		var got [3]byte
+		putNRZ(got[:], c.b)
+		if got != c.want {
+			t.Errorf("putNRZ(%#02x) = % x, want % x", c.b, got, c.want)
+		}
+	}
+}
+
+func TestNRZWrite(t *testing.T) {
+	var sent []byte
+	d := newNRZ(func(w []byte) error { sent = append([]byte(nil), w...); return nil }, 2, 3, DefaultHertz, "")
+	if _, err := d.Write([]byte{0xff, 0x00, 0x00, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	one, zero := make([]byte, 3), make([]byte, 3)
+	putNRZ(one, 0xff)
+	putNRZ(zero, 0)
+	// Green first: the red pixel's first byte is a zero, its second a one.
+	want := append(append(append([]byte{0, 0, 0}, zero...), one...), zero...)
+	if !bytes.Equal(sent[:len(want)], want) {
+		t.Errorf("frame starts % x, want % x", sent[:len(want)], want)
+	}
+	// 300µs at 2.4MHz latches the frame.
+	if reset := len(sent) - nrzLead - 18; reset < 90 {
+		t.Errorf("reset of %d bytes, want at least 90", reset)
+	}
+	for _, b := range sent[nrzLead+18:] {
+		if b != 0 {
+			t.Fatalf("reset is not low: % x", sent[nrzLead+18:])
+		}
+	}
+	if _, err := d.Write(make([]byte, 9)); err == nil {
+		t.Error("Write() accepted a frame longer than the strip")
+	}
+}
+
+func TestValidateHertz(t *testing.T) {
+	for hertz, ok := range map[int]bool{
+		400_000: true, 800_000: true, 820_000: true,
+		2_500_000: false, 600_000: false, 0: false,
+	} {
+		if err := ValidateHertz(hertz); (err == nil) != ok {
+			t.Errorf("ValidateHertz(%d) = %v", hertz, err)
+		}
+	}
+}
+
+func TestNRZOrder(t *testing.T) {
+	var sent []byte
+	d := newNRZ(func(w []byte) error { sent = append([]byte(nil), w...); return nil }, 1, 4, DefaultHertz, "wbgr")
+	if _, err := d.Write([]byte{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []byte{4, 3, 2, 1} {
+		var enc [3]byte
+		putNRZ(enc[:], want)
+		if got := sent[nrzLead+3*i : nrzLead+3*i+3]; !bytes.Equal(got, enc[:]) {
+			t.Errorf("channel %d sent % x, want %d", i, got, want)
+		}
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	for _, c := range []struct {
+		order    string
+		channels int
+		ok       bool
+	}{
+		{"grb", 3, true}, {"rgb", 3, true}, {"grbw", 4, true},
+		{"grb", 4, false}, {"grbw", 3, false}, {"grr", 3, false}, {"gbw", 3, false}, {"xy", 3, false},
+	} {
+		if err := ValidateOrder(c.order, c.channels); (err == nil) != c.ok {
+			t.Errorf("ValidateOrder(%q, %d) = %v", c.order, c.channels, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package strip
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// pin is a Display for NRZ LEDs streamed from a GPIO pin by nrzled, which
+// takes RGB or RGBW pixels and always sends them green first. Frames are
+// shuffled so the LEDs still get their channels in the part's order.
+type pin struct {
+	w        io.Writer
+	channels int
+	in       [4]int // the frame channel nrzled must be given in each position
+	buf      []byte
+	zero     []byte
+}
+
+// newPin writes frames of length pixels of channels to w, an nrzled strip,
+// for LEDs taking them in order, green, red, blue then white if empty.
+func newPin(w io.Writer, length, channels int, order string) *pin {
+	if order == "" {
+		order = DefaultOrder + "w"
+	}
+	d := &pin{
+		w:        w,
+		channels: channels,
+		buf:      make([]byte, length*channels),
+		zero:     make([]byte, length*channels),
+	}
+	// nrzled sends its input's green, red, blue then white.
+	sends := [4]int{1, 0, 2, 3}
+	for i := 0; i < channels && i < len(order); i++ {
+		d.in[sends[i]] = strings.IndexByte("rgbw", order[i])
+	}
+	return d
+}
+
+func (d *pin) Write(frame []byte) (int, error) {
+	if len(frame)%d.channels != 0 || len(frame) > len(d.buf) {
+		return 0, errors.New("pin: frame does not fit the strip")
+	}
+	for i := 0; i < len(frame); i += d.channels {
+		for c := 0; c < d.channels; c++ {
+			d.buf[i+c] = frame[i+d.in[c]]
+		}
+	}
+	return d.w.Write(d.buf[:len(frame)])
+}
+
+// Halt turns every pixel off.
+func (d *pin) Halt() error {
+	_, err := d.Write(d.zero)
+	return err
+}
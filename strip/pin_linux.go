@@ -0,0 +1,45 @@
+//go:build linux
+
+package strip
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/conn/v3/gpio/gpiostream"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/devices/v3/nrzled"
+	"periph.io/x/host/v3"
+)
+
+// openPin streams an NRZ strip from the GPIO pin p.Device names, such as
+// GPIO18. The pin must support streaming, PWM with DMA on a Raspberry Pi.
+func openPin(p Port) (io.Closer, Display, error) {
+	if _, err := host.Init(); err != nil {
+		return nil, nil, errors.New("Unable to intialize the pariph.Host.")
+	}
+	gp := gpioreg.ByName(p.Device)
+	if gp == nil {
+		return nil, nil, fmt.Errorf("no GPIO pin %q", p.Device)
+	}
+	out, ok := gp.(gpiostream.PinOut)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s cannot stream, use a PWM pin such as GPIO18", p.Device)
+	}
+	dev, err := nrzled.NewStream(out, &nrzled.Opts{
+		NumPixels: p.Length,
+		Channels:  p.Channels,
+		Freq:      physic.Frequency(p.Hertz) * physic.Hertz,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pinCloser{out}, newPin(dev, p.Length, p.Channels, p.Order), nil
+}
+
+// pinCloser releases the pin when the strip's port is closed.
+type pinCloser struct{ gpiostream.PinOut }
+
+func (p pinCloser) Close() error { return p.Halt() }
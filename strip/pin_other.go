@@ -0,0 +1,13 @@
+//go:build !linux
+
+package strip
+
+import (
+	"errors"
+	"io"
+)
+
+// openPin fails, strips are only streamed from a GPIO pin on Linux.
+func openPin(p Port) (io.Closer, Display, error) {
+	return nil, nil, errors.New("GPIO pin strips are only supported on Linux, use simulate")
+}
@@ -0,0 +1,31 @@
+package strip
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPinOrder(t *testing.T) {
+	for _, c := range []struct {
+		order string
+		want  []byte
+	}{
+		// nrzled swaps red and green itself.
+		{"", []byte{1, 2, 3, 4}},
+		{"grbw", []byte{1, 2, 3, 4}},
+		{"rgbw", []byte{2, 1, 3, 4}},
+		{"wrgb", []byte{1, 4, 2, 3}},
+	} {
+		var w bytes.Buffer
+		d := newPin(&w, 1, 4, c.order)
+		if _, err := d.Write([]byte{1, 2, 3, 4}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(w.Bytes(), c.want) {
+			t.Errorf("order %q: gave nrzled % x, want % x", c.order, w.Bytes(), c.want)
+		}
+	}
+	if _, err := newPin(&bytes.Buffer{}, 1, 3, "").Write(make([]byte, 6)); err == nil {
+		t.Error("Write() accepted a frame longer than the strip")
+	}
+}
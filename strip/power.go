@@ -0,0 +1,91 @@
+package strip
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMilliampsPerChannel = 20
+	DefaultIdleMilliamps       = 1
+)
+
+// Power is the current budget of a strip and the model used to estimate its
+// draw.
+type Power struct {
+	// MaxMilliamps is the budget for the whole strip in mA. Zero disables the
+	// limit.
+	MaxMilliamps int
+	// MilliampsPerChannel is the draw of a single channel at full brightness
+	// in mA. WS281X parts draw about DefaultMilliampsPerChannel (20 mA).
+	MilliampsPerChannel int
+	// IdleMilliamps is the quiescent draw of each LED's driver chip in mA,
+	// DefaultIdleMilliamps (1 mA) unless configured.
+	IdleMilliamps int
+}
+
+// Validate checks the model for a strip of length LEDs. A budget at or below
+// the idle draw could only ever be met by a blank strip, so it is rejected.
+func (p Power) Validate(length int) error {
+	if p.MaxMilliamps < 0 {
+		return fmt.Errorf("max_milliamps must not be negative, got %d", p.MaxMilliamps)
+	}
+	if p.MilliampsPerChannel <= 0 {
+		return fmt.Errorf("milliamps_per_channel must be positive, got %d", p.MilliampsPerChannel)
+	}
+	if p.IdleMilliamps < 0 {
+		return fmt.Errorf("idle_milliamps must not be negative, got %d", p.IdleMilliamps)
+	}
+	if idle := length * p.IdleMilliamps; p.MaxMilliamps > 0 && p.MaxMilliamps <= idle {
+		return fmt.Errorf("max_milliamps %d must exceed the idle draw of %d", p.MaxMilliamps, idle)
+	}
+	return nil
+}
+
+// Milliamps estimates the current drawn by the strip when showing buf. Each
+// channel draws MilliampsPerChannel at full brightness. Each LED adds
+// IdleMilliamps for its driver chip.
+func (s *Strip) Milliamps(buf []byte) int {
+	var sum int
+	for _, b := range buf {
+		sum += int(b)
+	}
+	return *s.Count*s.Power.IdleMilliamps + sum*s.Power.MilliampsPerChannel/255
+}
+
+// limitPower scales the whole frame down when its estimated draw exceeds the
+// budget. This stops many bright pixels from browning out the supply.
+func (s *Strip) limitPower(buf []byte) {
+	scale := s.powerScale(buf, 1)
+	if scale >= 1 {
+		return
+	}
+	for i, b := range buf {
+		buf[i] = byte(float64(b) * scale)
+	}
+}
+
+// powerScale returns the factor that brings buf, once dimmed by f, within the
+// budget.
+func (s *Strip) powerScale(buf []byte, f float64) float64 {
+	if s.Power.MaxMilliamps <= 0 {
+		return 1
+	}
+	var sum int
+	for _, b := range buf {
+		sum += int(b)
+	}
+	idle := float64(*s.Count * s.Power.IdleMilliamps)
+	lit := float64(sum) * f * float64(s.Power.MilliampsPerChannel) / 255
+	if idle+lit <= float64(s.Power.MaxMilliamps) {
+		return 1
+	}
+	scale := (float64(s.Power.MaxMilliamps) - idle) / lit
+	s.Logger.WarnL("power", "Frame exceeds power budget, scaling down",
+		zap.Float64("milliamps", idle+lit),
+		zap.Int("max_milliamps", s.Power.MaxMilliamps),
+		zap.Float64("scale", scale),
+	)
+	return scale
+}
@@ -0,0 +1,57 @@
+package strip
+
+import (
+	"fmt"
+	"runtime"
+
+	"go.uber.org/zap"
+)
+
+// Realtime runs a strip's render loop, and the goroutine writing its frames
+// with a WriteTimeout, on threads of their own at a realtime priority, pinned
+// to CPUs, so a loaded machine does not delay frames or stretch their timing.
+type Realtime struct {
+	// Priority is the SCHED_FIFO priority, from 1 to 99, zero leaves the
+	// thread at normal priority.
+	Priority int
+	// CPUs the thread may run on, any unless set.
+	CPUs []int
+}
+
+// Validate checks the priority and CPUs are in range.
+func (r Realtime) Validate() error {
+	if r.Priority < 0 || r.Priority > 99 {
+		return fmt.Errorf("realtime.priority must be between 0 (off) and 99, got %d", r.Priority)
+	}
+	for _, cpu := range r.CPUs {
+		if cpu < 0 || cpu >= runtime.NumCPU() {
+			return fmt.Errorf("realtime.cpus: no CPU %d, there are %d", cpu, runtime.NumCPU())
+		}
+	}
+	return nil
+}
+
+// enabled is whether r asks for anything.
+func (r Realtime) enabled() bool {
+	return r.Priority > 0 || len(r.CPUs) > 0
+}
+
+// enter locks the calling goroutine to its thread and applies r to the
+// thread. Whatever cannot be applied, such as the priority without
+// CAP_SYS_NICE, is logged and the loop renders without it.
+func (s *Strip) enter(r Realtime) {
+	if !r.enabled() {
+		return
+	}
+	runtime.LockOSThread()
+	if len(r.CPUs) > 0 {
+		if err := pinCPUs(r.CPUs); err != nil {
+			s.Logger.Warn("Unable to pin the render thread, it runs on any CPU", zap.String("strip", s.Name), zap.Ints("cpus", r.CPUs), zap.Error(err))
+		}
+	}
+	if r.Priority > 0 {
+		if err := schedFIFO(r.Priority); err != nil {
+			s.Logger.Warn("Unable to render at realtime priority, it needs CAP_SYS_NICE", zap.String("strip", s.Name), zap.Int("priority", r.Priority), zap.Error(err))
+		}
+	}
+}
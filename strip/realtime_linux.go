@@ -0,0 +1,31 @@
+//go:build linux
+
+package strip
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// policyFIFO is SCHED_FIFO from linux/sched.h.
+const policyFIFO = 1
+
+// pinCPUs restricts the calling thread to cpus.
+func pinCPUs(cpus []int) error {
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	return unix.SchedSetaffinity(0, &set)
+}
+
+// schedFIFO moves the calling thread to SCHED_FIFO at priority.
+func schedFIFO(priority int) error {
+	param := struct{ priority int32 }{int32(priority)}
+	_, _, errno := unix.Syscall(unix.SYS_SCHED_SETSCHEDULER, 0, policyFIFO, uintptr(unsafe.Pointer(&param)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package strip
+
+import "errors"
+
+// pinCPUs fails, threads are only pinned to CPUs on Linux.
+func pinCPUs(cpus []int) error {
+	return errors.New("pinning to CPUs is only supported on Linux")
+}
+
+// schedFIFO fails, realtime priority is only supported on Linux.
+func schedFIFO(priority int) error {
+	return errors.New("realtime priority is only supported on Linux")
+}
@@ -0,0 +1,38 @@
+package strip
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRealtimeValidate(t *testing.T) {
+	for _, c := range []struct {
+		r  Realtime
+		ok bool
+	}{
+		{Realtime{}, true},
+		{Realtime{Priority: 50, CPUs: []int{0}}, true},
+		{Realtime{Priority: 100}, false},
+		{Realtime{Priority: -1}, false},
+		{Realtime{CPUs: []int{runtime.NumCPU()}}, false},
+	} {
+		if err := c.r.Validate(); (err == nil) != c.ok {
+			t.Errorf("%+v: %v", c.r, err)
+		}
+	}
+}
+
+func TestWriterEntersRealtime(t *testing.T) {
+	entered := make(chan struct{})
+	w := newWriter(discard{}, 6, func() { close(entered) })
+	defer w.close()
+	if err := w.write(make([]byte, 6), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-entered:
+	default:
+		t.Error("the writer wrote before entering realtime")
+	}
+}
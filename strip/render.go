@@ -0,0 +1,191 @@
+package strip
+
+import (
+	"time"
+
+	"github.com/shift/systemd-status-leds/ease"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/telemetry"
+)
+
+// colourCache holds the last colour it unpacked, so a colour that has not
+// changed since the last frame is not parsed again.
+type colourCache struct {
+	hex string
+	px  [4]byte
+}
+
+func (c *colourCache) rgba(colour string) [4]byte {
+	if colour != c.hex {
+		c.hex, c.px = colour, rgba(colour)
+	}
+	return c.px
+}
+
+// renderCache is what render keeps between frames: the unpacked colours of
+// each pixel, in Pixels then Overlays order, of the segment colour under each
+// pixel number and of the background.
+type renderCache struct {
+	colours    []colourCache
+	flashes    []colourCache
+	segments   []colourCache
+	background backgroundCache
+}
+
+// grow makes room for pixels pixels on a strip of count.
+func (c *renderCache) grow(pixels, count int) {
+	if len(c.colours) < pixels {
+		c.colours = make([]colourCache, pixels)
+		c.flashes = make([]colourCache, pixels)
+	}
+	if len(c.segments) < count {
+		c.segments = make([]colourCache, count)
+	}
+}
+
+// backgroundCache holds the unpacked colours and easing of a Background.
+type backgroundCache struct {
+	from, to colourCache
+	easing   string
+	shape    ease.Func
+}
+
+func (c *backgroundCache) ease(name string) ease.Func {
+	if c.shape == nil || name != c.easing {
+		shape, err := ease.ByName(name)
+		if err != nil {
+			shape = ease.InOut
+		}
+		c.easing, c.shape = name, shape
+	}
+	return c.shape
+}
+
+// stripMetrics are the strip's series of the SPI metrics.
+type stripMetrics struct {
+	attrs                   []telemetry.Attr
+	writes, errors, latency *telemetry.Series
+	failures, reopens       *telemetry.Series
+	changes, lag            *telemetry.Series
+}
+
+// metrics returns the strip's metrics, looking them up on first use. The
+// caller holds writeMu.
+func (s *Strip) metrics() *stripMetrics {
+	if s.series == nil {
+		name := telemetry.Attr{Key: "strip", Value: s.Name}
+		s.series = &stripMetrics{
+			attrs:   []telemetry.Attr{name},
+			writes:  spiWrites.Series(name),
+			errors:  spiErrors.Series(name),
+			latency: spiLatency.Series(name),
+
+			failures: spiFailures.Series(name),
+			reopens:  spiReopens.Series(name),
+			changes:  changeLatency.Series(name),
+			lag:      frameLag.Series(name),
+		}
+	}
+	return s.series
+}
+
+// frame renders the frame due at now into buf, encodes it into out and
+// writes it.
+func (s *Strip) frame(buf, out []byte, now time.Time) {
+	changes := s.changes()
+	s.render(buf, now)
+	s.limitFlashes(buf, now)
+	s.encode(buf, out)
+	s.writeMu.Lock()
+	if s.closed {
+		s.writeMu.Unlock()
+		return
+	}
+	err := s.write(out)
+	s.failed(err)
+	if err == nil {
+		s.measure(now, changes)
+	}
+	s.writeMu.Unlock()
+	s.Lock()
+	s.writeErr = err
+	s.framed = time.Now()
+	s.last = append(s.last[:0], buf...)
+	s.Unlock()
+}
+
+// Draw renders and writes a single frame as of now, for showing the states
+// once rather than running the strip. It must not be called while the strip
+// runs.
+func (s *Strip) Draw(now time.Time) error {
+	buf := make([]byte, *s.Count**s.Channels)
+	out := make([]byte, len(buf))
+	if s.residual == nil {
+		s.residual = make([]float64, len(buf))
+	}
+	s.frame(buf, out, now)
+	return s.WriteErr()
+}
+
+// Snapshot returns a copy of the last frame rendered, before brightness and
+// power scaling, in strip order, and the channels of each pixel. It is nil
+// before the first frame.
+func (s *Strip) Snapshot() (frame []byte, channels int) {
+	s.RLock()
+	defer s.RUnlock()
+	if s.last == nil {
+		return nil, *s.Channels
+	}
+	return append([]byte(nil), s.last...), *s.Channels
+}
+
+// render draws the frame due at now into buf. It keeps its caches in s, so
+// frames of one strip must not be rendered concurrently.
+func (s *Strip) render(buf []byte, now time.Time) {
+	c := &s.cache
+	c.grow(len(s.Pixels)+len(s.Overlays), *s.Count)
+	channels := *s.Channels
+	if s.Rotate > 0 {
+		s.rotation = s.rotationAt(now)
+	}
+	for number := 1; number <= *s.Count; number++ {
+		offset := s.Position(number) * channels
+		var px [4]byte
+		if colour := s.background(number); colour != "" {
+			px = c.segments[number-1].rgba(colour)
+		} else {
+			px = s.Background.colourAt(&c.background, number, *s.Count, now)
+		}
+		if channels == 1 {
+			px = mono(px)
+		}
+		copy(buf[offset:offset+channels], px[:])
+	}
+	k := 0
+	for _, pixels := range [][]*led.Led{s.Pixels, s.Overlays} {
+		for _, p := range pixels {
+			offset := s.Position(p.Number) * channels
+			var px [4]byte
+			if now.Before(p.FlashUntil) {
+				px = c.flashes[k].rgba(p.FlashColour)
+			} else if s.FlashLimit.pattern(p.Pattern).On(now) {
+				px = c.colours[k].rgba(p.Colour)
+				if p.Dim > 0 {
+					for i := range px {
+						px[i] = byte(float64(px[i]) * (1 - p.Dim))
+					}
+				}
+			}
+			if channels == 1 {
+				px = mono(px)
+			}
+			copy(buf[offset:offset+channels], px[:])
+			k++
+		}
+	}
+}
+
+// mono puts the brightest of px's channels first, for single-colour pixels.
+func mono(px [4]byte) [4]byte {
+	return [4]byte{max(px[0], px[1], px[2], px[3])}
+}
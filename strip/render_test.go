@@ -0,0 +1,128 @@
+package strip
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+type discard struct{}
+
+func (discard) Write(b []byte) (int, error) { return len(b), nil }
+func (discard) Halt() error                 { return nil }
+
+var _ Display = discard{}
+
+// benchStrip is a 300 pixel strip with a segment, a breathing background, a
+// flashing pixel, a blinking one and a dimmed one.
+func benchStrip(tb testing.TB) *Strip {
+	count, channels := 300, 4
+	s := testStrip(count, Power{})
+	s.Channels = &channels
+	s.Display = discard{}
+	s.Name = "bench"
+	s.Background = Background{Mode: "breathe", Colour: "10101000", Period: time.Second}
+	s.FlashLimit = DefaultFlashLimit
+	if err := s.AddSegment(Segment{Name: "web", Start: 0, End: 49, Background: "00001000", Separator: "ffffff00"}); err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		p, err := s.Add(fmt.Sprintf("unit%d.service", i))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		p.Colour = "00ff0000"
+	}
+	s.Pixels[0].FlashColour, s.Pixels[0].FlashUntil = "ffffffff", time.Now().Add(time.Hour)
+	s.Pixels[1].Pattern = led.Pattern{Period: time.Second, Duty: 0.5}
+	s.Pixels[2].Dim = 0.5
+	s.residual = make([]float64, count*channels)
+	return s
+}
+
+func BenchmarkFrame(b *testing.B) {
+	s := benchStrip(b)
+	buf := make([]byte, *s.Count**s.Channels)
+	out := make([]byte, len(buf))
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.frame(buf, out, now)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	s := benchStrip(b)
+	s.Dither = true
+	s.brightness = 0.3
+	buf := make([]byte, *s.Count**s.Channels)
+	out := make([]byte, len(buf))
+	s.render(buf, time.Now())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.encode(buf, out)
+	}
+}
+
+func TestFrameDoesNotAllocate(t *testing.T) {
+	s := benchStrip(t)
+	buf := make([]byte, *s.Count**s.Channels)
+	out := make([]byte, len(buf))
+	now := time.Now()
+	s.frame(buf, out, now)
+	if n := testing.AllocsPerRun(100, func() { s.frame(buf, out, now) }); n != 0 {
+		t.Errorf("frame allocated %v times, want none", n)
+	}
+}
+
+func TestRenderFollowsColourChanges(t *testing.T) {
+	s := benchStrip(t)
+	buf := make([]byte, *s.Count**s.Channels)
+	now := time.Now()
+	p := s.Pixels[10]
+	offset := s.Position(p.Number) * *s.Channels
+	for _, colour := range []string{"00ff0000", "0000ff00", "00ff0000"} {
+		p.Colour = colour
+		s.render(buf, now)
+		if got, want := [4]byte(buf[offset:offset+4]), rgba(colour); got != want {
+			t.Errorf("pixel shows %v after changing to %s, want %v", got, colour, want)
+		}
+	}
+}
+
+func TestRenderMono(t *testing.T) {
+	channels := 1
+	s := testStrip(3, Power{})
+	s.Channels = &channels
+	p, err := s.Add("a.service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Colour = "20c04000"
+	buf := make([]byte, 3)
+	s.render(buf, time.Now())
+	if got := buf[s.Position(p.Number)]; got != 0xc0 {
+		t.Errorf("single channel pixel shows %#02x, want the brightest channel 0xc0", got)
+	}
+}
+
+func TestDraw(t *testing.T) {
+	channels := 3
+	s := testStrip(2, Power{})
+	s.Channels = &channels
+	display := &halting{}
+	s.Display = display
+	pixel := s.addAt("a.service", 2)
+	pixel.SetColour("00ff0000")
+	if err := s.Draw(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(display.written) != 1 || !bytes.Equal(display.written[0], []byte{0, 0, 0, 0, 0xff, 0}) {
+		t.Errorf("wrote %v", display.written)
+	}
+}
@@ -0,0 +1,157 @@
+package strip
+
+import (
+	"fmt"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+// Segment reserves a range of pixels, counted from zero and inclusive, for a
+// category of services.
+type Segment struct {
+	Name  string
+	Start int
+	End   int
+	// Background is shown on pixels of the segment without a service.
+	Background string
+	// Separator, when set, is shown on the last pixel of the segment which is
+	// then not given to a service.
+	Separator string
+}
+
+func (seg *Segment) contains(number int) bool {
+	return number-1 >= seg.Start && number-1 <= seg.End
+}
+
+// AddSegment reserves seg on the strip. Segments must fit the strip and may
+// not overlap.
+func (s *Strip) AddSegment(seg Segment) error {
+	if seg.Start < 0 || seg.End < seg.Start || seg.End >= *s.Count {
+		return fmt.Errorf("segment %q: pixels %d-%d outside of the strip", seg.Name, seg.Start, seg.End)
+	}
+	for _, other := range s.Segments {
+		if other.Name == seg.Name {
+			return fmt.Errorf("segment %q defined twice", seg.Name)
+		}
+		if seg.Start <= other.End && other.Start <= seg.End {
+			return fmt.Errorf("segment %q overlaps %q", seg.Name, other.Name)
+		}
+	}
+	s.Segments = append(s.Segments, &seg)
+	return nil
+}
+
+func (s *Strip) segment(name string) *Segment {
+	for _, seg := range s.Segments {
+		if seg.Name == name {
+			return seg
+		}
+	}
+	return nil
+}
+
+func (s *Strip) segmentOf(number int) *Segment {
+	for _, seg := range s.Segments {
+		if seg.contains(number) {
+			return seg
+		}
+	}
+	return nil
+}
+
+// AddTo assigns unit the next free pixel of the named segment.
+func (s *Strip) AddTo(unit string, segment string) (*led.Led, error) {
+	seg := s.segment(segment)
+	if seg == nil {
+		return nil, fmt.Errorf("unknown segment %q", segment)
+	}
+	last := seg.End
+	if seg.Separator != "" {
+		last--
+	}
+	for number := seg.Start + 1; number <= last+1; number++ {
+		if !s.used(number) {
+			return s.addAt(unit, number), nil
+		}
+	}
+	return nil, fmt.Errorf("segment %q is full", segment)
+}
+
+func (s *Strip) used(number int) bool {
+	for _, pixels := range [][]*led.Led{s.Pixels, s.Overlays} {
+		for _, p := range pixels {
+			if p.Number == number {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reserve takes pixel number out of the pool given to units and returns it as
+// an overlay.
+func (s *Strip) Reserve(name string, number int) (*led.Led, error) {
+	if number < 1 || number > *s.Count {
+		return nil, fmt.Errorf("%s: pixel %d outside of the strip", name, number)
+	}
+	if s.used(number) {
+		return nil, fmt.Errorf("%s: pixel %d already in use", name, number)
+	}
+	l := &led.Led{}
+	l.Unit = name
+	l.Number = number
+	s.Overlays = append(s.Overlays, l)
+	return l, nil
+}
+
+// ReserveSegment reserves every free pixel of the named segment, but its
+// separator, as overlays in order.
+func (s *Strip) ReserveSegment(name string, segment string) ([]*led.Led, error) {
+	seg := s.segment(segment)
+	if seg == nil {
+		return nil, fmt.Errorf("%s: unknown segment %q", name, segment)
+	}
+	last := seg.End
+	if seg.Separator != "" {
+		last--
+	}
+	var pixels []*led.Led
+	for number := seg.Start + 1; number <= last+1; number++ {
+		if s.used(number) {
+			continue
+		}
+		l, err := s.Reserve(name, number)
+		if err != nil {
+			return nil, err
+		}
+		pixels = append(pixels, l)
+	}
+	return pixels, nil
+}
+
+func (s *Strip) addAt(unit string, number int) *led.Led {
+	l := &led.Led{}
+	l.Unit = unit
+	l.Number = number
+	s.Pixels = append(s.Pixels, l)
+	return l
+}
+
+// background returns the colour shown on pixel number when no service is
+// assigned to it.
+func (s *Strip) background(number int) string {
+	seg := s.segmentOf(number)
+	if seg == nil {
+		return ""
+	}
+	if seg.Separator != "" && number-1 == seg.End {
+		return seg.Separator
+	}
+	return seg.Background
+}
+
+// rgba unpacks an eight digit hex colour into its channel bytes.
+func rgba(colour string) [4]byte {
+	c, _ := led.ParseColour(colour)
+	return c
+}
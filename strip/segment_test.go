@@ -0,0 +1,66 @@
+package strip
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSegments(t *testing.T) {
+	count := 10
+	s := &Strip{Count: &count}
+	if err := s.AddSegment(Segment{Name: "network", Start: 0, End: 3, Separator: "ff000000"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddSegment(Segment{Name: "storage", Start: 3, End: 5}); err == nil {
+		t.Error("AddSegment() accepted an overlapping segment")
+	}
+	if err := s.AddSegment(Segment{Name: "storage", Start: 8, End: 10}); err == nil {
+		t.Error("AddSegment() accepted a segment past the end of the strip")
+	}
+
+	for i, want := range []int{1, 2, 3} {
+		p, err := s.AddTo("unit", "network")
+		if err != nil {
+			t.Fatalf("AddTo() #%d: %v", i, err)
+		}
+		if p.Number != want {
+			t.Errorf("AddTo() #%d got pixel %d, want %d", i, p.Number, want)
+		}
+	}
+	if _, err := s.AddTo("unit", "network"); err == nil {
+		t.Error("AddTo() gave away the separator pixel")
+	}
+	if got := s.background(4); got != "ff000000" {
+		t.Errorf("background(4) = %q, want the separator", got)
+	}
+
+	p, err := s.Add("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Number != 5 {
+		t.Errorf("Add() got pixel %d, want the first pixel after the segment", p.Number)
+	}
+}
+
+func TestReserveSegment(t *testing.T) {
+	count := 10
+	s := &Strip{Count: &count}
+	if err := s.AddSegment(Segment{Name: "clock", Start: 2, End: 6, Separator: "01010101"}); err != nil {
+		t.Fatal(err)
+	}
+	pixels, err := s.ReserveSegment("clock", "clock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []int
+	for _, p := range pixels {
+		numbers = append(numbers, p.Number)
+	}
+	if !reflect.DeepEqual(numbers, []int{3, 4, 5, 6}) {
+		t.Errorf("reserved %v, want 3-6", numbers)
+	}
+	if _, err := s.AddTo("a.service", "clock"); err == nil {
+		t.Error("unit added to a reserved segment")
+	}
+}
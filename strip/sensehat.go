@@ -0,0 +1,96 @@
+package strip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SenseHATPixels is the size of the Sense HAT's 8x8 matrix.
+	SenseHATPixels = 64
+	// senseHATName is what the Sense HAT's framebuffer calls itself.
+	senseHATName = "RPi-Sense FB"
+)
+
+// senseHAT is a Display for the Sense HAT's LED matrix, drawn through its
+// framebuffer as RGB565. Pixels run along each row from the top left, and
+// white, on RGBW strips, is added to the other channels.
+type senseHAT struct {
+	tx       func(w []byte) error
+	channels int
+	buf      []byte
+	zero     []byte
+}
+
+// newSenseHAT encodes frames of length pixels of channels and hands each to
+// tx.
+func newSenseHAT(tx func([]byte) error, length, channels int) *senseHAT {
+	return &senseHAT{
+		tx:       tx,
+		channels: channels,
+		buf:      make([]byte, 2*SenseHATPixels),
+		zero:     make([]byte, length*channels),
+	}
+}
+
+func (d *senseHAT) Write(frame []byte) (int, error) {
+	if len(frame)%d.channels != 0 || len(frame) > len(d.zero) {
+		return 0, errors.New("sensehat: frame does not fit the matrix")
+	}
+	for i := 0; i < len(frame)/d.channels; i++ {
+		px := frame[i*d.channels : (i+1)*d.channels]
+		var white int
+		if d.channels == 4 {
+			white = int(px[3])
+		}
+		r, g, b := clamp(int(px[0])+white), clamp(int(px[1])+white), clamp(int(px[2])+white)
+		binary.LittleEndian.PutUint16(d.buf[2*i:], uint16(r>>3<<11|g>>2<<5|b>>3))
+	}
+	return len(frame), d.tx(d.buf)
+}
+
+// Halt turns every pixel off.
+func (d *senseHAT) Halt() error {
+	_, err := d.Write(d.zero)
+	return err
+}
+
+// SenseHATDevice finds the Sense HAT's framebuffer device.
+func SenseHATDevice() (string, error) {
+	names, _ := filepath.Glob("/sys/class/graphics/fb*/name")
+	for _, name := range names {
+		b, err := os.ReadFile(name)
+		if err == nil && strings.TrimSpace(string(b)) == senseHATName {
+			return "/dev/" + filepath.Base(filepath.Dir(name)), nil
+		}
+	}
+	return "", errors.New("no Sense HAT framebuffer, is dtoverlay=rpi-sense loaded?")
+}
+
+// openSenseHAT opens the Sense HAT's framebuffer at p.Device, or wherever
+// it is found if that is empty. Hertz and Order do not apply.
+func openSenseHAT(p Port) (io.Closer, Display, error) {
+	if p.Length > SenseHATPixels {
+		return nil, nil, fmt.Errorf("the Sense HAT has %d pixels, not %d", SenseHATPixels, p.Length)
+	}
+	if p.Device == "" {
+		var err error
+		if p.Device, err = SenseHATDevice(); err != nil {
+			return nil, nil, err
+		}
+	}
+	f, err := os.OpenFile(p.Device, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	display := newSenseHAT(func(w []byte) error {
+		_, err := f.WriteAt(w, 0)
+		return err
+	}, p.Length, p.Channels)
+	return f, display, nil
+}
@@ -0,0 +1,25 @@
+package strip
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestSenseHATWrite(t *testing.T) {
+	var sent []byte
+	d := newSenseHAT(func(w []byte) error { sent = append([]byte(nil), w...); return nil }, 3, 4)
+	if _, err := d.Write([]byte{0xff, 0, 0, 0, 0, 0x80, 0, 0, 0, 0, 0, 0x10}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 128 {
+		t.Fatalf("sent %d bytes, want the whole 8x8 matrix", len(sent))
+	}
+	for i, want := range []uint16{0xf800, 0x0400, 0x1082, 0} {
+		if got := binary.LittleEndian.Uint16(sent[2*i:]); got != want {
+			t.Errorf("pixel %d = %#04x, want %#04x", i, got, want)
+		}
+	}
+	if _, err := d.Write(make([]byte, 16)); err == nil {
+		t.Error("Write() accepted a frame longer than the strip")
+	}
+}
@@ -0,0 +1,71 @@
+//go:build linux
+
+package strip
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"periph.io/x/conn/v3"
+	"periph.io/x/conn/v3/physic"
+	"periph.io/x/conn/v3/spi"
+	"periph.io/x/conn/v3/spi/spireg"
+	"periph.io/x/host/v3"
+)
+
+// openSPI opens the SPI port and an NRZ strip on it.
+func openSPI(p Port) (io.Closer, Display, error) {
+	if _, err := host.Init(); err != nil {
+		return nil, nil, errors.New("Unable to intialize the pariph.Host.")
+	}
+
+	port, err := spireg.Open(p.Device)
+	if err != nil {
+		return nil, nil, err
+	}
+	if l, ok := port.(conn.Limits); ok {
+		if size := nrzSize(p.Length, p.Channels, p.Hertz); l.MaxTxSize() < size {
+			port.Close()
+			return nil, nil, fmt.Errorf("%s: transfers of %d bytes needed, the port allows %d; raise spidev.bufsiz", p.Device, size, l.MaxTxSize())
+		}
+	}
+	c, err := port.Connect(3*physic.Frequency(p.Hertz)*physic.Hertz, spi.Mode3, 8)
+	if err != nil {
+		port.Close()
+		return nil, nil, err
+	}
+	display := newNRZ(func(w []byte) error { return c.Tx(w, nil) }, p.Length, p.Channels, p.Hertz, p.Order)
+	return port, display, nil
+}
+
+// tlcHertz is the SPI clock for TLC5947 chains, well inside the 30MHz the
+// chips take even down long cables.
+const tlcHertz = 1_000_000
+
+// openTLC5947 opens the SPI port and a chain of TLC5947 drivers on it, with
+// XLAT wired to the port's chip select so the end of each transfer latches
+// it. Hertz is the LEDs' and does not apply.
+func openTLC5947(p Port) (io.Closer, Display, error) {
+	if _, err := host.Init(); err != nil {
+		return nil, nil, errors.New("Unable to intialize the pariph.Host.")
+	}
+
+	port, err := spireg.Open(p.Device)
+	if err != nil {
+		return nil, nil, err
+	}
+	if l, ok := port.(conn.Limits); ok {
+		if size := tlcSize(p.Length, p.Channels); l.MaxTxSize() < size {
+			port.Close()
+			return nil, nil, fmt.Errorf("%s: transfers of %d bytes needed, the port allows %d; raise spidev.bufsiz", p.Device, size, l.MaxTxSize())
+		}
+	}
+	c, err := port.Connect(tlcHertz*physic.Hertz, spi.Mode0, 8)
+	if err != nil {
+		port.Close()
+		return nil, nil, err
+	}
+	display := newTLC5947(func(w []byte) error { return c.Tx(w, nil) }, p.Length, p.Channels, p.Order)
+	return port, display, nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package strip
+
+import (
+	"errors"
+	"io"
+)
+
+// openSPI fails, strips are only driven over SPI on Linux. Elsewhere use a
+// simulated Display with New.
+func openSPI(p Port) (io.Closer, Display, error) {
+	return nil, nil, errors.New("SPI strips are only supported on Linux, use simulate")
+}
+
+// openTLC5947 fails, like openSPI.
+func openTLC5947(p Port) (io.Closer, Display, error) {
+	return nil, nil, errors.New("SPI strips are only supported on Linux, use simulate")
+}
@@ -3,93 +3,515 @@ package strip
 import (
 	"bytes"
 	"errors"
-	"github.com/jar-o/limlog"
+	"fmt"
 	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"github.com/shift/systemd-status-leds/telemetry"
+	"go.uber.org/zap"
+	"io"
+	"math"
 	"periph.io/x/conn/v3/physic"
-	"periph.io/x/conn/v3/spi"
-	"periph.io/x/conn/v3/spi/spireg"
-	"periph.io/x/devices/v3/nrzled"
-	"periph.io/x/host/v3"
-	"strconv"
+	"sync"
 	"time"
 )
 
 var (
 	Loading = []byte{60, 60, 60, 60}
+
+	spiWrites  = telemetry.NewCounter("statusleds_spi_writes_total", "Frames written to the strip.")
+	spiErrors  = telemetry.NewCounter("statusleds_spi_write_errors_total", "Frames that failed to write.")
+	spiLatency = telemetry.NewGauge("statusleds_spi_write_seconds", "How long the last frame took to write.")
+)
+
+// Opts are the optional settings of a Strip.
+type Opts struct {
+	// Name tells strips apart in metrics.
+	Name  string
+	Power Power
+	// Interval between frames, DefaultInterval unless set.
+	Interval time.Duration
+	// Dither spreads quantization error across frames so dim colours average
+	// out to the requested value. It needs a short Interval to be invisible.
+	Dither bool
+	// Reverse numbers the pixels from the far end of the strip.
+	Reverse bool
+	// Offset shifts the first pixel along the strip.
+	Offset int
+	// Rotate moves every assignment along by one pixel each period, zero
+	// disables rotation. Periods are counted from RotateAnchor, or from
+	// when the strip is created if that is zero, so a fixed anchor gives
+	// the same layout across restarts.
+	Rotate       time.Duration
+	RotateAnchor time.Time
+	// Background fills the pixels no unit or segment uses.
+	Background Background
+	// WriteTimeout bounds how long a frame may take to write, zero waits
+	// for ever.
+	WriteTimeout time.Duration
+	// ReopenAfter reopens the SPI port after this many frames in a row
+	// failed to write, zero never does.
+	ReopenAfter int
+	// Hotplug lets Init succeed without the SPI port, retrying until it
+	// appears.
+	Hotplug bool
+	// Order is the order the LEDs take channels in, such as grb or grbw,
+	// green, red, blue then white unless set.
+	Order string
+	// Backend names what drives the strip in Backends, BackendSPI unless
+	// set.
+	Backend string
+	// Panel is the size of the matrix a hub75 backend draws on.
+	Panel Panel
+	// Balance corrects the strength of each channel.
+	Balance Balance
+	// FlashLimit caps how often pixels may flash, off unless set.
+	FlashLimit FlashLimit
+	// Realtime is the priority and CPUs the render loop, and the writer
+	// with a WriteTimeout, run at, normal and any unless set.
+	Realtime Realtime
+	// LatencyOutlier is how much later than expected a change or frame
+	// may reach the LEDs before it is logged, DefaultLatencyOutlier unless
+	// set.
+	LatencyOutlier time.Duration
+}
+
+const DefaultInterval = 5 * time.Second
+
+const (
+	BackendSPI  = "spi"
+	BackendUART = "uart"
+	BackendPWM  = "pwm"
+	// BackendTLC5947 drives discrete LEDs from TLC5947 chips on an SPI
+	// port, one output per pixel with a single channel or three with
+	// three.
+	BackendTLC5947 = "tlc5947"
+	// BackendSenseHAT draws on the Sense HAT's 8x8 matrix, up to 64
+	// pixels.
+	BackendSenseHAT = "sensehat"
+	// BackendHUB75 draws a grid on a HUB75 panel through a Flaschen
+	// Taschen server.
+	BackendHUB75 = "hub75"
+	// BackendHID drives a BlinkStick or blink(1) over USB HID.
+	BackendHID = "hid"
 )
 
+// Port is what a backend opens for a strip.
+type Port struct {
+	// Device is the SPI port, serial port, pin or address to open.
+	Device   string
+	Length   int
+	Channels int
+	Hertz    int
+	Order    string
+	Panel    Panel
+}
+
+// Backends opens a strip's port by backend: the device, such as an SPI bus
+// or a serial port, and the Display drawing on it.
+var Backends = map[string]func(p Port) (io.Closer, Display, error){
+	BackendSPI:  openSPI,
+	BackendUART: openUART,
+	BackendPWM:  openPin,
+
+	BackendTLC5947:  openTLC5947,
+	BackendSenseHAT: openSenseHAT,
+	BackendHUB75:    openHUB75,
+	BackendHID:      openHID,
+}
+
 type Strip struct {
-	Logger   *limlog.Limlog
-	SPIBus   *string
-	HRz      physic.Frequency
-	Channels *int
-	Count    *int
-	Display  *nrzled.Dev
-	Pixels   []*led.Led
-	spidev   spi.PortCloser
+	sync.RWMutex
+	Name       string
+	Logger     *loglimit.Logger
+	SPIBus     *string
+	HRz        physic.Frequency
+	Channels   *int
+	Count      *int
+	Display    Display
+	Pixels     []*led.Led
+	Overlays   []*led.Led // pixels driven by the daemon rather than a unit
+	Segments   []*Segment
+	Power      Power
+	Interval   time.Duration
+	Dither     bool
+	Reverse    bool
+	Offset     int
+	Rotate     time.Duration
+	Anchor     time.Time
+	Background Background
+	Balance    Balance
+	FlashLimit FlashLimit
+	Realtime   Realtime
+
+	WriteTimeout   time.Duration
+	ReopenAfter    int
+	LatencyOutlier time.Duration
+
+	spidev     io.Closer
+	open       func() (io.Closer, Display, error)
+	writer     *writer
+	failures   int
+	backoff    time.Duration
+	retryAt    time.Time
+	throttle   float64
+	brightness float64
+	fade       float64
+	residual   []float64
+	shown      []byte      // the frame limitFlashes last let through
+	swungAt    []time.Time // when each pixel last swung in luminance
+	rotation   int
+	writeErr   error
+	framed     time.Time  // when the last frame finished writing
+	last       []byte     // the last frame rendered, for Snapshot
+	writeMu    sync.Mutex // held for each write, and through a SelfTest
+	cache      renderCache
+	series     *stripMetrics
+	seen       time.Time     // when the newest change written changed
+	changed    []time.Time   // the changes of the frame being written
+	done       chan struct{} // closed by Close
+	closeOnce  sync.Once
+	closed     bool // set by Close, under writeMu
+}
+
+// Display is what frames are written to, an NRZ strip on SPI or a
+// simulation of one.
+type Display interface {
+	io.Writer
+	Halt() error
 }
 
-func Init(logger *limlog.Limlog, spibus *string, length *int, channels *int, hertz *int) (*Strip, error) {
+// Init opens the strip on spibus, the SPI port or, for other backends, their
+// device, driving its LEDs at hertz, or DefaultHertz if that is zero.
+func Init(logger *loglimit.Logger, spibus *string, length *int, channels *int, hertz *int, opts Opts) (*Strip, error) {
+	hz := *hertz
+	if hz == 0 {
+		hz = DefaultHertz
+	}
+	if err := ValidateHertz(hz); err != nil {
+		return nil, err
+	}
+	if opts.Order != "" {
+		if err := ValidateOrder(opts.Order, *channels); err != nil {
+			return nil, err
+		}
+	}
+	backend := opts.Backend
+	if backend == "" {
+		backend = BackendSPI
+	}
+	opener, ok := Backends[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", backend)
+	}
+	open := func() (io.Closer, Display, error) {
+		return opener(Port{Device: *spibus, Length: *length, Channels: *channels, Hertz: hz, Order: opts.Order, Panel: opts.Panel})
+	}
+	port, display, err := open()
+	if err != nil && opts.Hotplug {
+		logger.Warn("port missing, waiting for it", zap.String("backend", backend), zap.String("device", *spibus), zap.Error(err))
+		port, display = nil, noDevice{}
+	} else if err != nil {
+		return nil, err
+	}
+	strip, err := New(logger, display, length, channels, opts)
+	if err != nil {
+		if port != nil {
+			port.Close()
+		}
+		return nil, err
+	}
+	strip.SPIBus = spibus
+	strip.HRz = physic.Frequency(hz) * physic.Hertz
+	strip.spidev = port
+	strip.open = open
+	return strip, nil
+}
 
+// New drives display as a strip of length pixels, showing the loading
+// colour until the first frame.
+func New(logger *loglimit.Logger, display Display, length *int, channels *int, opts Opts) (*Strip, error) {
 	strip := &Strip{}
 	strip.Logger = logger
-	strip.SPIBus = spibus
+	strip.Display = display
 	strip.Count = length
 	strip.Channels = channels
-
-	if _, err := host.Init(); err != nil {
-		return nil, errors.New("Unable to intialize the pariph.Host.")
+	strip.Name = opts.Name
+	strip.Power = opts.Power
+	strip.Interval = opts.Interval
+	if strip.Interval <= 0 {
+		strip.Interval = DefaultInterval
 	}
-
-	var err error
-	if strip.spidev, err = spireg.Open(*strip.SPIBus); err != nil {
-		return nil, err
+	strip.Dither = opts.Dither
+	strip.Reverse = opts.Reverse
+	strip.Offset = opts.Offset
+	strip.Rotate = opts.Rotate
+	strip.Background = opts.Background
+	strip.Balance = opts.Balance
+	strip.FlashLimit = opts.FlashLimit
+	strip.Realtime = opts.Realtime
+	strip.WriteTimeout = opts.WriteTimeout
+	strip.ReopenAfter = opts.ReopenAfter
+	strip.LatencyOutlier = opts.LatencyOutlier
+	strip.Anchor = opts.RotateAnchor
+	if strip.Anchor.IsZero() {
+		strip.Anchor = time.Now()
 	}
-	//defer s.Close()
+	strip.throttle = 1
+	strip.brightness = 1
+	strip.fade = 1
+	strip.done = make(chan struct{})
 
-	if _, ok := strip.spidev.(spi.Pins); ok {
-		//		strip.Logger.Infof("Using pins: %i, %i ,%i", p.CLK(), p.MOSI(), p.MISO())
-	}
-	o := nrzled.Opts{
-		NumPixels: *strip.Count,
-		Channels:  *strip.Channels,
-		Freq:      2500 * physic.KiloHertz,
-	}
-	strip.Display, err = nrzled.NewSPI(strip.spidev, &o)
-	if err != nil {
+	if err := opts.Power.Validate(*length); err != nil {
 		return nil, err
 	}
-	_, _ = strip.Display.Write(bytes.Repeat(Loading, *strip.Count-1))
+
+	loading := bytes.Repeat(Loading, *strip.Count-1)
+	strip.limitPower(loading)
+	_, _ = strip.Display.Write(loading)
 
 	return strip, nil
 }
 
+// Add assigns unit the first free pixel outside of any segment.
 func (strip *Strip) Add(unit string) (pixel *led.Led, err error) {
-	led := &led.Led{}
-	led.Unit = unit
+	for number := 1; number <= *strip.Count; number++ {
+		if !strip.used(number) && strip.segmentOf(number) == nil {
+			return strip.addAt(unit, number), nil
+		}
+	}
+	return nil, errors.New("Already at one service per pixel.")
+}
+
+// rotationAt is how many pixels the assignments have moved along by t.
+func (s *Strip) rotationAt(t time.Time) int {
+	periods := int64(t.Sub(s.Anchor) / s.Rotate)
+	count := int64(*s.Count)
+	return int((periods%count + count) % count)
+}
 
-	if len(strip.Pixels) == *strip.Count {
-		return nil, errors.New("Already at one service per pixel.")
-	} else {
-		strip.Pixels = append(strip.Pixels, led)
-		led.Number = len(strip.Pixels)
-		return led, nil
+// Position maps a pixel Number onto its physical position along the strip,
+// counted from zero.
+func (s *Strip) Position(number int) int {
+	count := *s.Count
+	pos := ((number-1+s.Offset+s.rotation)%count + count) % count
+	if s.Reverse {
+		pos = count - 1 - pos
 	}
-	return led, nil
+	return pos
 }
 
+// UpdateLoop renders and writes a frame every Interval, until the strip is
+// closed.
 func (s *Strip) UpdateLoop() {
-	buf := make([]byte, 5*4)
+	Run(s)
+}
+
+// Run drives every strip from one frame clock ticking at the shortest of
+// their Intervals, until every strip is closed. Each strip is given the tick
+// nearest each of its own Intervals, and renders and writes in its own
+// goroutine, skipping ticks while its last frame is still being written, so a
+// slow bus delays only itself.
+func Run(strips ...*Strip) {
+	interval := strips[0].Interval
+	ticks := make([]chan time.Time, len(strips))
+	for i, s := range strips {
+		interval = min(interval, s.Interval)
+		ticks[i] = make(chan time.Time, 1)
+		go s.renderLoop(ticks[i])
+	}
+	due := make([]time.Time, len(strips))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := time.Now(); ; now = <-ticker.C {
+		open := 0
+		for i, tick := range ticks {
+			if strips[i].isClosed() {
+				continue
+			}
+			open++
+			// Ticks come late rather than early, so half a tick early is
+			// the nearest one.
+			if now.Add(interval / 2).Before(due[i]) {
+				continue
+			}
+			due[i] = now.Add(strips[i].Interval)
+			select {
+			case tick <- now:
+			default:
+			}
+		}
+		if open == 0 {
+			return
+		}
+	}
+}
+
+// renderLoop renders and writes a frame on each tick, until the strip is
+// closed.
+func (s *Strip) renderLoop(ticks <-chan time.Time) {
+	channels := *s.Channels
+	buf := make([]byte, *s.Count*channels)
+	out := make([]byte, len(buf))
+	s.residual = make([]float64, len(buf))
+	s.enter(s.Realtime)
 	for {
-		for _, p := range s.Pixels {
-			offset := (p.Number - 1) * 4
-			rgba, _ := strconv.ParseUint(p.Colour, 16, 32)
-			buf[offset] = byte(rgba >> 24)
-			buf[offset+1] = byte(rgba >> 16)
-			buf[offset+2] = byte(rgba >> 8)
-			buf[offset+3] = byte(rgba)
+		select {
+		case <-s.done:
+			return
+		case now := <-ticks:
+			s.frame(buf, out, now)
+		}
+	}
+}
+
+func (s *Strip) isClosed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops rendering, blanks the strip, halts the display and closes its
+// port. Frames and self tests after it write nothing.
+func (s *Strip) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.done != nil {
+			close(s.done)
+		}
+		s.writeMu.Lock()
+		defer s.writeMu.Unlock()
+		s.closed = true
+		stuck := s.writer != nil && s.writer.busy
+		if _, missing := s.Display.(noDevice); !missing {
+			err = s.displayWrite(make([]byte, *s.Count**s.Channels))
+			err = errors.Join(err, s.Display.Halt())
+		}
+		if s.writer != nil {
+			s.writer.close()
+			s.writer = nil
+		}
+		if s.spidev != nil {
+			if stuck {
+				// Closing may block behind a stuck write.
+				go s.spidev.Close()
+			} else {
+				err = errors.Join(err, s.spidev.Close())
+			}
+			s.spidev = nil
+		}
+	})
+	return err
+}
+
+// write writes a frame to the display, tracing, timing and counting it.
+func (s *Strip) write(frame []byte) error {
+	m := s.metrics()
+	span := telemetry.StartSpan("spi.write", m.attrs...)
+	start := time.Now()
+	err := s.displayWrite(frame)
+	m.latency.Set(time.Since(start).Seconds())
+	span.End(err)
+	m.writes.Inc()
+	if err != nil {
+		m.errors.Inc()
+	}
+	return err
+}
+
+// SelfTest sweeps a single fully lit pixel along the whole strip, once for
+// each channel, showing each position for step. It returns the number of
+// frames that failed to write and the last error.
+func (s *Strip) SelfTest(step time.Duration) (failed int, err error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.closed {
+		return 0, ErrClosed
+	}
+	channels := *s.Channels
+	frame := make([]byte, *s.Count*channels)
+	for c := 0; c < channels; c++ {
+		for pos := 0; pos < *s.Count; pos++ {
+			clear(frame)
+			frame[pos*channels+c] = 255
+			s.limitPower(frame)
+			if werr := s.write(frame); werr != nil {
+				failed++
+				err = werr
+			}
+			time.Sleep(step)
+		}
+	}
+	return failed, err
+}
+
+// WriteErr returns the error of the last frame written, if any.
+func (s *Strip) WriteErr() error {
+	s.RLock()
+	defer s.RUnlock()
+	return s.writeErr
+}
+
+// Framed returns when the last frame finished writing, well or not, zero
+// before the first. It falls behind when rendering or writing is stuck.
+func (s *Strip) Framed() time.Time {
+	s.RLock()
+	defer s.RUnlock()
+	return s.framed
+}
+
+// SetThrottle sets the brightness factor, between 0 and 1, used to keep the
+// strip from heating the board underneath it.
+func (s *Strip) SetThrottle(f float64) {
+	s.Lock()
+	defer s.Unlock()
+	s.throttle = f
+}
+
+// SetBrightness sets the global brightness factor between 0 and 1.
+func (s *Strip) SetBrightness(f float64) {
+	s.Lock()
+	defer s.Unlock()
+	s.brightness = f
+}
+
+func (s *Strip) Brightness() float64 {
+	s.RLock()
+	defer s.RUnlock()
+	return s.brightness
+}
+
+// SetFade sets the factor, between 0 and 1, used to fade the strip in and
+// out of sleep.
+func (s *Strip) SetFade(f float64) {
+	s.Lock()
+	defer s.Unlock()
+	s.fade = f
+}
+
+// encode scales frame by the brightness, throttle and power budget into out. With Dither
+// set, the rounding error of each channel is carried into the next frame.
+func (s *Strip) encode(frame, out []byte) {
+	s.RLock()
+	f := s.throttle * s.brightness * s.fade
+	s.RUnlock()
+	f *= s.powerScale(frame, f)
+	balance, channels := [4]float64{1, 1, 1, 1}, 1
+	if s.Balance != (Balance{}) {
+		channels = *s.Channels
+		balance = s.Balance.factors(channels)
+	}
+	for i, b := range frame {
+		v := float64(b) * f * balance[i%channels]
+		if s.Dither {
+			v += s.residual[i]
+		}
+		q := math.Min(math.Max(math.Round(v), 0), 255)
+		if s.Dither {
+			s.residual[i] = v - q
 		}
-		_, _ = s.Display.Write(buf)
-		time.Sleep(5 * time.Second)
+		out[i] = byte(q)
 	}
 }
@@ -0,0 +1,332 @@
+package strip
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"go.uber.org/zap"
+)
+
+func testStrip(count int, power Power) *Strip {
+	return &Strip{
+		Logger: loglimit.New(limlog.NewLimlogWithZap(zap.NewNop())),
+		Count:  &count,
+		Power:  power,
+
+		throttle:   1,
+		brightness: 1,
+		fade:       1,
+	}
+}
+
+func TestLimitPowerUnderBudget(t *testing.T) {
+	s := testStrip(5, Power{MaxMilliamps: 500, MilliampsPerChannel: 20, IdleMilliamps: 1})
+	buf := bytes.Repeat([]byte{60}, 20)
+	want := append([]byte(nil), buf...)
+	s.limitPower(buf)
+	if !bytes.Equal(buf, want) {
+		t.Errorf("frame changed under budget: got %v, want %v", buf, want)
+	}
+}
+
+func TestLimitPowerOverBudget(t *testing.T) {
+	s := testStrip(5, Power{MaxMilliamps: 100, MilliampsPerChannel: 20, IdleMilliamps: 1})
+	buf := bytes.Repeat([]byte{255}, 20)
+	if draw := s.Milliamps(buf); draw != 405 {
+		t.Fatalf("Milliamps() = %d, want 405", draw)
+	}
+	s.limitPower(buf)
+	if draw := s.Milliamps(buf); draw > 100 {
+		t.Errorf("Milliamps() after limit = %d, want at most 100", draw)
+	}
+	if buf[0] == 0 {
+		t.Errorf("frame blanked instead of scaled")
+	}
+}
+
+func TestPowerValidateBudgetAtIdle(t *testing.T) {
+	for _, max := range []int{4, 5} {
+		p := Power{MaxMilliamps: max, MilliampsPerChannel: 20, IdleMilliamps: 1}
+		if err := p.Validate(5); err == nil {
+			t.Errorf("Validate() with max_milliamps %d accepted a budget at or below idle", max)
+		}
+	}
+	p := Power{MaxMilliamps: 6, MilliampsPerChannel: 20, IdleMilliamps: 1}
+	if err := p.Validate(5); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestPowerValidateRejectsBadModel(t *testing.T) {
+	for _, p := range []Power{
+		{MaxMilliamps: -1, MilliampsPerChannel: 20, IdleMilliamps: 1},
+		{MilliampsPerChannel: 0, IdleMilliamps: 1},
+		{MilliampsPerChannel: -20, IdleMilliamps: 1},
+		{MilliampsPerChannel: 20, IdleMilliamps: -1},
+	} {
+		if err := p.Validate(5); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+}
+
+func TestLimitPowerDisabled(t *testing.T) {
+	s := testStrip(5, Power{MilliampsPerChannel: 20, IdleMilliamps: 1})
+	buf := bytes.Repeat([]byte{255}, 20)
+	s.limitPower(buf)
+	if !bytes.Equal(buf, bytes.Repeat([]byte{255}, 20)) {
+		t.Errorf("frame changed with the limit disabled: %v", buf)
+	}
+}
+
+func TestEncodeDitherAverages(t *testing.T) {
+	s := testStrip(1, Power{MilliampsPerChannel: 20, IdleMilliamps: 1})
+	s.Dither = true
+	s.throttle = 0.1
+	s.residual = make([]float64, 1)
+	frame, out := []byte{5}, make([]byte, 1)
+	var sum int
+	for i := 0; i < 100; i++ {
+		s.encode(frame, out)
+		sum += int(out[0])
+	}
+	if sum != 50 {
+		t.Errorf("sum over 100 frames = %d, want 50", sum)
+	}
+}
+
+func TestEncodeBalance(t *testing.T) {
+	channels := 3
+	s := testStrip(2, Power{MilliampsPerChannel: 20, IdleMilliamps: 1})
+	s.Channels = &channels
+	s.Balance = Balance{Red: 0.5, Blue: 1}
+	out := make([]byte, 6)
+	s.encode(bytes.Repeat([]byte{200}, 6), out)
+	if want := []byte{100, 200, 200, 100, 200, 200}; !bytes.Equal(out, want) {
+		t.Errorf("balanced white = %v, want %v", out, want)
+	}
+	if err := (Balance{Blue: 1.2}).Validate(); err == nil {
+		t.Error("Validate() accepted a factor above 1")
+	}
+}
+
+func TestPosition(t *testing.T) {
+	count := 5
+	for _, tc := range []struct {
+		reverse          bool
+		offset, rotation int
+		number, want     int
+	}{
+		{number: 1, want: 0},
+		{number: 5, want: 4},
+		{reverse: true, number: 1, want: 4},
+		{offset: 2, number: 4, want: 0},
+		{offset: -1, number: 1, want: 4},
+		{rotation: 1, number: 5, want: 0},
+		{reverse: true, offset: 1, number: 1, want: 3},
+	} {
+		s := &Strip{Count: &count, Reverse: tc.reverse, Offset: tc.offset, rotation: tc.rotation}
+		if got := s.Position(tc.number); got != tc.want {
+			t.Errorf("Position(%d) with %+v = %d, want %d", tc.number, tc, got, tc.want)
+		}
+	}
+}
+
+// frames records the frames written to it, failing every other one.
+type frames struct {
+	written [][]byte
+}
+
+func (f *frames) Write(b []byte) (int, error) {
+	f.written = append(f.written, append([]byte(nil), b...))
+	if len(f.written)%2 == 0 {
+		return 0, errors.New("write failed")
+	}
+	return len(b), nil
+}
+
+func (f *frames) Halt() error { return nil }
+
+func TestSelfTest(t *testing.T) {
+	s := testStrip(3, Power{})
+	channels := 4
+	s.Channels = &channels
+	display := &frames{}
+	s.Display = display
+	failed, err := s.SelfTest(0)
+	if len(display.written) != 12 {
+		t.Fatalf("wrote %d frames, want 12", len(display.written))
+	}
+	if failed != 6 || err == nil {
+		t.Errorf("failed = %d, %v, want 6 and an error", failed, err)
+	}
+	for i, frame := range display.written {
+		lit := 0
+		for _, b := range frame {
+			if b != 0 {
+				lit++
+			}
+		}
+		if lit != 1 || frame[(i%3)*4+i/3] != 255 {
+			t.Errorf("frame %d = %v", i, frame)
+		}
+	}
+}
+
+func TestRotationAt(t *testing.T) {
+	count := 5
+	anchor := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &Strip{Count: &count, Rotate: time.Hour, Anchor: anchor}
+	for _, tc := range []struct {
+		at   time.Duration
+		want int
+	}{
+		{0, 0},
+		{59 * time.Minute, 0},
+		{time.Hour, 1},
+		{7 * time.Hour, 2},
+		{-time.Hour, 4},
+	} {
+		if got := s.rotationAt(anchor.Add(tc.at)); got != tc.want {
+			t.Errorf("rotation %s after the anchor = %d, want %d", tc.at, got, tc.want)
+		}
+	}
+}
+
+// counting counts the frames written to it, after waiting for block if set.
+type counting struct {
+	n     atomic.Int32
+	block chan struct{}
+}
+
+func (c *counting) Write(b []byte) (int, error) {
+	if c.block != nil {
+		<-c.block
+	}
+	c.n.Add(1)
+	return len(b), nil
+}
+
+func (c *counting) Halt() error { return nil }
+
+func TestRunSlowStrip(t *testing.T) {
+	channels := 3
+	fast, slow := testStrip(2, Power{}), testStrip(2, Power{})
+	fastDisplay, slowDisplay := &counting{}, &counting{block: make(chan struct{})}
+	defer close(slowDisplay.block)
+	for _, s := range []struct {
+		*Strip
+		d Display
+	}{{fast, fastDisplay}, {slow, slowDisplay}} {
+		s.Channels, s.Display, s.Interval = &channels, s.d, time.Millisecond
+	}
+	go Run(fast, slow)
+	time.Sleep(50 * time.Millisecond)
+	if n := fastDisplay.n.Load(); n < 5 {
+		t.Errorf("fast strip wrote %d frames while the slow one was stuck, want at least 5", n)
+	}
+}
+
+// halting records the frames written to it and whether it was halted.
+type halting struct {
+	mu      sync.Mutex
+	written [][]byte
+	halted  bool
+}
+
+func (h *halting) Write(b []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.written = append(h.written, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (h *halting) Halt() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.halted = true
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	channels := 3
+	s := testStrip(2, Power{})
+	s.Channels, s.Interval, s.WriteTimeout = &channels, time.Millisecond, time.Second
+	s.done = make(chan struct{})
+	display := &halting{}
+	s.Display = display
+	port := closer{make(chan struct{})}
+	s.spidev = port
+	s.addAt("a.service", 1).SetColour("ff000000")
+
+	stopped := make(chan struct{})
+	go func() {
+		Run(s)
+		close(stopped)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run still going after Close")
+	}
+	select {
+	case <-port.closed:
+	default:
+		t.Error("port not closed")
+	}
+	display.mu.Lock()
+	frames, halted := len(display.written), display.halted
+	last := display.written[frames-1]
+	display.mu.Unlock()
+	if !halted || !bytes.Equal(last, make([]byte, 6)) {
+		t.Errorf("after Close halted = %v, last frame %v, want a blank frame", halted, last)
+	}
+	time.Sleep(10 * time.Millisecond)
+	display.mu.Lock()
+	if len(display.written) != frames {
+		t.Errorf("wrote %d frames after Close", len(display.written)-frames)
+	}
+	display.mu.Unlock()
+	if _, err := s.SelfTest(0); err != ErrClosed {
+		t.Errorf("SelfTest() after Close = %v, want ErrClosed", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left over", runtime.NumGoroutine()-before)
+		}
+	}
+}
+
+func TestRunKeepsEachInterval(t *testing.T) {
+	channels := 3
+	fast, slow := testStrip(2, Power{}), testStrip(2, Power{})
+	fastDisplay, slowDisplay := &counting{}, &counting{}
+	fast.Channels, fast.Display, fast.Interval = &channels, fastDisplay, 5*time.Millisecond
+	slow.Channels, slow.Display, slow.Interval = &channels, slowDisplay, 50*time.Millisecond
+	go Run(fast, slow)
+	time.Sleep(175 * time.Millisecond)
+	fast.Close()
+	slow.Close()
+	if n := slowDisplay.n.Load(); n < 3 || n > 5 {
+		t.Errorf("slow strip wrote %d frames in 175ms, want one every 50ms", n)
+	}
+	if n := fastDisplay.n.Load(); n < 15 {
+		t.Errorf("fast strip wrote %d frames in 175ms, want one every 5ms", n)
+	}
+}
@@ -0,0 +1,49 @@
+package strip
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Terminal is a Display drawing the strip as a line of coloured blocks on a
+// truecolour terminal, for running without hardware.
+type Terminal struct {
+	Out      io.Writer
+	Channels int
+}
+
+func (t *Terminal) Write(frame []byte) (int, error) {
+	var b strings.Builder
+	b.WriteString("\r")
+	for i := 0; i+t.Channels <= len(frame); i += t.Channels {
+		px := frame[i : i+t.Channels]
+		var white int
+		switch t.Channels {
+		case 1:
+			px = []byte{0, 0, 0}
+			white = int(frame[i])
+		case 4:
+			white = int(px[3])
+		}
+		fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm██", clamp(int(px[0])+white), clamp(int(px[1])+white), clamp(int(px[2])+white))
+	}
+	b.WriteString("\x1b[0m")
+	if _, err := io.WriteString(t.Out, b.String()); err != nil {
+		return 0, err
+	}
+	return len(frame), nil
+}
+
+// Halt ends the line.
+func (t *Terminal) Halt() error {
+	_, err := io.WriteString(t.Out, "\x1b[0m\n")
+	return err
+}
+
+func clamp(v int) int {
+	if v > 255 {
+		return 255
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package strip
+
+import (
+	"errors"
+	"strings"
+)
+
+// tlcChannels is the number of outputs on each TLC5947.
+const tlcChannels = 24
+
+// tlc5947 is a Display for discrete LEDs on a chain of TLC5947 24-channel
+// constant-current drivers. Each pixel drives channels outputs in turn from
+// the first chip's output 0, one for single-colour LEDs or three, in order,
+// for RGB ones. Every output gets 12 bits, and the whole chain is shifted
+// out last output first, so the transfer ends with the first chip.
+type tlc5947 struct {
+	tx       func(w []byte) error
+	channels int
+	order    [4]int // the frame channel on each of a pixel's outputs
+	buf      []byte
+	zero     []byte
+}
+
+// newTLC5947 encodes frames of length pixels of channels, one or three,
+// taking RGB pixels' outputs in order, red, green then blue if empty, and
+// hands each to tx.
+func newTLC5947(tx func([]byte) error, length, channels int, order string) *tlc5947 {
+	if order == "" {
+		order = "rgb"
+	}
+	chips := (length*channels + tlcChannels - 1) / tlcChannels
+	d := &tlc5947{
+		tx:       tx,
+		channels: channels,
+		buf:      make([]byte, chips*tlcChannels*12/8),
+		zero:     make([]byte, length*channels),
+	}
+	for i := 0; i < channels && i < len(order); i++ {
+		d.order[i] = strings.IndexByte("rgbw", order[i])
+	}
+	return d
+}
+
+// tlcSize is the size of the SPI transfers for length pixels of channels.
+func tlcSize(length, channels int) int {
+	return len(newTLC5947(nil, length, channels, "").buf)
+}
+
+func (d *tlc5947) Write(frame []byte) (int, error) {
+	if len(frame)%d.channels != 0 || len(frame) > len(d.zero) {
+		return 0, errors.New("tlc5947: frame does not fit the chain")
+	}
+	clear(d.buf)
+	last := len(d.buf)*8/12 - 1
+	for i := 0; i < len(frame); i += d.channels {
+		for c := 0; c < d.channels; c++ {
+			putTLC(d.buf, last-(i+c), frame[i+d.order[c]])
+		}
+	}
+	return len(frame), d.tx(d.buf)
+}
+
+// Halt turns every output off.
+func (d *tlc5947) Halt() error {
+	_, err := d.Write(d.zero)
+	return err
+}
+
+// putTLC writes v, scaled to 12 bits, as the n-th 12-bit value of buf, most
+// significant bit first.
+func putTLC(buf []byte, n int, v byte) {
+	w := uint16(v)<<4 | uint16(v)>>4
+	i := n * 12 / 8
+	if n%2 == 0 {
+		buf[i] = byte(w >> 4)
+		buf[i+1] = buf[i+1]&0x0f | byte(w<<4)
+	} else {
+		buf[i] = buf[i]&0xf0 | byte(w>>8)
+		buf[i+1] = byte(w)
+	}
+}
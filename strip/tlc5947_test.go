@@ -0,0 +1,53 @@
+package strip
+
+import "testing"
+
+// tlcValue reads output n of a chain from the transfer buf.
+func tlcValue(buf []byte, n int) uint16 {
+	k := len(buf)*8/12 - 1 - n
+	i := k * 12 / 8
+	if k%2 == 0 {
+		return uint16(buf[i])<<4 | uint16(buf[i+1])>>4
+	}
+	return uint16(buf[i]&0x0f)<<8 | uint16(buf[i+1])
+}
+
+func TestTLC5947RGB(t *testing.T) {
+	var sent []byte
+	d := newTLC5947(func(w []byte) error { sent = append([]byte(nil), w...); return nil }, 9, 3, "bgr")
+	frame := make([]byte, 27)
+	copy(frame, []byte{0xff, 0x80, 0x00})
+	frame[24], frame[26] = 0x10, 0x01
+	if _, err := d.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	// 27 outputs need two chips.
+	if len(sent) != 72 {
+		t.Fatalf("sent %d bytes, want 72", len(sent))
+	}
+	for n, want := range map[int]uint16{0: 0, 1: 0x808, 2: 0xfff, 24: 0x010, 26: 0x101, 27: 0} {
+		if got := tlcValue(sent, n); got != want {
+			t.Errorf("output %d = %#03x, want %#03x", n, got, want)
+		}
+	}
+}
+
+func TestTLC5947Mono(t *testing.T) {
+	var sent []byte
+	d := newTLC5947(func(w []byte) error { sent = append([]byte(nil), w...); return nil }, 24, 1, "")
+	frame := make([]byte, 24)
+	frame[0], frame[23] = 0xff, 0x40
+	if _, err := d.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	// The last output is shifted out first.
+	if sent[0] != 0x40 || sent[1]>>4 != 0x4 {
+		t.Errorf("transfer starts % x, want output 23", sent[:2])
+	}
+	if len(sent) != 36 || tlcValue(sent, 0) != 0xfff || tlcValue(sent, 23) != 0x404 || tlcValue(sent, 1) != 0 {
+		t.Errorf("sent % x", sent)
+	}
+	if _, err := d.Write(make([]byte, 25)); err == nil {
+		t.Error("Write() accepted a frame longer than the chain")
+	}
+}
@@ -0,0 +1,79 @@
+package strip
+
+import (
+	"errors"
+	"strings"
+)
+
+// uart is a Display for NRZ LEDs on a UART sending 7N1 at three times their
+// bit rate with its TX line inverted. Each character, start bit and stop bit
+// included, carries three LED bits as the same thirds nrz sends: high, the
+// bit, then low.
+type uart struct {
+	tx       func(w []byte) error
+	channels int
+	order    [4]int // the frame channel sent in each position
+	buf      []byte
+	zero     []byte
+}
+
+// newUART encodes frames of length pixels of channels taking them in order,
+// green, red, blue then white if empty, and hands each to tx.
+func newUART(tx func([]byte) error, length, channels int, order string) *uart {
+	if order == "" {
+		order = DefaultOrder + "w"
+	}
+	d := &uart{
+		tx:       tx,
+		channels: channels,
+		buf:      make([]byte, (8*length*channels+2)/3),
+		zero:     make([]byte, length*channels),
+	}
+	for i := 0; i < channels && i < len(order); i++ {
+		d.order[i] = strings.IndexByte("rgbw", order[i])
+	}
+	return d
+}
+
+func (d *uart) Write(frame []byte) (int, error) {
+	if len(frame)%d.channels != 0 || len(frame) > len(d.zero) {
+		return 0, errors.New("uart: frame does not fit the strip")
+	}
+	n := 0
+	for i := 0; i < len(frame); i += d.channels {
+		px := frame[i : i+d.channels]
+		for c := 0; c < d.channels; c++ {
+			b := px[d.order[c]]
+			for j := 7; j >= 0; j-- {
+				putUART(d.buf, n, b>>j&1 == 1)
+				n++
+			}
+		}
+	}
+	// Pad the last character with zeros, the strip ignores bits past its
+	// last pixel.
+	for ; n%3 != 0; n++ {
+		putUART(d.buf, n, false)
+	}
+	return len(frame), d.tx(d.buf[:n/3])
+}
+
+// Halt turns every pixel off.
+func (d *uart) Halt() error {
+	_, err := d.Write(d.zero)
+	return err
+}
+
+// putUART sets LED bit n of the stream in buf. Inverted, the start bit is
+// the high third of the first LED bit and the stop bit the low third of the
+// last, so the seven data bits, least significant first and inverted, are
+// !b0 1 0 !b1 1 0 !b2.
+func putUART(buf []byte, n int, one bool) {
+	i, pos := n/3, n%3
+	if pos == 0 {
+		buf[i] = 0b0010010
+	}
+	if !one {
+		buf[i] |= 1 << (3 * pos)
+	}
+}
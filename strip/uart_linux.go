@@ -0,0 +1,45 @@
+//go:build linux
+
+package strip
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openUART opens the serial port at p.Device for an NRZ strip. The port runs
+// 7N1 at three times the LEDs' hertz; its TX line must be inverted, by a
+// transistor or level shifter or in the UART itself, to drive the strip.
+func openUART(p Port) (io.Closer, Display, error) {
+	f, err := os.OpenFile(p.Device, os.O_WRONLY|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := unix.IoctlGetTermios(int(f.Fd()), unix.TCGETS2)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", p.Device, err)
+	}
+	baud := uint32(3 * p.Hertz)
+	t.Iflag = 0
+	t.Oflag = 0
+	t.Lflag = 0
+	t.Cflag = unix.BOTHER | unix.CS7 | unix.CLOCAL | unix.CREAD
+	t.Ispeed, t.Ospeed = baud, baud
+	if err := unix.IoctlSetTermios(int(f.Fd()), unix.TCSETS2, t); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: setting %d baud: %w", p.Device, baud, err)
+	}
+	display := newUART(func(w []byte) error {
+		if _, err := f.Write(w); err != nil {
+			return err
+		}
+		// Wait for the frame to leave the port, the idle line that
+		// follows latches it.
+		return unix.IoctlSetInt(int(f.Fd()), unix.TCSBRK, 1)
+	}, p.Length, p.Channels, p.Order)
+	return f, display, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package strip
+
+import (
+	"errors"
+	"io"
+)
+
+// openUART fails, strips are only driven over a UART on Linux.
+func openUART(p Port) (io.Closer, Display, error) {
+	return nil, nil, errors.New("UART strips are only supported on Linux, use simulate")
+}
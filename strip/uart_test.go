@@ -0,0 +1,59 @@
+package strip
+
+import "testing"
+
+// line is what an inverted 7N1 UART puts on the wire for chars.
+func line(chars []byte) []bool {
+	var bits []bool
+	for _, c := range chars {
+		bits = append(bits, true)
+		for i := 0; i < 7; i++ {
+			bits = append(bits, c>>i&1 == 0)
+		}
+		bits = append(bits, false)
+	}
+	return bits
+}
+
+func TestUARTWrite(t *testing.T) {
+	var sent []byte
+	d := newUART(func(w []byte) error { sent = append([]byte(nil), w...); return nil }, 2, 3, "")
+	if _, err := d.Write([]byte{0x80, 0x01, 0x00, 0, 0, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+	// Green first, then red and blue.
+	want := []byte{0x01, 0x80, 0x00, 0, 0, 0xff}
+	if len(sent) != 16 {
+		t.Fatalf("sent %d characters, want 16", len(sent))
+	}
+	bits := line(sent)
+	for i, b := range want {
+		for j := 0; j < 8; j++ {
+			third := bits[3*(8*i+j):]
+			one := b>>(7-j)&1 == 1
+			if !third[0] || third[1] != one || third[2] {
+				t.Fatalf("bit %d of byte %d is %v, want high, %v, low", j, i, third[:3], one)
+			}
+		}
+	}
+	if _, err := d.Write(make([]byte, 9)); err == nil {
+		t.Error("Write() accepted a frame longer than the strip")
+	}
+}
+
+func TestUARTPads(t *testing.T) {
+	var sent []byte
+	d := newUART(func(w []byte) error { sent = append([]byte(nil), w...); return nil }, 1, 4, "grbw")
+	if err := d.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	// 32 bits round up to 11 characters, the last padded with zeros.
+	if len(sent) != 11 {
+		t.Fatalf("sent %d characters, want 11", len(sent))
+	}
+	for i, c := range sent {
+		if c != 0b1011011 {
+			t.Errorf("character %d is %07b, want zeros", i, c)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package strip
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shift/systemd-status-leds/telemetry"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrWriteTimeout is returned for a frame not written within the
+	// strip's WriteTimeout.
+	ErrWriteTimeout = errors.New("write timed out")
+	// ErrWriteStalled is returned for frames dropped while an earlier one
+	// is still being written.
+	ErrWriteStalled = errors.New("write stalled behind an earlier frame")
+	// ErrNoDevice is returned for frames written while the SPI port is
+	// missing.
+	ErrNoDevice = errors.New("SPI device missing")
+	// ErrClosed is returned for self tests of a closed strip.
+	ErrClosed = errors.New("strip closed")
+
+	spiReopens  = telemetry.NewCounter("statusleds_spi_reopens_total", "Times the SPI port was reopened after failed writes.")
+	spiFailures = telemetry.NewGauge("statusleds_spi_consecutive_failures", "Frames that failed to write in a row.")
+)
+
+// writer writes frames to a display from its own goroutine, so a write that
+// never returns can be timed out. Frames are copied into pending, which the
+// goroutine owns while busy.
+type writer struct {
+	display Display
+	frames  chan struct{}
+	results chan error
+	pending []byte
+	busy    bool
+	timer   *time.Timer
+}
+
+// newWriter starts a writer, calling enter first on its goroutine.
+func newWriter(display Display, size int, enter func()) *writer {
+	w := &writer{
+		display: display,
+		frames:  make(chan struct{}, 1),
+		results: make(chan error, 1),
+		pending: make([]byte, size),
+		timer:   time.NewTimer(time.Hour),
+	}
+	w.timer.Stop()
+	go func() {
+		enter()
+		for range w.frames {
+			_, err := w.display.Write(w.pending)
+			w.results <- err
+		}
+	}()
+	return w
+}
+
+// close ends the writer's goroutine once any write in progress returns.
+func (w *writer) close() {
+	close(w.frames)
+	w.timer.Stop()
+}
+
+// write writes frame, waiting at most timeout for it.
+func (w *writer) write(frame []byte, timeout time.Duration) error {
+	if w.busy {
+		select {
+		case <-w.results:
+			w.busy = false
+		default:
+			return ErrWriteStalled
+		}
+	}
+	w.pending = append(w.pending[:0], frame...)
+	w.busy = true
+	w.frames <- struct{}{}
+	w.timer.Reset(timeout)
+	select {
+	case err := <-w.results:
+		w.busy = false
+		if !w.timer.Stop() {
+			<-w.timer.C
+		}
+		return err
+	case <-w.timer.C:
+		return ErrWriteTimeout
+	}
+}
+
+// displayWrite writes frame to the display, through a writer if the strip
+// has a WriteTimeout. The caller holds writeMu.
+func (s *Strip) displayWrite(frame []byte) error {
+	if s.WriteTimeout <= 0 {
+		_, err := s.Display.Write(frame)
+		return err
+	}
+	if s.writer == nil {
+		s.writer = newWriter(s.Display, len(frame), func() { s.enter(s.Realtime) })
+	}
+	return s.writer.write(frame, s.WriteTimeout)
+}
+
+// maxBackoff is the longest wait between attempts to open a missing SPI port.
+const maxBackoff = time.Minute
+
+// noDevice stands in for the display while the SPI port is missing.
+type noDevice struct{}
+
+func (noDevice) Write([]byte) (int, error) { return 0, ErrNoDevice }
+func (noDevice) Halt() error               { return nil }
+
+// failed counts consecutive failed frames, reopening the SPI port after
+// ReopenAfter of them, or while it is missing, backing off between failed
+// attempts. The caller holds writeMu.
+func (s *Strip) failed(err error) {
+	m := s.metrics()
+	if err == nil {
+		s.failures = 0
+		m.failures.Set(0)
+		return
+	}
+	s.failures++
+	m.failures.Set(float64(s.failures))
+	s.Logger.ErrorL("spi", "Failed to write a frame", zap.String("strip", s.Name), zap.Int("consecutive", s.failures), zap.Error(err))
+	_, missing := s.Display.(noDevice)
+	switch {
+	case s.open == nil, time.Now().Before(s.retryAt):
+		return
+	case !missing && (s.ReopenAfter <= 0 || s.failures < s.ReopenAfter):
+		return
+	}
+	s.failures = 0
+	m.reopens.Inc()
+	if err := s.reopen(); err != nil {
+		s.backoff = min(max(2*s.backoff, time.Second), maxBackoff)
+		s.retryAt = time.Now().Add(s.backoff)
+		s.Logger.ErrorL("spi-open", "Unable to open the SPI port", zap.String("strip", s.Name), zap.Duration("retry", s.backoff), zap.Error(err))
+		return
+	}
+	s.backoff, s.retryAt = 0, time.Time{}
+	s.Logger.Info("Opened the SPI port", zap.String("strip", s.Name))
+}
+
+// reopen closes the SPI port and opens it again, leaving it missing if that
+// fails. A write stuck on the old port is abandoned. The caller holds
+// writeMu.
+func (s *Strip) reopen() error {
+	if s.writer != nil {
+		// A stuck write still finishes into the buffered results.
+		s.writer.close()
+		s.writer = nil
+	}
+	if s.spidev != nil {
+		// Closing may block behind a stuck write.
+		go s.spidev.Close()
+		s.spidev = nil
+	}
+	port, display, err := s.open()
+	if err != nil {
+		s.Display = noDevice{}
+		return err
+	}
+	s.spidev, s.Display = port, display
+	return nil
+}
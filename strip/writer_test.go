@@ -0,0 +1,132 @@
+package strip
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWriteTimeout(t *testing.T) {
+	channels := 3
+	s := testStrip(2, Power{})
+	s.Channels = &channels
+	display := &counting{block: make(chan struct{})}
+	s.Display = display
+	s.WriteTimeout = 10 * time.Millisecond
+	frame := make([]byte, 6)
+
+	if err := s.write(frame); err != ErrWriteTimeout {
+		t.Fatalf("write() to a stuck display = %v, want ErrWriteTimeout", err)
+	}
+	if err := s.write(frame); err != ErrWriteStalled {
+		t.Fatalf("write() behind a stuck frame = %v, want ErrWriteStalled", err)
+	}
+	display.block <- struct{}{}
+	close(display.block)
+	time.Sleep(10 * time.Millisecond)
+	if err := s.write(frame); err != nil {
+		t.Fatalf("write() once the display recovered = %v", err)
+	}
+	if n := display.n.Load(); n != 2 {
+		t.Errorf("display got %d frames, want 2", n)
+	}
+}
+
+type closer struct{ closed chan struct{} }
+
+func (c closer) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestReopenAfterFailures(t *testing.T) {
+	channels := 3
+	s := testStrip(2, Power{})
+	s.Channels = &channels
+	s.Display = &frames{}
+	s.ReopenAfter = 2
+	old := closer{make(chan struct{})}
+	s.spidev = old
+	opened := 0
+	s.open = func() (io.Closer, Display, error) {
+		opened++
+		return closer{make(chan struct{})}, discard{}, nil
+	}
+
+	fail := errors.New("write failed")
+	s.failed(fail)
+	if opened != 0 {
+		t.Fatalf("reopened after one failure")
+	}
+	s.failed(nil)
+	s.failed(fail)
+	if opened != 0 {
+		t.Fatalf("reopened after failures that were not consecutive")
+	}
+	s.failed(fail)
+	if opened != 1 {
+		t.Fatalf("opened %d times after two failures in a row, want 1", opened)
+	}
+	select {
+	case <-old.closed:
+	case <-time.After(time.Second):
+		t.Error("old port not closed")
+	}
+	if _, ok := s.Display.(discard); !ok {
+		t.Errorf("display not replaced: %T", s.Display)
+	}
+}
+
+func TestHotplug(t *testing.T) {
+	channels := 3
+	s := testStrip(2, Power{})
+	s.Channels = &channels
+	s.Display = noDevice{}
+	s.ReopenAfter = 5
+	present := false
+	s.open = func() (io.Closer, Display, error) {
+		if !present {
+			return nil, nil, errors.New("no such device")
+		}
+		return closer{make(chan struct{})}, discard{}, nil
+	}
+
+	s.failed(s.write(make([]byte, 6)))
+	if _, ok := s.Display.(noDevice); !ok || s.backoff != time.Second {
+		t.Fatalf("after a failed open: display %T, backoff %v, want noDevice and 1s", s.Display, s.backoff)
+	}
+	present = true
+	s.failed(s.write(make([]byte, 6)))
+	if _, ok := s.Display.(noDevice); !ok {
+		t.Fatalf("opened again before the backoff elapsed")
+	}
+	s.retryAt = time.Time{}
+	s.failed(s.write(make([]byte, 6)))
+	if _, ok := s.Display.(discard); !ok || s.backoff != 0 {
+		t.Errorf("device not picked up once present: display %T, backoff %v", s.Display, s.backoff)
+	}
+}
+
+func TestReopenLeavesNoGoroutines(t *testing.T) {
+	channels := 3
+	s := testStrip(2, Power{})
+	s.Channels = &channels
+	s.Display = noDevice{}
+	s.WriteTimeout = time.Second
+	s.open = func() (io.Closer, Display, error) {
+		return nil, nil, errors.New("no such device")
+	}
+	s.failed(s.write(make([]byte, 6)))
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		s.retryAt = time.Time{}
+		s.failed(s.write(make([]byte, 6)))
+	}
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left over after 20 reopens", runtime.NumGoroutine()-before)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	"github.com/shift/systemd-status-leds/monitor"
+	"go.uber.org/zap"
+)
+
+// SystemdConfig is how unit states are followed: over D-Bus, the default
+// Transport, or varlink from the io.systemd.Unit socket at Varlink, for
+// systems without a D-Bus broker. When systemd refuses a D-Bus subscription,
+// as a restrictive bus policy or an old systemd may, they are polled every
+// Poll instead. Every call to systemd gives up after Timeout.
+type SystemdConfig struct {
+	Transport string
+	Varlink   string
+	Poll      time.Duration
+	Timeout   time.Duration
+	Bus       BusConfig
+}
+
+func (s SystemdConfig) Validate() error {
+	switch s.Transport {
+	case "", "dbus", "varlink":
+	default:
+		return fmt.Errorf("systemd.transport: unknown transport %q", s.Transport)
+	}
+	if s.Poll <= 0 {
+		return fmt.Errorf("systemd.poll must be positive, got %s", s.Poll)
+	}
+	if s.Timeout <= 0 {
+		return fmt.Errorf("systemd.timeout must be positive, got %s", s.Timeout)
+	}
+	return s.Bus.Validate("systemd.bus")
+}
+
+// systemdConn is what the daemon needs of systemd over either transport.
+type systemdConn interface {
+	monitor.Conn
+	Connected() bool
+}
+
+// connect connects to systemd over the configured transport. Restarting
+// units and following OnFailure= handlers need D-Bus, so sysd is only set
+// over that.
+func (s SystemdConfig) connect() (conn systemdConn, poll time.Duration, err error) {
+	if s.Transport == "varlink" {
+		v := &monitor.Varlink{Path: s.Varlink, Timeout: s.Timeout}
+		if !v.Connected() {
+			return nil, 0, fmt.Errorf("no varlink server at %s", s.Varlink)
+		}
+		return v, 0, nil
+	}
+	c, err := s.Bus.systemd()
+	if err != nil {
+		return nil, 0, err
+	}
+	sysd = c
+	if err := c.Subscribe(); err != nil {
+		logr.Warn("systemd refused a subscription, polling unit states instead", zap.Duration("interval", s.Poll), zap.Error(err))
+		poll = s.Poll
+	}
+	return c, poll, nil
+}
+
+// context bounds a call to systemd by Timeout.
+func (s SystemdConfig) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.Timeout)
+}
+
+// BusConfig picks the D-Bus a source talks to: the system bus unless
+// Address, such as unix:path=/run/alternate/bus or a forwarded
+// tcp:host=…,port=…, is set. Auth is external, the default, authenticating
+// as the daemon's uid, anonymous, or cookie for DBUS_COOKIE_SHA1 as the
+// daemon's user; User overrides either. Direct skips the Hello a bus daemon
+// expects, for a peer such as systemd's own /run/systemd/private.
+type BusConfig struct {
+	Address string
+	Auth    string
+	User    string
+	Direct  bool
+}
+
+func (b BusConfig) Validate(where string) error {
+	switch b.Auth {
+	case "", "external", "anonymous", "cookie":
+	default:
+		return fmt.Errorf("%s.auth: unknown authentication %q", where, b.Auth)
+	}
+	if b.Address == "" && (b.Auth != "" || b.User != "" || b.Direct) {
+		return fmt.Errorf("%s: auth, user and direct need an address", where)
+	}
+	return nil
+}
+
+// dial connects to the bus at Address and authenticates.
+func (b BusConfig) dial() (*dbus.Conn, error) {
+	auth, err := b.auth()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dbus.Dial(b.Address)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Auth([]dbus.Auth{auth}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !b.Direct {
+		if err := conn.Hello(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (b BusConfig) auth() (dbus.Auth, error) {
+	switch b.Auth {
+	case "anonymous":
+		return dbus.AuthAnonymous(), nil
+	case "cookie":
+		u, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		name := b.User
+		if name == "" {
+			name = u.Username
+		}
+		return dbus.AuthCookieSha1(name, u.HomeDir), nil
+	}
+	if b.User != "" {
+		return dbus.AuthExternal(b.User), nil
+	}
+	return dbus.AuthExternal(strconv.Itoa(os.Getuid())), nil
+}
+
+// connect connects to the bus, the shared system bus connection unless
+// Address is set.
+func (b BusConfig) connect() (*dbus.Conn, error) {
+	if b.Address == "" {
+		return dbus.SystemBus()
+	}
+	return b.dial()
+}
+
+// systemd connects to systemd over the bus, or as go-systemd does by default,
+// over the system bus or, as root, systemd's private socket, unless Address is
+// set.
+func (b BusConfig) systemd() (*systemd.Conn, error) {
+	if b.Address == "" {
+		return systemd.New()
+	}
+	return systemd.NewConnection(b.dial)
+}
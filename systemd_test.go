@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestBusConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		bus BusConfig
+		ok  bool
+	}{
+		{BusConfig{}, true},
+		{BusConfig{Address: "unix:path=/run/alternate/bus", Auth: "anonymous"}, true},
+		{BusConfig{Address: "unix:path=/run/systemd/private", Direct: true}, true},
+		{BusConfig{Address: "unix:path=/run/alternate/bus", Auth: "kerberos"}, false},
+		{BusConfig{User: "1000"}, false},
+	} {
+		if err := tc.bus.Validate("systemd.bus"); (err == nil) != tc.ok {
+			t.Errorf("%+v: %v", tc.bus, err)
+		}
+	}
+}
+
+func TestBusConfigAuth(t *testing.T) {
+	for _, tc := range []struct {
+		bus       BusConfig
+		mechanism string
+		data      string
+	}{
+		{BusConfig{}, "EXTERNAL", strconv.Itoa(os.Getuid())},
+		{BusConfig{User: "1000"}, "EXTERNAL", "1000"},
+		{BusConfig{Auth: "anonymous"}, "ANONYMOUS", ""},
+	} {
+		auth, err := tc.bus.auth()
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, data, _ := auth.FirstData()
+		if string(name) != tc.mechanism || (tc.data != "" && string(data) != hex.EncodeToString([]byte(tc.data))) {
+			t.Errorf("%+v: %s %s, want %s %s", tc.bus, name, data, tc.mechanism, tc.data)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"time"
+
+	"github.com/shift/systemd-status-leds/telemetry"
+	"go.uber.org/zap"
+)
+
+// OTLPConfig enables pushing metrics and spans to an OpenTelemetry collector.
+type OTLPConfig struct {
+	Endpoint string
+	Interval time.Duration
+	Sample   float64 // share of spans exported
+	Headers  map[string]string
+}
+
+var (
+	transitions = telemetry.NewCounter("statusleds_state_transitions_total", "Unit state transitions shown on the strip.")
+)
+
+func otlpLoop(c OTLPConfig) {
+	telemetry.SetSampleRate(c.Sample)
+	e := &telemetry.Exporter{Endpoint: c.Endpoint, Headers: c.Headers, Service: "systemd-status-leds"}
+	e.Run(c.Interval, func(err error) {
+		logr.ErrorL("otlp", "OTLP export failed", zap.String("endpoint", c.Endpoint), zap.Error(err))
+	})
+}
@@ -0,0 +1,198 @@
+package telemetry
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Attr is a key/value attribute attached to a metric point or span.
+type Attr struct {
+	Key   string
+	Value string
+}
+
+type kind int
+
+const (
+	counter kind = iota
+	gauge
+	histogram
+)
+
+// Metric is a counter, gauge or histogram with one value per distinct set of
+// attributes.
+type Metric struct {
+	Name        string
+	Description string
+	kind        kind
+	// bounds are the upper bounds of a histogram's buckets, ascending,
+	// with a last bucket above them all.
+	bounds []float64
+
+	mu     sync.Mutex
+	points map[string]*point
+}
+
+type point struct {
+	attrs []Attr
+	value float64 // the sum of a histogram's observations
+	// count and counts are the observations of a histogram, in all and in
+	// each bucket.
+	count  uint64
+	counts []uint64
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*Metric
+)
+
+func register(m *Metric) *Metric {
+	m.points = map[string]*point{}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+	return m
+}
+
+// NewCounter registers a monotonic counter.
+func NewCounter(name string, description string) *Metric {
+	return register(&Metric{Name: name, Description: description, kind: counter})
+}
+
+// NewGauge registers a gauge.
+func NewGauge(name string, description string) *Metric {
+	return register(&Metric{Name: name, Description: description, kind: gauge})
+}
+
+// NewHistogram registers a histogram with buckets up to each of bounds,
+// which must be ascending, and one above them.
+func NewHistogram(name string, description string, bounds []float64) *Metric {
+	return register(&Metric{Name: name, Description: description, kind: histogram, bounds: bounds})
+}
+
+func key(attrs []Attr) string {
+	parts := make([]string, len(attrs))
+	for i, a := range attrs {
+		parts[i] = a.Key + "=" + a.Value
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (m *Metric) point(attrs []Attr) *point {
+	k := key(attrs)
+	p, ok := m.points[k]
+	if !ok {
+		p = &point{attrs: attrs}
+		if m.kind == histogram {
+			p.counts = make([]uint64, len(m.bounds)+1)
+		}
+		m.points[k] = p
+	}
+	return p
+}
+
+// Add increases the value for attrs by v.
+func (m *Metric) Add(v float64, attrs ...Attr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.point(attrs).value += v
+}
+
+// Inc increases the value for attrs by one.
+func (m *Metric) Inc(attrs ...Attr) {
+	m.Add(1, attrs...)
+}
+
+// Set replaces the value for attrs.
+func (m *Metric) Set(v float64, attrs ...Attr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.point(attrs).value = v
+}
+
+// Observe adds v to the histogram for attrs.
+func (m *Metric) Observe(v float64, attrs ...Attr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observe(m.point(attrs), v)
+}
+
+// observe adds v to p. The caller holds mu.
+func (m *Metric) observe(p *point, v float64) {
+	i := sort.SearchFloat64s(m.bounds, v)
+	p.counts[i]++
+	p.count++
+	p.value += v
+}
+
+// Value returns the value recorded for attrs, the sum of its observations
+// for a histogram.
+func (m *Metric) Value(attrs ...Attr) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p, ok := m.points[key(attrs)]; ok {
+		return p.value
+	}
+	return 0
+}
+
+// Series is the value of a metric for one set of attributes, looked up once
+// so updating it does not allocate.
+type Series struct {
+	m *Metric
+	p *point
+}
+
+// Series returns the value for attrs.
+func (m *Metric) Series(attrs ...Attr) *Series {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &Series{m: m, p: m.point(attrs)}
+}
+
+// Add increases the value by v.
+func (s *Series) Add(v float64) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.p.value += v
+}
+
+// Inc increases the value by one.
+func (s *Series) Inc() {
+	s.Add(1)
+}
+
+// Set replaces the value.
+func (s *Series) Set(v float64) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.p.value = v
+}
+
+// Observe adds v to the histogram.
+func (s *Series) Observe(v float64) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	s.m.observe(s.p, v)
+}
+
+func (m *Metric) snapshot() []point {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]point, 0, len(m.points))
+	for _, p := range m.points {
+		c := *p
+		c.counts = append([]uint64(nil), p.counts...)
+		out = append(out, c)
+	}
+	return out
+}
+
+func metrics() []*Metric {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	return append([]*Metric(nil), registry...)
+}
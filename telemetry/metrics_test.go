@@ -0,0 +1,39 @@
+package telemetry
+
+import "testing"
+
+func TestSeries(t *testing.T) {
+	m := NewCounter("test_series_total", "")
+	a := Attr{Key: "strip", Value: "a"}
+	s := m.Series(a)
+	s.Inc()
+	s.Add(2)
+	m.Inc(a)
+	if got := m.Value(a); got != 4 {
+		t.Errorf("Value() = %v, want 4", got)
+	}
+	s.Set(1)
+	if got := m.Value(a); got != 1 {
+		t.Errorf("Value() after Set = %v, want 1", got)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	m := NewHistogram("test_histogram_seconds", "", []float64{0.1, 1})
+	s := m.Series()
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		s.Observe(v)
+	}
+	m.Observe(3)
+	p := m.snapshot()[0]
+	if p.count != 5 || p.value != 5.65 {
+		t.Errorf("count %d, sum %v, want 5 and 5.65", p.count, p.value)
+	}
+	want := []uint64{2, 1, 2}
+	for i := range want {
+		if p.counts[i] != want[i] {
+			t.Errorf("buckets %v, want %v", p.counts, want)
+			break
+		}
+	}
+}
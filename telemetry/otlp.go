@@ -0,0 +1,179 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exporter pushes metrics and spans to an OpenTelemetry collector using
+// OTLP/HTTP with JSON encoding.
+type Exporter struct {
+	Endpoint string // e.g. http://localhost:4318
+	Headers  map[string]string
+	Service  string
+	Client   *http.Client
+
+	start time.Time
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+func keyValues(attrs []Attr) []keyValue {
+	out := make([]keyValue, len(attrs))
+	for i, a := range attrs {
+		out[i] = keyValue{Key: a.Key, Value: anyValue{StringValue: a.Value}}
+	}
+	return out
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func (e *Exporter) resource() map[string]interface{} {
+	return map[string]interface{}{
+		"attributes": keyValues([]Attr{{Key: "service.name", Value: e.Service}}),
+	}
+}
+
+func (e *Exporter) scope() map[string]string {
+	return map[string]string{"name": e.Service}
+}
+
+func (e *Exporter) metricsBody(now time.Time) map[string]interface{} {
+	var out []map[string]interface{}
+	for _, m := range metrics() {
+		var points []map[string]interface{}
+		for _, p := range m.snapshot() {
+			point := map[string]interface{}{
+				"attributes":        keyValues(p.attrs),
+				"startTimeUnixNano": nanos(e.start),
+				"timeUnixNano":      nanos(now),
+			}
+			if m.kind == histogram {
+				point["count"] = p.count
+				point["sum"] = p.value
+				point["bucketCounts"] = p.counts
+				point["explicitBounds"] = m.bounds
+			} else {
+				point["asDouble"] = p.value
+			}
+			points = append(points, point)
+		}
+		if len(points) == 0 {
+			continue
+		}
+		metric := map[string]interface{}{"name": m.Name, "description": m.Description}
+		switch m.kind {
+		case counter:
+			metric["sum"] = map[string]interface{}{
+				"aggregationTemporality": 2, // cumulative
+				"isMonotonic":            true,
+				"dataPoints":             points,
+			}
+		case gauge:
+			metric["gauge"] = map[string]interface{}{"dataPoints": points}
+		case histogram:
+			metric["histogram"] = map[string]interface{}{
+				"aggregationTemporality": 2, // cumulative
+				"dataPoints":             points,
+			}
+		}
+		out = append(out, metric)
+	}
+	return map[string]interface{}{
+		"resourceMetrics": []map[string]interface{}{{
+			"resource":     e.resource(),
+			"scopeMetrics": []map[string]interface{}{{"scope": e.scope(), "metrics": out}},
+		}},
+	}
+}
+
+func (e *Exporter) tracesBody(pending []*Span) map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(pending))
+	for _, s := range pending {
+		status := map[string]interface{}{"code": 1} // ok
+		if s.err != nil {
+			status = map[string]interface{}{"code": 2, "message": s.err.Error()}
+		}
+		out = append(out, map[string]interface{}{
+			"traceId":           s.trace,
+			"spanId":            s.id,
+			"name":              s.Name,
+			"kind":              1, // internal
+			"startTimeUnixNano": nanos(s.start),
+			"endTimeUnixNano":   nanos(s.end),
+			"attributes":        keyValues(s.Attrs),
+			"status":            status,
+		})
+	}
+	return map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{{
+			"resource":   e.resource(),
+			"scopeSpans": []map[string]interface{}{{"scope": e.scope(), "spans": out}},
+		}},
+	}
+}
+
+func (e *Exporter) post(path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(e.Endpoint, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return nil
+}
+
+// Export sends the current metrics and any finished spans.
+func (e *Exporter) Export() error {
+	if e.start.IsZero() {
+		e.start = time.Now()
+	}
+	if e.Client == nil {
+		e.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if err := e.post("/v1/metrics", e.metricsBody(time.Now())); err != nil {
+		return err
+	}
+	if pending := takeSpans(); len(pending) > 0 {
+		return e.post("/v1/traces", e.tracesBody(pending))
+	}
+	return nil
+}
+
+// Run exports every interval, passing failures to onError.
+func (e *Exporter) Run(interval time.Duration, onError func(error)) {
+	e.start = time.Now()
+	for range time.Tick(interval) {
+		if err := e.Export(); err != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	c := NewCounter("test_total", "Test counter.")
+	c.Inc(Attr{Key: "unit", Value: "a.service"})
+	c.Inc(Attr{Key: "unit", Value: "a.service"})
+	SetSampleRate(1)
+	StartSpan("test.op").End(errors.New("boom"))
+
+	bodies := map[string]map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("%s: %v", r.URL.Path, err)
+		}
+		bodies[r.URL.Path] = body
+	}))
+	defer srv.Close()
+
+	e := &Exporter{Endpoint: srv.URL, Service: "test"}
+	if err := e.Export(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bodies["/v1/metrics"]["resourceMetrics"]; !ok {
+		t.Errorf("metrics body = %v", bodies["/v1/metrics"])
+	}
+	if _, ok := bodies["/v1/traces"]["resourceSpans"]; !ok {
+		t.Errorf("traces body = %v", bodies["/v1/traces"])
+	}
+	if got := c.Value(Attr{Key: "unit", Value: "a.service"}); got != 2 {
+		t.Errorf("Value() = %v, want 2", got)
+	}
+}
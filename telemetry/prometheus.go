@@ -0,0 +1,86 @@
+package telemetry
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus writes every metric in the Prometheus text exposition
+// format, the same values Exporter pushes over OTLP.
+func WritePrometheus(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	for _, m := range metrics() {
+		points := m.snapshot()
+		sort.Slice(points, func(i, j int) bool { return key(points[i].attrs) < key(points[j].attrs) })
+		out.WriteString("# HELP " + m.Name + " " + escapeHelp(m.Description) + "\n")
+		out.WriteString("# TYPE " + m.Name + " " + [...]string{"counter", "gauge", "histogram"}[m.kind] + "\n")
+		for _, p := range points {
+			if m.kind != histogram {
+				writeSample(out, m.Name, p.attrs, "", p.value)
+				continue
+			}
+			var cumulative uint64
+			for i, n := range p.counts {
+				cumulative += n
+				le := math.Inf(1)
+				if i < len(m.bounds) {
+					le = m.bounds[i]
+				}
+				writeSample(out, m.Name+"_bucket", p.attrs, formatFloat(le), float64(cumulative))
+			}
+			writeSample(out, m.Name+"_sum", p.attrs, "", p.value)
+			writeSample(out, m.Name+"_count", p.attrs, "", float64(p.count))
+		}
+	}
+	return out.Flush()
+}
+
+// Handler serves the metrics for Prometheus to scrape.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = WritePrometheus(w)
+	})
+}
+
+// writeSample writes one line: name, its labels, le for a histogram
+// bucket, and v.
+func writeSample(out *bufio.Writer, name string, attrs []Attr, le string, v float64) {
+	out.WriteString(name)
+	if len(attrs) > 0 || le != "" {
+		labels := make([]string, 0, len(attrs)+1)
+		for _, a := range attrs {
+			labels = append(labels, a.Key+`="`+escapeLabel(a.Value)+`"`)
+		}
+		if le != "" {
+			labels = append(labels, `le="`+le+`"`)
+		}
+		out.WriteString("{" + strings.Join(labels, ",") + "}")
+	}
+	out.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
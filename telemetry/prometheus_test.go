@@ -0,0 +1,34 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	c := NewCounter("test_prometheus_total", "Things\ncounted.")
+	c.Add(2, Attr{Key: "unit", Value: `a"b.service`})
+	h := NewHistogram("test_prometheus_seconds", "Time taken.", []float64{0.1, 1})
+	for _, v := range []float64{0.05, 0.5, 2} {
+		h.Observe(v, Attr{Key: "strip", Value: "main"})
+	}
+	var out strings.Builder
+	if err := WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# HELP test_prometheus_total Things\\ncounted.\n",
+		"# TYPE test_prometheus_total counter\n",
+		"test_prometheus_total{unit=\"a\\\"b.service\"} 2\n",
+		"# TYPE test_prometheus_seconds histogram\n",
+		"test_prometheus_seconds_bucket{strip=\"main\",le=\"0.1\"} 1\n",
+		"test_prometheus_seconds_bucket{strip=\"main\",le=\"1\"} 2\n",
+		"test_prometheus_seconds_bucket{strip=\"main\",le=\"+Inf\"} 3\n",
+		"test_prometheus_seconds_sum{strip=\"main\"} 2.55\n",
+		"test_prometheus_seconds_count{strip=\"main\"} 3\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+// Span times a single operation such as a D-Bus call or an SPI write.
+type Span struct {
+	Name  string
+	Attrs []Attr
+	start time.Time
+	end   time.Time
+	err   error
+	id    string
+	trace string
+}
+
+const maxPending = 4096
+
+var (
+	spansMu    sync.Mutex
+	spans      []*Span
+	sampleRate float64
+)
+
+// SetSampleRate sets the share of spans, between 0 and 1, that are exported.
+// Spans are dropped until it is set.
+func SetSampleRate(rate float64) {
+	spansMu.Lock()
+	defer spansMu.Unlock()
+	sampleRate = rate
+}
+
+// StartSpan starts timing name. It returns nil when the span is not sampled,
+// End is safe to call on nil.
+func StartSpan(name string, attrs ...Attr) *Span {
+	spansMu.Lock()
+	rate := sampleRate
+	spansMu.Unlock()
+	if rate <= 0 || mrand.Float64() >= rate {
+		return nil
+	}
+	return &Span{Name: name, Attrs: attrs, start: time.Now(), id: randomHex(8), trace: randomHex(16)}
+}
+
+// End finishes the span, recording err as its status.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	spansMu.Lock()
+	defer spansMu.Unlock()
+	if len(spans) < maxPending {
+		spans = append(spans, s)
+	}
+}
+
+func takeSpans() []*Span {
+	spansMu.Lock()
+	defer spansMu.Unlock()
+	out := spans
+	spans = nil
+	return out
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
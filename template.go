@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/monitor"
+	"go.uber.org/zap"
+)
+
+// instances shows the instances of a template service, such as
+// getty@.service, as they come and go: aggregated onto the service's pixel
+// like a group, or spread one to a pixel over its segment.
+type instances struct {
+	mu sync.Mutex
+	// group aggregates the instances, nil when they are spread.
+	group *group
+	// free are the spread pixels no instance has, in order.
+	free   []*led.Led
+	pixels map[string]*led.Led
+}
+
+// templates are the instances of each template service, by template.
+var templates = map[string]*instances{}
+
+// isTemplate reports whether unit is a template, such as getty@.service.
+func isTemplate(unit string) bool {
+	at := strings.IndexByte(unit, '@')
+	return at >= 0 && strings.HasPrefix(unit[at+1:], ".")
+}
+
+// serves reports whether the service is shown for unit: its own unit or an
+// instance of its template.
+func (s Service) serves(unit string) bool {
+	return s.Unit == unit || s.Unit == monitor.Template(unit)
+}
+
+func (s Service) validateInstances() error {
+	if !isTemplate(s.Unit) {
+		if s.Instances != "" {
+			return fmt.Errorf("%s: instances needs a template unit, such as getty@.service", s.Unit)
+		}
+		return nil
+	}
+	if len(s.Units) > 0 || s.Source != "" {
+		return fmt.Errorf("%s: a template can't have units or a source", s.Unit)
+	}
+	switch s.Instances {
+	case "", "aggregate":
+	case "spread":
+		if s.Segment == "" {
+			return fmt.Errorf("%s: spreading instances needs a segment", s.Unit)
+		}
+	default:
+		return fmt.Errorf("%s: unknown instances %q", s.Unit, s.Instances)
+	}
+	switch s.Aggregate {
+	case "", "worst", "all-active":
+	case "quorum":
+		if s.Quorum < 1 {
+			return fmt.Errorf("%s: quorum must be at least 1", s.Unit)
+		}
+	default:
+		return fmt.Errorf("%s: unknown aggregate %q", s.Unit, s.Aggregate)
+	}
+	return nil
+}
+
+// templateUnits are the templates whose instances systemd reports.
+func templateUnits() []string {
+	var units []string
+	for _, service := range C.Services {
+		if isTemplate(service.Unit) && service.Source == "" {
+			units = append(units, service.Unit)
+		}
+	}
+	return units
+}
+
+// addInstances shows the instances of service aggregated onto pixel, or
+// spread over pixels when pixel is nil.
+func addInstances(service Service, pixel *led.Led, pixels []*led.Led) {
+	t := &instances{free: pixels, pixels: map[string]*led.Led{}}
+	if pixel != nil {
+		t.group = &group{pixel: pixel, aggregate: service.Aggregate, quorum: service.Quorum}
+	}
+	templates[service.Unit] = t
+}
+
+// pixel returns the pixel of an instance, giving it one if it is new, or nil
+// when every spread pixel is taken.
+func (t *instances) pixel(unit string) *led.Led {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.pixels[unit]; ok {
+		return p
+	}
+	var p *led.Led
+	if g := t.group; g != nil {
+		p = &led.Led{}
+		p.Unit = unit
+		g.mu.Lock()
+		g.members = append(g.members, p)
+		g.mu.Unlock()
+		groupsMu.Lock()
+		groups[p] = g
+		groupsMu.Unlock()
+	} else {
+		if len(t.free) == 0 {
+			logr.InfoL("instances", "No free pixel for instance", zap.String("unit", unit))
+			return nil
+		}
+		p, t.free = t.free[0], t.free[1:]
+		p.Lock()
+		p.Unit = unit
+		p.Unlock()
+	}
+	t.pixels[unit] = p
+	return p
+}
+
+// remove forgets an instance systemd no longer lists, freeing its pixel. A
+// template without instances shows as inactive.
+func (t *instances) remove(unit string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pixels[unit]
+	if !ok {
+		return
+	}
+	delete(t.pixels, unit)
+	if g := t.group; g != nil {
+		g.mu.Lock()
+		for i, m := range g.members {
+			if m == p {
+				g.members = append(g.members[:i], g.members[i+1:]...)
+				break
+			}
+		}
+		empty := len(g.members) == 0
+		g.mu.Unlock()
+		groupsMu.Lock()
+		delete(groups, p)
+		groupsMu.Unlock()
+		if empty {
+			setState(g.pixel, "inactive")
+		} else {
+			g.update()
+		}
+		return
+	}
+	p.Lock()
+	p.Unit = monitor.Template(unit)
+	p.Status, p.SubState, p.Colour, p.Pattern = "", "", "", led.Pattern{}
+	p.Unlock()
+	t.free = append(t.free, p)
+	sort.Slice(t.free, func(i, j int) bool { return t.free[i].Number < t.free[j].Number })
+	markDirty()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jar-o/limlog"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/loglimit"
+	"github.com/shift/systemd-status-leds/monitor"
+	"go.uber.org/zap"
+)
+
+func TestInstancesAggregate(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	service := Service{Unit: "getty@.service"}
+	C = Config{Services: []Service{service}}
+	defer func() { C, templates = Config{}, map[string]*instances{} }()
+	pixel := &led.Led{}
+	pixel.Unit = service.Unit
+	addInstances(service, pixel, nil)
+
+	r := renderer{}
+	r.Render(monitor.Event{Unit: "getty@tty1.service", Template: service.Unit, State: "active", Initial: true})
+	r.Render(monitor.Event{Unit: "getty@tty2.service", Template: service.Unit, State: "failed", Initial: true})
+	if pixel.Status != "failed" {
+		t.Errorf("with a failed instance: %q, want failed", pixel.Status)
+	}
+	r.Render(monitor.Event{Unit: "getty@tty2.service", Template: service.Unit, Gone: true})
+	if pixel.Status != "active" {
+		t.Errorf("once the failed instance is gone: %q, want active", pixel.Status)
+	}
+	r.Render(monitor.Event{Unit: "getty@tty1.service", Template: service.Unit, Gone: true})
+	if pixel.Status != "inactive" {
+		t.Errorf("without instances: %q, want inactive", pixel.Status)
+	}
+}
+
+func TestInstancesSpread(t *testing.T) {
+	logr = loglimit.New(limlog.NewLimlogWithZap(zap.NewNop()))
+	service := Service{Unit: "worker@.service", Instances: "spread", Segment: "workers"}
+	C = Config{Services: []Service{service}}
+	defer func() { C, templates = Config{}, map[string]*instances{} }()
+	var pixels []*led.Led
+	for number := 1; number <= 2; number++ {
+		p := &led.Led{Number: number}
+		p.Unit = service.Unit
+		pixels = append(pixels, p)
+	}
+	addInstances(service, nil, pixels)
+
+	r := renderer{}
+	for _, unit := range []string{"worker@a.service", "worker@b.service", "worker@c.service"} {
+		r.Render(monitor.Event{Unit: unit, Template: service.Unit, State: "active", Initial: true})
+	}
+	if pixels[0].Unit != "worker@a.service" || pixels[1].Unit != "worker@b.service" {
+		t.Fatalf("pixels show %q and %q", pixels[0].Unit, pixels[1].Unit)
+	}
+	r.Render(monitor.Event{Unit: "worker@a.service", Template: service.Unit, Gone: true})
+	if pixels[0].Unit != service.Unit || pixels[0].Status != "" {
+		t.Errorf("freed pixel shows %q in %q", pixels[0].Unit, pixels[0].Status)
+	}
+	r.Render(monitor.Event{Unit: "worker@d.service", Template: service.Unit, State: "failed"})
+	if pixels[0].Unit != "worker@d.service" || pixels[0].Status != "failed" {
+		t.Errorf("new instance on %q in %q", pixels[0].Unit, pixels[0].Status)
+	}
+}
+
+func TestValidateInstances(t *testing.T) {
+	for _, tc := range []struct {
+		service Service
+		ok      bool
+	}{
+		{Service{Unit: "getty@.service"}, true},
+		{Service{Unit: "getty@.service", Instances: "spread", Segment: "ttys"}, true},
+		{Service{Unit: "getty@.service", Instances: "spread"}, false},
+		{Service{Unit: "getty@.service", Instances: "sideways"}, false},
+		{Service{Unit: "getty@.service", Aggregate: "quorum"}, false},
+		{Service{Unit: "nginx.service", Instances: "aggregate"}, false},
+		{Service{Unit: "getty@tty1.service"}, true},
+	} {
+		if err := tc.service.validateInstances(); (err == nil) != tc.ok {
+			t.Errorf("%+v: %v", tc.service, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// themes are the built-in palettes selected with `theme:`. Any state in
+// strip.colours overrides the theme. Disabled units look inactive unless
+// given a colour of their own.
+var themes = map[string]map[string]string{
+	"classic": {
+		"active":       "00ff0000",
+		"inactive":     "01010101",
+		"reloading":    "11551100",
+		"failed":       "55002200",
+		"activating":   "00442200",
+		"deactivating": "22440000",
+		"masked":       "22002200",
+		"disabled":     "01010101",
+		"skipped":      "00101000",
+		"watchdog":     "55220000",
+		"armed":        "00660000",
+		"absent":       "00000200",
+		"missing":      "55001100",
+	},
+	"pastel": {
+		"active":       "44aa6600",
+		"inactive":     "08080808",
+		"reloading":    "66886600",
+		"failed":       "aa444400",
+		"activating":   "44664400",
+		"deactivating": "66664400",
+		"masked":       "66446600",
+		"disabled":     "08080808",
+		"skipped":      "22444400",
+		"watchdog":     "aa664400",
+		"armed":        "22553300",
+		"absent":       "04040800",
+		"missing":      "aa224400",
+	},
+	"high-contrast": {
+		"active":       "00ff0000",
+		"inactive":     "00000000",
+		"reloading":    "0000ff00",
+		"failed":       "ff000000",
+		"activating":   "ffff0000",
+		"deactivating": "ff00ff00",
+		"masked":       "8800ff00",
+		"disabled":     "00000000",
+		"skipped":      "00ffff00",
+		"watchdog":     "ff880000",
+		"armed":        "00880000",
+		"absent":       "00000000",
+		"missing":      "ff000000",
+	},
+	// monochrome-white drives only the white channel of RGBW strips, states
+	// differ by brightness.
+	"monochrome-white": {
+		"active":       "00000040",
+		"inactive":     "00000002",
+		"reloading":    "00000020",
+		"failed":       "000000ff",
+		"activating":   "00000010",
+		"deactivating": "00000008",
+		"masked":       "00000004",
+		"disabled":     "00000002",
+		"skipped":      "00000003",
+		"watchdog":     "000000c0",
+		"armed":        "00000030",
+		"absent":       "00000001",
+		"missing":      "000000ff",
+	},
+}
+
+func validateTheme(name string) error {
+	if _, ok := themes[name]; !ok {
+		return fmt.Errorf("unknown theme %q", name)
+	}
+	return nil
+}
+
+// FlashConfig briefly shows Colour on a pixel whenever its state changes, so
+// quick cycles such as active→reloading→active are noticeable. A zero
+// Duration disables it.
+type FlashConfig struct {
+	Colour   string
+	Duration time.Duration
+}
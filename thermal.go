@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+type ThermalThreshold struct {
+	Celsius    float64
+	Brightness float64 // percent of full brightness at or above Celsius
+}
+
+type ThermalConfig struct {
+	Sensor     string // sysfs file reporting millidegrees Celsius
+	Interval   time.Duration
+	Hysteresis float64
+	Thresholds []ThermalThreshold
+}
+
+// readCelsius reads a thermal_zone or hwmon temperature file.
+func readCelsius(path string) (float64, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	milli, err := strconv.ParseFloat(strings.TrimSpace(string(raw)), 64)
+	if err != nil {
+		return 0, err
+	}
+	return milli / 1000, nil
+}
+
+// thermalLevel returns the index of the highest threshold reached, or -1.
+// Levels only drop once the temperature is Hysteresis below the threshold so
+// the strip doesn't flicker around a boundary.
+func (t ThermalConfig) thermalLevel(celsius float64, current int) int {
+	level := -1
+	for i, th := range t.Thresholds {
+		if celsius >= th.Celsius {
+			level = i
+		}
+	}
+	if level < current && celsius > t.Thresholds[current].Celsius-t.Hysteresis {
+		level = current
+	}
+	return level
+}
+
+func thermalLoop(strips []*strip.Strip, t ThermalConfig) {
+	level := -1
+	for {
+		celsius, err := readCelsius(t.Sensor)
+		if err != nil {
+			logr.ErrorL("thermal", "Failed to read temperature", zap.String("sensor", t.Sensor), zap.Error(err))
+		} else if next := t.thermalLevel(celsius, level); next != level {
+			brightness := 100.0
+			if next >= 0 {
+				brightness = t.Thresholds[next].Brightness
+			}
+			logr.Info("Thermal throttle changed",
+				zap.Float64("celsius", celsius),
+				zap.Float64("brightness", brightness),
+			)
+			for _, s := range strips {
+				s.SetThrottle(brightness / 100)
+			}
+			level = next
+		}
+		time.Sleep(t.Interval)
+	}
+}
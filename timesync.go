@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/monitor"
+	"go.uber.org/zap"
+)
+
+// TimesyncConfig dedicates Pixel to whether the clock is synchronised, as
+// reported by systemd-timedated, checked every Interval. The pixel shows the
+// active colour while synchronised and the failed colour otherwise.
+type TimesyncConfig struct {
+	Pixel    int
+	Interval time.Duration
+	Bus      BusConfig
+}
+
+func (c TimesyncConfig) Validate() error {
+	if c.Pixel > 0 && c.Interval <= 0 {
+		return fmt.Errorf("timesync.interval must be positive")
+	}
+	return c.Bus.Validate("timesync.bus")
+}
+
+// ntpSynchronized asks timedated whether the clock is synchronised.
+func ntpSynchronized(conn *dbus.Conn) (bool, error) {
+	var synced bool
+	err := monitor.Traced("GetProperty", "systemd-timedated", func() error {
+		v, err := conn.Object("org.freedesktop.timedate1", "/org/freedesktop/timedate1").
+			GetProperty("org.freedesktop.timedate1.NTPSynchronized")
+		if err != nil {
+			return err
+		}
+		return v.Store(&synced)
+	})
+	return synced, err
+}
+
+func timesyncLoop(pixel *led.Led, c TimesyncConfig) {
+	conn, err := c.Bus.connect()
+	if err != nil {
+		logr.Error("Unable to connect to the system bus for timesync", zap.Error(err))
+		return
+	}
+	for ; ; time.Sleep(c.Interval) {
+		synced, err := ntpSynchronized(conn)
+		if err != nil {
+			logr.ErrorL("dbus", "Failed to get NTPSynchronized", zap.Error(err))
+		}
+		state := "active"
+		if !synced {
+			state = "failed"
+		}
+		if pixel.Status != state {
+			logr.Info("Clock synchronisation changed", zap.Bool("synchronized", synced))
+		}
+		pixel.SetStatus(state)
+		setColour(pixel, colourFor(pixel.Unit, state))
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+	"github.com/shift/systemd-status-leds/monitor"
+	"github.com/shift/systemd-status-leds/strip"
+	"go.uber.org/zap"
+)
+
+// UptimeConfig shows how available each active unit was over the last
+// Window, from the history, as well as its current state. Mode "dim" darkens
+// its pixel the further it fell short of always up, darkest at Target
+// percent and below; "blink" blinks it off briefly if it was up less than
+// Target percent. An empty Mode disables it.
+type UptimeConfig struct {
+	Mode   string
+	Window time.Duration
+	Target float64
+}
+
+// uptimeDimmest is how far "dim" darkens a unit up Target percent or less.
+const uptimeDimmest = 0.8
+
+// uptimeBlink blinks a unit up less than Target percent briefly off.
+var uptimeBlink = led.Pattern{Period: 4 * time.Second, Duty: 0.85}
+
+func (u UptimeConfig) Validate(c *Config) error {
+	switch u.Mode {
+	case "":
+		return nil
+	case "dim", "blink":
+	default:
+		return fmt.Errorf("history.uptime.mode must be dim or blink, got %q", u.Mode)
+	}
+	if c.History.File == "" {
+		return fmt.Errorf("history.uptime needs history.file")
+	}
+	if u.Window <= 0 {
+		return fmt.Errorf("history.uptime.window must be positive")
+	}
+	if u.Target <= 0 || u.Target >= 100 {
+		return fmt.Errorf("history.uptime.target must be a percentage between 0 and 100, got %v", u.Target)
+	}
+	if u.Mode == "dim" && c.Decay.Over > 0 {
+		return fmt.Errorf("history.uptime dim and decay both dim pixels, use one")
+	}
+	return nil
+}
+
+// availability is the fraction of the time in times that unit, or every
+// instance of it together, was active, and whether any of it is known.
+func availability(times map[string]unitUptime, unit string) (float64, bool) {
+	var up, known time.Duration
+	for name, t := range times {
+		if name == unit || monitor.Template(name) == unit {
+			up, known = up+t.up, known+t.known
+		}
+	}
+	if known == 0 {
+		return 0, false
+	}
+	return float64(up) / float64(known), true
+}
+
+// showUptime shows the availability of an active pixel's unit, from the
+// uptimes of the units over the window.
+func showUptime(pixel *led.Led, times map[string]unitUptime, u UptimeConfig) {
+	if pixel.Status != "active" {
+		if u.Mode == "dim" {
+			pixel.SetDim(0)
+		}
+		return
+	}
+	available, ok := availability(times, pixel.Unit)
+	if !ok {
+		available = 1
+	}
+	short := (1 - available) / (1 - u.Target/100)
+	switch u.Mode {
+	case "dim":
+		pixel.SetDim(uptimeDimmest * min(short, 1))
+	case "blink":
+		if short > 1 {
+			pixel.SetPattern(uptimeBlink)
+		} else if pixel.Pattern == uptimeBlink {
+			pixel.SetPattern(led.Pattern{})
+		}
+	}
+}
+
+// uptimeLoop reshows the availability of the units every minute.
+func uptimeLoop(strips []*strip.Strip, h *historian, u UptimeConfig) {
+	for now := time.Now(); ; now = <-time.After(time.Minute) {
+		entries, err := h.entries()
+		if err != nil {
+			logr.ErrorL("history", "Unable to read state history", zap.Error(err))
+			continue
+		}
+		times := uptimes(entries, now.Add(-u.Window), now)
+		for _, pixel := range pixelsOf(strips) {
+			showUptime(pixel, times, u)
+		}
+	}
+}
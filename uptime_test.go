@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shift/systemd-status-leds/led"
+)
+
+func TestShowUptime(t *testing.T) {
+	now := time.Now()
+	entries := []historyEntry{
+		{Time: now.Add(-24 * time.Hour), Unit: "a.service", State: "active"},
+		{Time: now.Add(-12 * time.Hour), Unit: "a.service", State: "failed"},
+		{Time: now.Add(-11*time.Hour - 45*time.Minute - 36*time.Second), Unit: "a.service", State: "active"},
+		{Time: now.Add(-24 * time.Hour), Unit: "b@1.service", State: "active"},
+		{Time: now.Add(-24 * time.Hour), Unit: "b@2.service", State: "failed"},
+		{Time: now.Add(-time.Hour), Unit: "b@2.service", State: "active"},
+	}
+	u := UptimeConfig{Mode: "dim", Window: 24 * time.Hour, Target: 90}
+	for _, tc := range []struct {
+		unit string
+		want float64
+	}{
+		{"a.service", uptimeDimmest * 0.1},
+		{"b@.service", uptimeDimmest},
+		{"c.service", 0},
+	} {
+		pixel := &led.Led{Unit: tc.unit, Status: "active"}
+		showUptime(pixel, uptimes(entries, now.Add(-u.Window), now), u)
+		if diff := pixel.Dim - tc.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("%s dimmed by %v, want %v", tc.unit, pixel.Dim, tc.want)
+		}
+	}
+
+	u.Mode = "blink"
+	pixel := &led.Led{Unit: "b@.service", Status: "active"}
+	showUptime(pixel, uptimes(entries, now.Add(-u.Window), now), u)
+	if pixel.Pattern != uptimeBlink {
+		t.Errorf("unit up under target: pattern %+v, want %+v", pixel.Pattern, uptimeBlink)
+	}
+	showUptime(pixel, uptimes(entries[:3], now.Add(-u.Window), now), u)
+	if pixel.Pattern != (led.Pattern{}) {
+		t.Errorf("unit no longer under target: pattern %+v, want none", pixel.Pattern)
+	}
+	failed := &led.Led{Unit: "b@.service", Status: "failed", Pattern: led.Pattern{Period: time.Second, Duty: 0.5}}
+	showUptime(failed, uptimes(entries, now.Add(-u.Window), now), u)
+	if failed.Pattern.Period != time.Second {
+		t.Errorf("failed unit's pattern changed to %+v", failed.Pattern)
+	}
+
+	u.Mode = "dim"
+	dimmed := &led.Led{Unit: "b@.service", Status: "active"}
+	showUptime(dimmed, uptimes(entries, now.Add(-u.Window), now), u)
+	dimmed.SetStatus("failed")
+	showUptime(dimmed, uptimes(entries, now.Add(-u.Window), now), u)
+	if dimmed.Dim != 0 {
+		t.Errorf("failed unit still dimmed by %v", dimmed.Dim)
+	}
+}
+
+func TestUptimeValidate(t *testing.T) {
+	c := &Config{History: HistoryConfig{File: "history.jsonl"}, Decay: DecayConfig{Over: time.Hour}}
+	for _, u := range []UptimeConfig{
+		{Mode: "pulse", Window: time.Hour, Target: 90},
+		{Mode: "blink", Target: 90},
+		{Mode: "blink", Window: time.Hour, Target: 100},
+		{Mode: "dim", Window: time.Hour, Target: 90},
+	} {
+		if err := u.Validate(c); err == nil {
+			t.Errorf("%+v accepted", u)
+		}
+	}
+	if err := (UptimeConfig{Mode: "blink", Window: time.Hour, Target: 90}).Validate(c); err != nil {
+		t.Error(err)
+	}
+}
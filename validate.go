@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shift/systemd-status-leds/monitor"
+)
+
+// validate checks a configuration without touching the strip, printing every
+// problem found. It returns the process exit status.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	path := flags.String("config", "", "configuration file")
+	withSystemd := flags.Bool("systemd", false, "fail on units systemd doesn't know")
+	_ = flags.Parse(args)
+
+	if err := loadConfig(*path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, warning := range configWarnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+	errs := C.Validate()
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if *withSystemd {
+		missing, err := missingUnits()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "systemd:", err)
+			return 1
+		}
+		for _, unit := range missing {
+			fmt.Fprintf(os.Stderr, "%s is not known to systemd\n", unit)
+		}
+		if len(missing) > 0 {
+			return 1
+		}
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Println("configuration ok")
+	return 0
+}
+
+// missingUnits lists the configured units systemd doesn't have loaded, but
+// for optional ones.
+func missingUnits() ([]string, error) {
+	var conn monitor.Conn = &monitor.Varlink{Path: C.Systemd.Varlink, Timeout: C.Systemd.Timeout}
+	if C.Systemd.Transport != "varlink" {
+		c, err := C.Systemd.Bus.systemd()
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		conn = c
+	}
+	var names []string
+	for _, service := range C.Services {
+		if len(service.Units) > 0 {
+			names = append(names, service.Units...)
+		} else {
+			names = append(names, service.Unit)
+		}
+	}
+	ctx, cancel := C.Systemd.context()
+	defer cancel()
+	units, err := conn.ListUnitsByNamesContext(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, unit := range units {
+		if unit.LoadState == "not-found" && !optionalUnit(unit.Name) {
+			missing = append(missing, unit.Name)
+		}
+	}
+	return missing, nil
+}